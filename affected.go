@@ -0,0 +1,182 @@
+package kanvas
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Affected returns the IDs of the jobs in the plan affected by the changed files, sorted.
+//
+// A component is affected when any of the files is within its dir, matches any of its watch paths,
+// or is the config file like kanvas.yaml.
+// The jobs that need an affected job are affected as well, transitively,
+// including the synthetic tests.
+// Each file is either absolute or relative to the current working directory.
+func (wf *Workflow) Affected(files []string) ([]string, error) {
+	var absFiles []string
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return nil, err
+		}
+		absFiles = append(absFiles, abs)
+	}
+
+	inPlan := map[string]struct{}{}
+	for _, phase := range wf.Plan {
+		for _, id := range phase {
+			inPlan[id] = struct{}{}
+		}
+	}
+
+	affected := map[string]struct{}{}
+	for id := range inPlan {
+		j := wf.WorkflowJobs[id]
+
+		// Only the components are affected by the files directly.
+		// The special git job and the synthetic tests are in the base dir,
+		// but they are affected only via the components they need.
+		if _, ok := j.config.(Component); !ok {
+			continue
+		}
+
+		ok, err := j.affectedBy(wf.Dir, absFiles)
+		if err != nil {
+			return nil, fmt.Errorf("component %q: %w", id, err)
+		}
+
+		if ok {
+			affected[id] = struct{}{}
+		}
+	}
+
+	dependents := map[string][]string{}
+	for id := range inPlan {
+		for _, n := range wf.WorkflowJobs[id].Needs {
+			dependents[n] = append(dependents[n], id)
+		}
+	}
+
+	var visit func(id string)
+	visit = func(id string) {
+		for _, d := range dependents[id] {
+			if _, ok := affected[d]; ok {
+				continue
+			}
+			affected[d] = struct{}{}
+			visit(d)
+		}
+	}
+
+	for _, id := range sortedKeys(affected) {
+		visit(id)
+	}
+
+	return sortedKeys(affected), nil
+}
+
+// affectedBy reports whether any of the absolute file paths is within the dir of the job,
+// matches any of its watch paths, or is the config file in baseDir, like kanvas.yaml.
+//
+// Every component is defined in the config file, so that any change to it may change the component.
+// A job in baseDir, like a component without dir, would be affected by every file otherwise.
+// So it is affected only by the config files and its watch paths.
+func (j *WorkflowJob) affectedBy(baseDir string, files []string) (bool, error) {
+	dir, err := filepath.Abs(j.Dir)
+	if err != nil {
+		return false, err
+	}
+
+	base, err := filepath.Abs(baseDir)
+	if err != nil {
+		return false, err
+	}
+
+	var paths []string
+	for _, f := range []string{DefaultConfigFileYAML, DefaultConfigFileJsonnet, DefaultConfigFileTemplateJsonnet} {
+		paths = append(paths, filepath.Join(base, f))
+	}
+
+	if dir != base {
+		paths = append(paths, dir)
+	}
+
+	for _, w := range j.Watch {
+		abs, err := filepath.Abs(w)
+		if err != nil {
+			return false, err
+		}
+		paths = append(paths, abs)
+	}
+
+	for _, f := range files {
+		for _, p := range paths {
+			ok, err := matchPath(p, f)
+			if err != nil {
+				return false, err
+			}
+
+			if ok {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// matchPath reports whether the file is the path, within the path,
+// or matches the path as a glob pattern like path.Match.
+// In addition, `**` in the pattern matches zero or more directories, like `/protos/**/*.proto`.
+func matchPath(pattern, file string) (bool, error) {
+	for f := file; ; f = filepath.Dir(f) {
+		if f == pattern {
+			return true, nil
+		}
+
+		if strings.ContainsAny(pattern, `*?[\`) {
+			ok, err := matchGlob(strings.Split(pattern, string(filepath.Separator)), strings.Split(f, string(filepath.Separator)))
+			if err != nil {
+				return false, fmt.Errorf("invalid watch pattern %q: %w", pattern, err)
+			}
+
+			if ok {
+				return true, nil
+			}
+		}
+
+		if parent := filepath.Dir(f); parent == f {
+			return false, nil
+		}
+	}
+}
+
+// matchGlob reports whether the path elements match the pattern elements.
+// Each pattern element is matched by filepath.Match, except for `**` that matches zero or more elements.
+func matchGlob(pattern, elems []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				ok, err := matchGlob(pattern[1:], elems[i:])
+				if err != nil || ok {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+
+		if len(elems) == 0 {
+			return false, nil
+		}
+
+		ok, err := filepath.Match(pattern[0], elems[0])
+		if err != nil || !ok {
+			return false, err
+		}
+
+		pattern, elems = pattern[1:], elems[1:]
+	}
+
+	return len(elems) == 0, nil
+}
@@ -0,0 +1,75 @@
+package kanvas_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/davinci-std/kanvas"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkflowAffected(t *testing.T) {
+	dir := t.TempDir()
+
+	c := kanvas.Component{
+		Dir: dir,
+		Components: map[string]kanvas.Component{
+			"infra": {
+				Dir:  "infra",
+				Noop: &kanvas.Noop{},
+			},
+			"image": {
+				Dir:   "app",
+				Watch: []string{"/shared", "../protos/*.proto"},
+				Noop:  &kanvas.Noop{},
+			},
+			"deploy": {
+				Dir:   "deploy",
+				Needs: []string{"image", "infra"},
+				Noop:  &kanvas.Noop{},
+			},
+			"base": {
+				Watch: []string{"charts/**/values.yaml"},
+				Noop:  &kanvas.Noop{},
+			},
+		},
+		Tests: map[string]kanvas.Test{
+			"smoke": {
+				Needs:  []string{"deploy"},
+				Prober: "http",
+				Target: "https://example.com",
+			},
+		},
+	}
+
+	w, err := kanvas.NewWorkflow(c, kanvas.Options{TempDir: t.TempDir()})
+	require.NoError(t, err)
+
+	testcases := []struct {
+		files []string
+		want  []string
+	}{
+		{files: []string{"README.md"}, want: []string{}},
+		{files: []string{"infra/main.tf"}, want: []string{"deploy", "infra", "smoke"}},
+		{files: []string{"deploy/kustomization.yaml"}, want: []string{"deploy", "smoke"}},
+		{files: []string{"shared/lib.go"}, want: []string{"deploy", "image", "smoke"}},
+		{files: []string{"protos/app.proto"}, want: []string{"deploy", "image", "smoke"}},
+		{files: []string{"protos/README.md"}, want: []string{}},
+		{files: []string{"app2/main.go"}, want: []string{}},
+		{files: []string{"kanvas.yaml"}, want: []string{"base", "deploy", "image", "infra", "smoke"}},
+		{files: []string{"charts/values.yaml"}, want: []string{"base"}},
+		{files: []string{"charts/app/env/values.yaml"}, want: []string{"base"}},
+		{files: []string{"charts/app/Chart.yaml"}, want: []string{}},
+	}
+
+	for _, tc := range testcases {
+		var files []string
+		for _, f := range tc.files {
+			files = append(files, filepath.Join(dir, f))
+		}
+
+		got, err := w.Affected(files)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, got, "files %v", tc.files)
+	}
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/davinci-std/kanvas"
+	"github.com/davinci-std/kanvas/plugin"
+)
+
+const (
+	// AffectedOutputText prints the affected components one per line
+	AffectedOutputText = "text"
+	// AffectedOutputJSON prints the affected components and the changed files in JSON
+	AffectedOutputJSON = "json"
+)
+
+// AffectedOutputs is the list of the supported output formats of Affected
+var AffectedOutputs = []string{AffectedOutputText, AffectedOutputJSON, plugin.FormatGitHubActions}
+
+// AffectedResult is the result of Affected in the json output format.
+type AffectedResult struct {
+	// Base is the git ref the changes are computed against.
+	Base string `json:"base"`
+	// Files is the list of the changed files, relative to the root of the git repository.
+	Files []string `json:"files"`
+	// Components is the list of the IDs of the affected components, including the dependents.
+	Components []string `json:"components"`
+}
+
+// Affected prints the components affected by the changes since the git ref base.
+//
+// The changed files are the ones changed between the merge base of base and HEAD, and HEAD,
+// like the files changed in a pull request.
+// When base is empty, all the components are considered affected.
+func (a *App) Affected(base, output string) error {
+	wf, err := a.newWorkflow()
+	if err != nil {
+		return err
+	}
+
+	res := AffectedResult{
+		Base:       base,
+		Files:      []string{},
+		Components: []string{},
+	}
+
+	if base == "" {
+		fmt.Fprintf(os.Stderr, "No base ref is given. All the components are considered affected\n")
+		for _, phase := range wf.Plan {
+			res.Components = append(res.Components, phase...)
+		}
+	} else {
+		top, files, err := a.changedFiles(wf.Dir, base)
+		if err != nil {
+			return err
+		}
+		res.Files = append(res.Files, files...)
+
+		var paths []string
+		for _, f := range files {
+			paths = append(paths, filepath.Join(top, f))
+		}
+
+		affected, err := wf.Affected(paths)
+		if err != nil {
+			return err
+		}
+		res.Components = append(res.Components, affected...)
+	}
+
+	switch output {
+	case AffectedOutputText:
+		for _, id := range res.Components {
+			fmt.Println(id)
+		}
+	case AffectedOutputJSON:
+		data, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling affected components: %w", err)
+		}
+		fmt.Printf("%s\n", data)
+	case plugin.FormatGitHubActions:
+		return plugin.New(wf, a.Runtime).OutputAffected(output, res.Components)
+	default:
+		return fmt.Errorf("unsupported output %q", output)
+	}
+
+	return nil
+}
+
+// changedFiles returns the root of the git repository that contains dir,
+// and the files changed since the merge base of base and HEAD, relative to the root.
+func (a *App) changedFiles(dir, base string) (string, []string, error) {
+	if dir == "" {
+		dir = "."
+	}
+
+	var top bytes.Buffer
+	if err := a.Runtime.Exec(dir, []string{"git", "rev-parse", "--show-toplevel"}, kanvas.ExecStdout(&top)); err != nil {
+		return "", nil, fmt.Errorf("unable to find the git repository: %w", err)
+	}
+
+	var diff bytes.Buffer
+	if err := a.Runtime.Exec(dir, []string{"git", "diff", "--name-only", base + "...HEAD"}, kanvas.ExecStdout(&diff)); err != nil {
+		return "", nil, fmt.Errorf("unable to get the changed files since %q: %w", base, err)
+	}
+
+	var files []string
+	for _, l := range strings.Split(diff.String(), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			files = append(files, l)
+		}
+	}
+
+	return strings.TrimSpace(top.String()), files, nil
+}
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -17,6 +19,16 @@ import (
 	"github.com/davinci-std/kanvas"
 )
 
+const (
+	// DiffOutputText prints only the output of the diff commands
+	DiffOutputText = "text"
+	// DiffOutputJSON additionally prints the structured diff report in JSON
+	DiffOutputJSON = "json"
+)
+
+// DiffOutputs is the list of the supported output formats of Diff
+var DiffOutputs = []string{DiffOutputText, DiffOutputJSON}
+
 type App struct {
 	Config  Config
 	Runtime *kanvas.Runtime
@@ -68,11 +80,31 @@ func New(opts kanvas.Options) (*App, error) {
 }
 
 func (a *App) newWorkflow() (*kanvas.Workflow, error) {
-	return kanvas.NewWorkflow(a.Config.Component, a.Options)
+	wf, err := kanvas.NewWorkflow(a.Config.Component, a.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range wf.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+
+	return wf, nil
 }
 
-// Diff shows the diff between the desired state and the current state
-func (a *App) Diff() error {
+// Diff shows the diff between the desired state and the current state.
+// With DiffOutputJSON, it also prints the structured diff report to the standard output,
+// while the output of the diff commands is written to the standard error as usual.
+// The running commands are interrupted when ctx is done.
+func (a *App) Diff(ctx context.Context, output string) error {
+	switch output {
+	case DiffOutputText:
+	case DiffOutputJSON:
+		a.Options.DiffReport = true
+	default:
+		return fmt.Errorf("unsupported output %q", output)
+	}
+
 	wf, err := a.newWorkflow()
 	if err != nil {
 		return err
@@ -80,11 +112,27 @@ func (a *App) Diff() error {
 
 	p := interpreter.New(wf, a.Runtime)
 
-	return p.Diff()
+	if output == DiffOutputText {
+		return p.Diff(ctx)
+	}
+
+	r, err := p.DiffReport(ctx)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling diff report: %w", err)
+	}
+	fmt.Printf("%s\n", data)
+
+	return nil
 }
 
-// Apply builds the container image(s) if any and runs terraform-apply command(s) to deploy changes
-func (a *App) Apply() error {
+// Apply builds the container image(s) if any and runs terraform-apply command(s) to deploy changes.
+// The running commands are interrupted when ctx is done.
+func (a *App) Apply(ctx context.Context) error {
 	wf, err := a.newWorkflow()
 	if err != nil {
 		return err
@@ -92,7 +140,7 @@ func (a *App) Apply() error {
 
 	p := interpreter.New(wf, a.Runtime)
 
-	return p.Apply()
+	return p.Apply(ctx)
 }
 
 func (a *App) Export(format, dir, kanvasContainerImage string) error {
@@ -113,6 +161,9 @@ type RenderConfig struct {
 	// In case you want to push to a different branch than the current branch,
 	// you must run `git checkout -b <branch>` before running `kanvas render`.
 	Push bool
+	// Validate is a flag to check the rendered config against the schema printed by `kanvas schema`.
+	// If true, nothing is written when the rendered config does not conform to the schema.
+	Validate bool
 }
 
 type RenderOption func(*RenderConfig)
@@ -121,6 +172,22 @@ type RenderOption func(*RenderConfig)
 // directory.
 // If dir is empty, the rendered file is written to the current directory.
 func (a *App) Render(dir string, opts ...RenderOption) error {
+	var cfg RenderConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	if cfg.Validate {
+		var rendered interface{}
+		if err := yaml.Unmarshal(a.Config.Raw, &rendered); err != nil {
+			return fmt.Errorf("unable to decode the rendered config: %w", err)
+		}
+
+		if problems := kanvas.Schema().Validate(rendered); len(problems) > 0 {
+			return fmt.Errorf("the rendered config does not conform to the schema:\n\t%s", strings.Join(problems, "\n\t"))
+		}
+	}
+
 	path := filepath.Base(a.Config.Path)
 
 	const ext = ".template.jsonnet"
@@ -140,11 +207,6 @@ func (a *App) Render(dir string, opts ...RenderOption) error {
 		return fmt.Errorf("unable to write to %s: %w", path, err)
 	}
 
-	var cfg RenderConfig
-	for _, o := range opts {
-		o(&cfg)
-	}
-
 	if cfg.Push {
 		gitAdd := fmt.Sprintf("git add %s", path)
 		gitCommit := fmt.Sprintf("git commit -m 'Render %s'", path)
@@ -178,7 +240,7 @@ func (a *App) runCommands(commands ...string) error {
 	return nil
 }
 
-func (a *App) Output(format, op, target string) error {
+func (a *App) Output(format, op, target string, args []string) error {
 	wf, err := a.newWorkflow()
 	if err != nil {
 		return err
@@ -196,5 +258,5 @@ func (a *App) Output(format, op, target string) error {
 		return fmt.Errorf("unsupported op %q", op)
 	}
 
-	return e.Output(o, format, target)
+	return e.Output(o, format, target, args)
 }
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/davinci-std/kanvas/interpreter"
+
+	"golang.org/x/term"
+)
+
+// Destroy destroys what the components deployed, in the reverse order of apply.
+// It asks for confirmation before destroying anything, unless yes is true.
+// The running commands are interrupted when ctx is done.
+func (a *App) Destroy(ctx context.Context, yes bool) error {
+	wf, err := a.newWorkflow()
+	if err != nil {
+		return err
+	}
+
+	p := interpreter.New(wf, a.Runtime)
+
+	ids := p.DestroyOrder()
+	if len(ids) == 0 {
+		fmt.Fprintf(os.Stderr, "No component to destroy\n")
+		return nil
+	}
+
+	if !yes {
+		if err := confirmDestroy(a.Options.Env, ids); err != nil {
+			return err
+		}
+	}
+
+	return p.Destroy(ctx)
+}
+
+// confirmDestroy asks the user to confirm destroying the components.
+// Only "yes" is accepted, like terraform destroy does, because destroying is irreversible.
+func confirmDestroy(env string, ids []string) error {
+	target := "the components"
+	if env != "" {
+		target = fmt.Sprintf("the components in environment %q", env)
+	}
+
+	fmt.Fprintf(os.Stderr, "Destroying %s in this order:\n", target)
+	for _, id := range ids {
+		fmt.Fprintf(os.Stderr, "  %s\n", id)
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("destroy is not confirmed: rerun with --yes to destroy without confirmation")
+	}
+
+	fmt.Fprintf(os.Stderr, "Do you really want to destroy %s? Only 'yes' will be accepted: ", target)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("reading answer: %w", err)
+	}
+
+	if strings.TrimSpace(answer) != "yes" {
+		return fmt.Errorf("destroy is cancelled")
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/davinci-std/kanvas/interpreter"
+)
+
+const (
+	// DriftOutputJSON prints the drift report in JSON
+	DriftOutputJSON = "json"
+	// DriftOutputText prints the drift status of the components in a table
+	DriftOutputText = "text"
+)
+
+// DriftOutputs is the list of the supported output formats of Drift
+var DriftOutputs = []string{DriftOutputJSON, DriftOutputText}
+
+const (
+	// ExitCodeError is the exit code of kanvas when it failed.
+	ExitCodeError = 1
+	// ExitCodeChanges is the exit code of `kanvas drift` when any of the components has changes.
+	// It is the same as the one of `terraform plan -detailed-exitcode`.
+	ExitCodeChanges = 2
+)
+
+// ExitError is the error that makes kanvas exit with the code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Drift prints the report of the changes applying the components would make, in the output format.
+//
+// It returns an ExitError with ExitCodeChanges when any of the components has changes,
+// or ExitCodeError when the drift of any of the components could not be detected.
+// The running commands are interrupted when ctx is done.
+func (a *App) Drift(ctx context.Context, output string) error {
+	switch output {
+	case DriftOutputJSON, DriftOutputText:
+	default:
+		return fmt.Errorf("unsupported output %q", output)
+	}
+
+	wf, err := a.newWorkflow()
+	if err != nil {
+		return err
+	}
+
+	p := interpreter.New(wf, a.Runtime)
+
+	r, err := p.Drift(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch output {
+	case DriftOutputJSON:
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling drift report: %w", err)
+		}
+		fmt.Printf("%s\n", data)
+	case DriftOutputText:
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "COMPONENT\tSTATUS\tERROR\n")
+		for _, c := range r.Components {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", c.ID, c.Status, c.Error)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	var drifted, failed []string
+	for _, c := range r.Components {
+		switch c.Status {
+		case interpreter.DriftChanges:
+			drifted = append(drifted, c.ID)
+		case interpreter.DriftError:
+			failed = append(failed, c.ID)
+		}
+	}
+
+	if len(failed) > 0 {
+		return &ExitError{Code: ExitCodeError, Err: fmt.Errorf("unable to detect the drift of component(s) %v", failed)}
+	}
+
+	if len(drifted) > 0 {
+		return &ExitError{Code: ExitCodeChanges, Err: fmt.Errorf("drift detected in component(s) %v", drifted)}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/davinci-std/kanvas"
+)
+
+const (
+	// ValidateOutputText prints the problems one per line, in the form of file:line:column: message
+	ValidateOutputText = "text"
+	// ValidateOutputJSON prints the problems in JSON
+	ValidateOutputJSON = "json"
+)
+
+// ValidateOutputs is the list of the supported output formats of Validate
+var ValidateOutputs = []string{ValidateOutputText, ValidateOutputJSON}
+
+// Validate statically checks the config for every environment and prints all the problems found, in the output format.
+//
+// It returns an error when any problem is found, so that kanvas exits non-zero in CI.
+func (a *App) Validate(output string) error {
+	switch output {
+	case ValidateOutputText, ValidateOutputJSON:
+	default:
+		return fmt.Errorf("unsupported output %q", output)
+	}
+
+	problems, err := kanvas.Validate(a.Config.Path, a.Config.Raw, a.Options)
+	if err != nil {
+		return fmt.Errorf("unable to validate %s: %w", a.Config.Path, err)
+	}
+
+	switch output {
+	case ValidateOutputJSON:
+		if problems == nil {
+			problems = []kanvas.Problem{}
+		}
+		data, err := json.MarshalIndent(problems, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling problems: %w", err)
+		}
+		fmt.Printf("%s\n", data)
+	case ValidateOutputText:
+		for _, p := range problems {
+			fmt.Println(p.String())
+		}
+	}
+
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("found 1 problem in %s", a.Config.Path)
+	default:
+		return fmt.Errorf("found %d problems in %s", len(problems), a.Config.Path)
+	}
+}
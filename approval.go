@@ -0,0 +1,61 @@
+package kanvas
+
+import "strings"
+
+// environmentApprovalGatePrefix is the prefix of the IDs of the environment approval gates,
+// so that an environment gate never shares the ID with a component gate.
+const environmentApprovalGatePrefix = "env:"
+
+// Approval is a manual approval gate.
+// When a component or an environment has an approval,
+// kanvas pauses the apply before the gated job(s),
+// shows the diff, and waits for the approval.
+type Approval struct {
+	// Environment is the name of the GitHub Actions environment
+	// that the gated jobs are bound to when exported to GitHub Actions.
+	// The protection rules of the environment, like required reviewers,
+	// act as the approval gate.
+	// If empty, this defaults to the environment name for an environment approval,
+	// and to the component ID for a component approval.
+	Environment string `yaml:"environment,omitempty"`
+}
+
+// ApprovalGate is an approval gate that the job needs to pass before running.
+type ApprovalGate struct {
+	// ID is the ID of the gate.
+	// It is the job ID like `/production/app` for a component approval,
+	// and the environment name prefixed with `env:` like `env:production` for an environment approval.
+	// Jobs that share the same gate are approved at once.
+	ID string
+	// Environment is the name of the GitHub Actions environment.
+	// See Approval.Environment.
+	Environment string
+}
+
+// Path returns the path of the gate relative to the approvals dir,
+// like `components/production/app` or `env/production`.
+// Component and environment gates are kept in separate dirs
+// so that their approval files never collide.
+func (g ApprovalGate) Path() string {
+	if env, ok := strings.CutPrefix(g.ID, environmentApprovalGatePrefix); ok {
+		return "env/" + env
+	}
+
+	return "components/" + strings.TrimPrefix(g.ID, "/")
+}
+
+func newApprovalGate(id string, a *Approval) ApprovalGate {
+	return ApprovalGate{
+		ID:          id,
+		Environment: a.Environment,
+	}
+}
+
+func newEnvironmentApprovalGate(env string, a *Approval) ApprovalGate {
+	g := newApprovalGate(environmentApprovalGatePrefix+env, a)
+	if g.Environment == "" {
+		g.Environment = env
+	}
+
+	return g
+}
@@ -87,7 +87,7 @@ func (c *Client) Apply(ctx context.Context, config, env string, opts client.Appl
 // config is the path to the configuration file, which looks like path/to/kanvas.yaml.
 // env is the name of the environment. The specified configuration needs to have the environment whose name is env.
 func (c *Client) Diff(ctx context.Context, config, env string, opts client.DiffOptions) (*client.DiffResult, error) {
-	out, err := c.run(ctx, config, env, &opts, "diff")
+	out, err := c.run(ctx, config, env, &opts, "diff", "--output", "json")
 	if err != nil {
 		return nil, err
 	}
@@ -101,8 +101,8 @@ func (c *Client) Diff(ctx context.Context, config, env string, opts client.DiffO
 	return &r, nil
 }
 
-func (c *Client) run(ctx context.Context, config, env string, opts clientif.Options, command string) (*bytes.Buffer, error) {
-	return run(ctx, config, env, opts, c.GetCommand(), command)
+func (c *Client) run(ctx context.Context, config, env string, opts clientif.Options, command ...string) (*bytes.Buffer, error) {
+	return run(ctx, config, env, opts, c.GetCommand(), command...)
 }
 
 func (c *Client) GetCommand() []string {
@@ -112,12 +112,13 @@ func (c *Client) GetCommand() []string {
 	return []string{"kanvas"}
 }
 
-func run(ctx context.Context, configPath, env string, opts clientif.Options, bin []string, command string) (*bytes.Buffer, error) {
+func run(ctx context.Context, configPath, env string, opts clientif.Options, bin []string, command ...string) (*bytes.Buffer, error) {
 	var a []string
 
 	configDir, configName := filepath.Split(configPath)
 
-	a = append(a, "--config", configName, "--env", env, command)
+	a = append(a, "--config", configName, "--env", env)
+	a = append(a, command...)
 
 	if opts.GetSkip() != nil {
 		a = append(a, "--skip", strings.Join(opts.GetSkip(), ","))
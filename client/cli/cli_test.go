@@ -27,7 +27,7 @@ func TestCLIApply(t *testing.T) {
 }
 
 func TestCLIDiff(t *testing.T) {
-	cmd, teardown := setupTestCommand(t, "--config", "kanvas.yaml", "--env", "dev", "diff")
+	cmd, teardown := setupTestCommand(t, "--config", "kanvas.yaml", "--env", "dev", "diff", "--output", "json")
 	defer teardown()
 
 	cli := New()
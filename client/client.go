@@ -132,6 +132,12 @@ type Output struct {
 	// You can expect any current and future kanvas provider
 	// that works with pull requests to produce the outputs for this field.
 	PullRequest *PullRequest `json:"-"`
+
+	// Values is all the outputs of the component, keyed by the output names.
+	//
+	// Each value is formatted as a string like kanvas passes it to the commands,
+	// that is, the numbers like "123", the bools like "true", and the lists and the maps as JSON.
+	Values map[string]string `json:"-"`
 }
 
 type PullRequest struct {
@@ -187,6 +193,10 @@ func (r *ApplyResult) UnmarshalJSON(b []byte) error {
 	for k, v := range m {
 		var o Output
 
+		if err := json.Unmarshal(v, &o.Values); err != nil {
+			return err
+		}
+
 		var pr PullRequest
 		if err := json.Unmarshal(v, &pr); err != nil {
 			return err
@@ -201,5 +211,77 @@ func (r *ApplyResult) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+const (
+	// DiffChanges is the status of the component that applying it would make changes.
+	DiffChanges = "changes"
+	// DiffNoChanges is the status of the component that applying it would make no changes.
+	DiffNoChanges = "no-changes"
+	// DiffUnknown is the status of the component whose diff cannot tell whether there are changes,
+	// like the kubernetes component deployed via gitops.
+	DiffUnknown = "unknown"
+)
+
+// DiffResult is the structured result of the diff command.
 type DiffResult struct {
+	// Env is the environment the diff is for.
+	Env string `json:"env"`
+	// Components is the diff of each component, sorted by the component ID.
+	// The components that have nothing to diff, like the git component, are not included.
+	Components []ComponentDiff `json:"components"`
+}
+
+// ComponentDiff is the structured result of the diff of a component.
+type ComponentDiff struct {
+	// ID is the ID of the component.
+	ID string `json:"id"`
+	// Status is either DiffChanges, DiffNoChanges, or DiffUnknown.
+	Status string `json:"status"`
+	// Terraform is the summary of the terraform plan, for terraform components.
+	Terraform *TerraformDiff `json:"terraform,omitempty"`
+	// Kubernetes is the changed resources, for kubernetes components.
+	Kubernetes *KubernetesDiff `json:"kubernetes,omitempty"`
+	// Docker tells whether the image would be rebuilt, for docker components.
+	Docker *DockerDiff `json:"docker,omitempty"`
+}
+
+// TerraformDiff is the numbers of the resources to add, change, and destroy.
+type TerraformDiff struct {
+	Add     int `json:"add"`
+	Change  int `json:"change"`
+	Destroy int `json:"destroy"`
+}
+
+// KubernetesDiff is the changed Kubernetes resources.
+type KubernetesDiff struct {
+	Resources []KubernetesResource `json:"resources"`
+}
+
+// KubernetesResource is a changed Kubernetes resource.
+type KubernetesResource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Action is either create, update, or delete.
+	Action string `json:"action"`
+}
+
+// DockerDiff tells whether the image would be rebuilt.
+type DockerDiff struct {
+	// Ref is the content-addressed reference of the image.
+	Ref string `json:"ref"`
+	// Rebuild is true when apply would build the image.
+	Rebuild bool `json:"rebuild"`
+}
+
+// HasChanges returns true when any of the components would be changed by the apply command.
+//
+// The components whose status is DiffUnknown are not considered changed.
+// Check the status of each component if you need to treat them differently.
+func (r *DiffResult) HasChanges() bool {
+	for _, c := range r.Components {
+		if c.Status == DiffChanges {
+			return true
+		}
+	}
+	return false
 }
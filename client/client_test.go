@@ -31,6 +31,34 @@ func TestApplyResultUnmarshal(t *testing.T) {
 					PullRequest: &PullRequest{
 						Number: "1",
 					},
+					Values: map[string]string{
+						"pullRequest.number": "1",
+					},
+				},
+			},
+		}
+		require.Equal(t, want, got)
+	})
+	t.Run("with outputs of any types", func(t *testing.T) {
+		var got ApplyResult
+		jsonBytes := []byte(`{
+			"infra": {
+				"replicas": "3",
+				"public": "true",
+				"subnets": "[\"subnet-1\",\"subnet-2\"]"
+			}
+		}`)
+		if err := got.UnmarshalJSON(jsonBytes); err != nil {
+			t.Fatal(err)
+		}
+		want := ApplyResult{
+			Outputs: map[string]Output{
+				"infra": {
+					Values: map[string]string{
+						"replicas": "3",
+						"public":   "true",
+						"subnets":  `["subnet-1","subnet-2"]`,
+					},
 				},
 			},
 		}
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"os"
 
 	"github.com/davinci-std/kanvas/cmd"
 )
 
 func main() {
-	if err := cmd.Root().Execute(); err != nil {
-		os.Exit(1)
+	ctx, stop := cmd.NotifyContext(context.Background())
+
+	err := cmd.Root().ExecuteContext(ctx)
+
+	stop()
+
+	if err != nil {
+		os.Exit(cmd.ExitCode(err))
 	}
 }
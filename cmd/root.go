@@ -3,7 +3,11 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/davinci-std/kanvas/plugin"
 
@@ -16,12 +20,13 @@ import (
 	kargotools "github.com/mumoshu/kargo/tools"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func Root() *cobra.Command {
 	var (
 		opts = kanvas.Options{
-			SkippedJobsOutputs: map[string]map[string]string{},
+			SkippedJobsOutputs: map[string]kanvas.Outputs{},
 		}
 	)
 
@@ -32,6 +37,7 @@ func Root() *cobra.Command {
 	}
 	cmd.PersistentFlags().StringVarP(&opts.Env, "env", "e", "", "The environment to deploy to")
 	cmd.PersistentFlags().StringVarP(&opts.ConfigFile, "config", "c", "", "The path to the config file that declares the deployment workflow")
+	cmd.PersistentFlags().BoolVar(&opts.Strict, "strict", false, "Fail on the problems in the config that are otherwise warned, like the components referenced via valueFrom but missing in needs")
 
 	new := &cobra.Command{
 		Use:   "new",
@@ -44,35 +50,140 @@ func Root() *cobra.Command {
 	new.Flags().BoolVarP(&opts.UseAI, "use-ai", "a", false, "Use AI to suggest a kanvas.yaml file content based on your environment")
 	cmd.AddCommand(new)
 
+	var diffOutput string
+
 	diff := &cobra.Command{
 		Use:   "diff",
 		Short: "Shows the diff between the desired state and the current state",
+		Long: `Shows the diff between the desired state and the current state.
+
+With --output json, the structured diff report of the components is printed to the standard output,
+including the numbers of terraform resources to add, change, and destroy,
+the changed Kubernetes resources, and whether the container images would be rebuilt.
+The output of the diff commands is printed to the standard error either way.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cmd.SilenceUsage = true
 			return run(cmd, opts, func(a *app.App) error {
-				return a.Diff()
+				return a.Diff(cmd.Context(), diffOutput)
 			})
 		},
 	}
+	diff.Flags().StringVarP(&diffOutput, "output", "o", app.DiffOutputText, fmt.Sprintf("Print the diff in this format. The supported values are %s", strings.Join(app.DiffOutputs, ", ")))
 	diff.Flags().StringSliceVar(&opts.Skip, "skip", nil, "Skip the specified component(s) when diffing changes")
 	diff.Flags().Var(&JSONFlag{&opts.SkippedJobsOutputs}, "skipped-jobs-outputs", "The outputs from the skipped jobs. Needed for the jobs that depend on the skipped jobs")
+	diff.Flags().IntVar(&opts.Parallelism, "parallelism", kanvas.DefaultParallelism, "The maximum number of components to diff concurrently. Set to 1 to diff components one by one")
+	diff.Flags().DurationVar(&opts.Timeout, "timeout", 0, "Interrupt the diff when it takes longer than this duration, like 1h. Zero means no timeout")
+	diff.Flags().BoolVar(&opts.Phased, "phased", false, "Diff components phase by phase, where each phase waits for all the components in the previous phase, for reproducibility")
+	diff.Flags().StringVar(&opts.PlanDir, "plan-dir", "", "Write terraform plan files to this directory so that apply with the same plan dir applies exactly the plans")
+	diff.Flags().StringSliceVar(&opts.Only, "only", nil, "Diff only the component(s) matching the specified glob pattern(s), like app or /product1/*. The outputs of the components they need are read without diffing them. Also available as --target")
+	diff.Flags().BoolVar(&opts.WithDeps, "with-deps", false, "Also diff the components needed by the components selected via --only, transitively")
+	diff.Flags().BoolVar(&opts.WithDependents, "with-dependents", false, "Also diff the components that need the components selected via --only, transitively")
+	diff.Flags().SetNormalizeFunc(targetAlias)
 	cmd.AddCommand(diff)
 
 	apply := &cobra.Command{
 		Use:   "apply",
 		Short: "Build the container image(s) if any and runs terraform-apply command(s) to deploy changes",
+		// A run ID given as `--resume ID` would otherwise be silently ignored,
+		// resuming the latest run instead.
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cmd.SilenceUsage = true
 			return run(cmd, opts, func(a *app.App) error {
-				return a.Apply()
+				return a.Apply(cmd.Context())
 			})
 		},
 	}
 	apply.Flags().BoolVar(&opts.LogsFollow, "logs-follow", false, "Follow log output from the components")
 	apply.Flags().StringSliceVar(&opts.Skip, "skip", nil, "Skip the specified component(s) when applying changes")
+	apply.Flags().StringSliceVar(&opts.Approve, "approve", nil, "Approve the specified approval gate(s) in advance. Each gate is either a component ID or an environment name")
 	apply.Flags().Var(&JSONFlag{&opts.SkippedJobsOutputs}, "skipped-jobs-outputs", "The outputs from the skipped jobs. Needed for the jobs that depend on the skipped jobs")
+	apply.Flags().IntVar(&opts.Parallelism, "parallelism", kanvas.DefaultParallelism, "The maximum number of components to apply concurrently. Set to 1 to apply components one by one")
+	apply.Flags().DurationVar(&opts.Timeout, "timeout", 0, "Interrupt the apply when it takes longer than this duration, like 1h. Zero means no timeout")
+	apply.Flags().BoolVar(&opts.Phased, "phased", false, "Apply components phase by phase, where each phase waits for all the components in the previous phase, for reproducibility")
+	apply.Flags().StringVar(&opts.PlanDir, "plan-dir", "", "Apply the terraform plan files in this directory written by diff. Fails if any plan file is missing or stale")
+	apply.Flags().BoolVar(&opts.Force, "force", false, "Apply all the components, even the ones whose dir, config, and inputs have not changed since their last successful apply")
+	apply.Flags().StringVar(&opts.Resume, "resume", "", "Resume the run with the specified ID like --resume=ID, or the latest run if no ID is given, skipping the components that succeeded in the run")
+	apply.Flags().Lookup("resume").NoOptDefVal = kanvas.ResumeLatest
+	apply.Flags().StringSliceVar(&opts.Only, "only", nil, "Apply only the component(s) matching the specified glob pattern(s), like app or /product1/*. The outputs of the components they need are read without applying them. Also available as --target")
+	apply.Flags().BoolVar(&opts.WithDeps, "with-deps", false, "Also apply the components needed by the components selected via --only, transitively")
+	apply.Flags().BoolVar(&opts.WithDependents, "with-dependents", false, "Also apply the components that need the components selected via --only, transitively")
+	apply.Flags().SetNormalizeFunc(targetAlias)
 	cmd.AddCommand(apply)
 
+	{
+		var yes bool
+
+		destroy := &cobra.Command{
+			Use:   "destroy",
+			Short: "Destroys what the components deployed, in the reverse order of apply",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cmd.SilenceUsage = true
+				return run(cmd, opts, func(a *app.App) error {
+					return a.Destroy(cmd.Context(), yes)
+				})
+			},
+		}
+		destroy.Flags().BoolVarP(&yes, "yes", "y", false, "Destroy without asking for confirmation")
+		destroy.Flags().StringSliceVar(&opts.Skip, "skip", nil, "Skip the specified component(s) when destroying")
+		destroy.Flags().Var(&JSONFlag{&opts.SkippedJobsOutputs}, "skipped-jobs-outputs", "The outputs from the skipped jobs. Needed for the jobs that depend on the skipped jobs")
+		destroy.Flags().IntVar(&opts.Parallelism, "parallelism", kanvas.DefaultParallelism, "The maximum number of components to destroy concurrently. Set to 1 to destroy components one by one")
+		destroy.Flags().DurationVar(&opts.Timeout, "timeout", 0, "Interrupt the destroy when it takes longer than this duration, like 1h. Zero means no timeout")
+		destroy.Flags().BoolVar(&opts.Phased, "phased", false, "Destroy components phase by phase in the reverse order, where each phase waits for all the components in the previous phase, for reproducibility")
+		destroy.Flags().StringSliceVar(&opts.Only, "only", nil, "Destroy only the component(s) matching the specified glob pattern(s), like app or /product1/*. The outputs of the components they need are read without destroying them. Also available as --target")
+		destroy.Flags().BoolVar(&opts.WithDependents, "with-dependents", false, "Also destroy the components that need the components selected via --only, transitively")
+		destroy.Flags().SetNormalizeFunc(targetAlias)
+		cmd.AddCommand(destroy)
+	}
+
+	{
+		var output string
+
+		drift := &cobra.Command{
+			Use:   "drift",
+			Short: "Detects the changes applying the components would make, without changing anything",
+			Long: `Detects the changes applying the components would make, without changing anything.
+
+It exits with 0 when no component has changes, 2 when any of the components has changes,
+and 1 when the drift of any of the components could not be detected.`,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cmd.SilenceUsage = true
+				return run(cmd, opts, func(a *app.App) error {
+					return a.Drift(cmd.Context(), output)
+				})
+			},
+		}
+		drift.Flags().StringVarP(&output, "output", "o", app.DriftOutputJSON, fmt.Sprintf("Print the drift report in this format. The supported values are %s", strings.Join(app.DriftOutputs, ", ")))
+		drift.Flags().StringSliceVar(&opts.Skip, "skip", nil, "Skip the specified component(s) when detecting drift")
+		drift.Flags().Var(&JSONFlag{&opts.SkippedJobsOutputs}, "skipped-jobs-outputs", "The outputs from the skipped jobs. Needed for the jobs that depend on the skipped jobs")
+		drift.Flags().IntVar(&opts.Parallelism, "parallelism", kanvas.DefaultParallelism, "The maximum number of components to check concurrently. Set to 1 to check components one by one")
+		drift.Flags().DurationVar(&opts.Timeout, "timeout", 0, "Interrupt the drift detection when it takes longer than this duration, like 1h. Zero means no timeout")
+		drift.Flags().StringSliceVar(&opts.Only, "only", nil, "Check only the component(s) matching the specified glob pattern(s), like app or /product1/*. Also available as --target")
+		drift.Flags().SetNormalizeFunc(targetAlias)
+		cmd.AddCommand(drift)
+	}
+
+	{
+		var output string
+
+		validate := &cobra.Command{
+			Use:   "validate",
+			Short: "Statically checks the config for every environment and reports all the problems at once",
+			Long: `Statically checks the config for every environment and reports all the problems at once,
+with the file and line positions, without running any command.
+
+It exits with 0 when no problem is found, and 1 otherwise.`,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cmd.SilenceUsage = true
+				return run(cmd, opts, func(a *app.App) error {
+					return a.Validate(output)
+				})
+			},
+		}
+		validate.Flags().StringVarP(&output, "output", "o", app.ValidateOutputText, fmt.Sprintf("Print the problems in this format. The supported values are %s", strings.Join(app.ValidateOutputs, ", ")))
+		cmd.AddCommand(validate)
+	}
+
 	{
 		var (
 			exportDir            string
@@ -89,15 +200,39 @@ func Root() *cobra.Command {
 				})
 			},
 		}
-		export.Flags().StringVarP(&format, "format", "f", plugin.FormatDefault, fmt.Sprintf("Export workflows in this format. The only supported value is %q", plugin.FormatGitHubActions))
+		export.Flags().StringVarP(&format, "format", "f", plugin.FormatDefault, fmt.Sprintf("Export workflows in this format. The supported values are %s", strings.Join(plugin.Formats, ", ")))
 		export.Flags().StringVarP(&exportDir, "dir", "d", "", "Writes the exported workflow definitions to this directory")
 		export.Flags().StringVarP(&kanvasContainerImage, "kanvas-container-image", "i", "kanvas:example", "Use this image for running kanvas-related commands within GitHub Actions workflow job(s)")
+		export.Flags().StringVar(&opts.PlanDir, "plan-dir", "", "Make the exported workflows write terraform plan files to this directory and pass them from the plan to the apply workflow as artifacts")
+		export.Flags().BoolVar(&opts.AffectedOnly, "affected-only", false, "Make the exported workflows run the commands of only the components affected by the changes, as computed by kanvas affected")
+		export.Flags().BoolVar(&opts.Teardown, "teardown", false, "Also export the workflow that destroys the components in the reverse order when a pull request is closed")
 		cmd.AddCommand(export)
 	}
 
 	{
 		var (
-			renderDir string
+			base   string
+			output string
+		)
+		affected := &cobra.Command{
+			Use:   "affected",
+			Short: "Shows the components affected by the changes since the git base ref, including their dependents",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return run(cmd, opts, func(a *app.App) error {
+					cmd.SilenceUsage = true
+					return a.Affected(base, output)
+				})
+			},
+		}
+		affected.Flags().StringVar(&base, "base", "", "The git ref to compare HEAD against, like origin/main. If empty, all the components are affected")
+		affected.Flags().StringVarP(&output, "output", "o", app.AffectedOutputText, fmt.Sprintf("Print the affected components in this format. The supported values are %s", strings.Join(app.AffectedOutputs, ", ")))
+		cmd.AddCommand(affected)
+	}
+
+	{
+		var (
+			renderDir      string
+			renderValidate bool
 		)
 		render := &cobra.Command{
 			Use:   "render",
@@ -105,14 +240,39 @@ func Root() *cobra.Command {
 			RunE: func(cmd *cobra.Command, args []string) error {
 				return run(cmd, opts, func(a *app.App) error {
 					cmd.SilenceUsage = true
-					return a.Render(renderDir)
+					return a.Render(renderDir, func(c *app.RenderConfig) {
+						c.Validate = renderValidate
+					})
 				})
 			},
 		}
 		render.Flags().StringVarP(&renderDir, "dir", "d", "", "Writes the rendered kanvas.yaml to this directory")
+		render.Flags().BoolVar(&renderValidate, "validate", false, "Checks the rendered config against the schema printed by kanvas schema, and writes nothing when it does not conform")
 		cmd.AddCommand(render)
 	}
 
+	{
+		schema := &cobra.Command{
+			Use:   "schema",
+			Short: "Prints the JSON Schema of the config, for editors and linters",
+			Long: `Prints the JSON Schema of the config, for editors and linters.
+
+The schema covers both kanvas.yaml and the config rendered from kanvas.jsonnet.`,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cmd.SilenceUsage = true
+
+				data, err := json.MarshalIndent(kanvas.Schema(), "", "  ")
+				if err != nil {
+					return fmt.Errorf("marshaling schema: %w", err)
+				}
+				fmt.Printf("%s\n", data)
+
+				return nil
+			},
+		}
+		cmd.AddCommand(schema)
+	}
+
 	{
 		var (
 			target string
@@ -120,16 +280,20 @@ func Root() *cobra.Command {
 			format string
 		)
 		output := &cobra.Command{
-			Use:   "output",
+			Use:   "output [-- ARGS...]",
 			Short: "Writes or saves the outputs from the specified job",
+			Long: `Writes or saves the outputs from the specified job.
+
+The args after -- are the values the job needs from the outputs of the other jobs,
+like the build args from argsFrom of a docker component, resolved by the exported workflow.`,
 			RunE: func(cmd *cobra.Command, args []string) error {
 				return run(cmd, opts, func(a *app.App) error {
-					return a.Output(format, op, target)
+					return a.Output(format, op, target, args)
 				})
 			},
 		}
 		output.Flags().StringVarP(&op, "op", "o", "", "Either diff or apply")
-		output.Flags().StringVarP(&format, "format", "f", plugin.FormatDefault, fmt.Sprintf("Write outputs in this format. The only supported value is %q", plugin.FormatGitHubActions))
+		output.Flags().StringVarP(&format, "format", "f", plugin.FormatDefault, fmt.Sprintf("Write outputs in this format. The supported values are %s", strings.Join(plugin.Formats, ", ")))
 		output.Flags().StringVarP(&target, "target", "t", "", "Targeted job's name for collecting and writings outputs")
 		cmd.AddCommand(output)
 	}
@@ -175,6 +339,59 @@ func Root() *cobra.Command {
 
 		tools.AddCommand(createPullRequest)
 
+		var (
+			plan   string
+			verify bool
+		)
+
+		terraformPlanFingerprint := &cobra.Command{
+			Use:   kanvas.CommandTerraformPlanFingerprint + " -- [terraform plan args]",
+			Short: "Writes or verifies the fingerprint of the terraform plan file",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cmd.SilenceUsage = true
+				if verify {
+					return kanvas.VerifyTerraformPlanFingerprint(".", plan, args)
+				}
+				return kanvas.WriteTerraformPlanFingerprint(".", plan, args)
+			},
+		}
+		terraformPlanFingerprint.Flags().StringVar(&plan, kanvas.FlagTerraformPlanFingerprintPlan, "", "The path to the terraform plan file")
+		terraformPlanFingerprint.Flags().BoolVar(&verify, kanvas.FlagTerraformPlanFingerprintVerify, false, "Verify that the plan file exists and is not stale, instead of writing the fingerprint")
+
+		tools.AddCommand(terraformPlanFingerprint)
+
+		var retry kanvas.Retry
+
+		retryCmd := &cobra.Command{
+			Use:   kanvas.CommandRetry + " -- command [args]",
+			Short: "Runs the command and retries it on failure",
+			Args:  cobra.MinimumNArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cmd.SilenceUsage = true
+
+				policy, err := retry.Policy()
+				if err != nil {
+					return err
+				}
+
+				r := kanvas.NewRuntime()
+
+				_, err = policy.Do(cmd.Context(), func(attempt int) error {
+					return r.ExecContext(cmd.Context(), ".", args)
+				}, func(attempt int, err error, delay time.Duration) {
+					fmt.Fprintf(os.Stderr, "Attempt %d/%d failed: %v\nRetrying in %s\n", attempt, policy.Attempts, err, delay.Round(time.Millisecond))
+				})
+
+				return err
+			},
+		}
+		retryCmd.Flags().IntVar(&retry.Attempts, kanvas.FlagRetryAttempts, 0, "The maximum number of attempts, including the first one. Defaults to 3")
+		retryCmd.Flags().StringVar(&retry.Backoff, kanvas.FlagRetryBackoff, "", "The duration to wait before the second attempt, which doubles for each subsequent attempt. Defaults to 10s")
+		retryCmd.Flags().Float64Var(&retry.Jitter, kanvas.FlagRetryJitter, 0, "The maximum fraction of the backoff added at random, between 0 and 1")
+		retryCmd.Flags().StringArrayVar(&retry.OnStderr, kanvas.FlagRetryOnStderr, nil, "Retry only when the standard error of the command matches any of the regular expressions")
+
+		tools.AddCommand(retryCmd)
+
 		cmd.AddCommand(tools)
 	}
 
@@ -190,3 +407,25 @@ func run(cmd *cobra.Command, opts kanvas.Options, do func(*app.App) error) error
 
 	return do(app)
 }
+
+// ExitCode returns the exit code of kanvas for the error returned by the command.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var e *app.ExitError
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	return app.ExitCodeError
+}
+
+// targetAlias makes --target an alias of --only.
+func targetAlias(f *pflag.FlagSet, name string) pflag.NormalizedName {
+	if name == "target" {
+		name = "only"
+	}
+	return pflag.NormalizedName(name)
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/davinci-std/kanvas"
+)
+
+// NotifyContext returns a context that is cancelled when kanvas receives SIGINT or SIGTERM.
+// The cause of the cancellation is a kanvas.InterruptError, so that
+// the same signal is forwarded to the commands being run.
+//
+// Receiving the signal again makes kanvas exit immediately,
+// without waiting for the commands to exit.
+func NotifyContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		var sig os.Signal
+		select {
+		case sig = <-sigs:
+		case <-ctx.Done():
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "Received %s. Waiting for the running commands to exit. Send it again to exit immediately\n", sig)
+		cancel(&kanvas.InterruptError{Signal: sig})
+
+		sig = <-sigs
+
+		code := 1
+		if s, ok := sig.(syscall.Signal); ok {
+			code = 128 + int(s)
+		}
+		os.Exit(code)
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancel(context.Canceled)
+	}
+}
@@ -0,0 +1,131 @@
+package kanvas
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/davinci-std/kanvas/expr"
+	"github.com/mumoshu/kargo"
+)
+
+// exprs returns the expressions within the dynamic args of the tasks of the driver,
+// like `infra.vpc_id` for `valueFrom: infra.vpc_id`, in the order of appearance.
+func (d *Driver) exprs() ([]string, error) {
+	var exprs []string
+
+	collect := func(a *kargo.Args) error {
+		// The args are collected rather than visited,
+		// so that the references within the scripts like the one for argocd are found too.
+		_, err := a.Collect(func(out string) (string, error) {
+			exprs = append(exprs, out)
+			return "", nil
+		})
+		return err
+	}
+
+	for _, tasks := range [][]Task{d.Diff, d.Apply, d.Destroy, d.Drift} {
+		for _, t := range tasks {
+			if err := collect(t.Args); err != nil {
+				return nil, err
+			}
+			for _, c := range t.Run {
+				if err := collect(c.Args); err != nil {
+					return nil, fmt.Errorf("command %q: %w", c.Name, err)
+				}
+			}
+		}
+	}
+
+	return exprs, nil
+}
+
+// refs returns the components whose outputs are referenced by the dynamic args of the tasks of the driver,
+// like `infra` for `valueFrom: infra.vpc_id`, sorted and deduplicated.
+// The components are named as they are referenced, relative to the parent of the job.
+func (d *Driver) refs() ([]string, error) {
+	exprs, err := d.exprs()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]struct{}{}
+	for _, e := range exprs {
+		refs, err := expr.ParseRefs(e)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range refs {
+			seen[r.Component] = struct{}{}
+		}
+	}
+
+	refs := make([]string, 0, len(seen))
+	for r := range seen {
+		refs = append(refs, r)
+	}
+	sort.Strings(refs)
+
+	return refs, nil
+}
+
+// inferNeeds adds the components whose outputs are referenced by the driver of the job to needs,
+// so that the components run before the job without being listed in the needs of the job.
+//
+// It warns about the components referenced but missing in the needs,
+// and the ones listed in the needs although they are inferred from the references.
+// In strict mode, it fails instead.
+func (wf *Workflow) inferNeeds(id string, needs []string, d *Driver) ([]string, error) {
+	refs, err := d.refs()
+	if err != nil {
+		return nil, err
+	}
+
+	explicit := make(map[string]struct{}, len(needs))
+	for _, n := range needs {
+		explicit[n] = struct{}{}
+	}
+
+	var missing, redundant []string
+	for _, r := range refs {
+		ref := SiblingID(id, r)
+		if ref == id {
+			// The job may refer to its own outputs, like the tag of the image to be built.
+			continue
+		}
+
+		if _, ok := explicit[ref]; ok {
+			redundant = append(redundant, ref)
+		} else {
+			missing = append(missing, ref)
+		}
+	}
+
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, fmt.Sprintf("it refers to the outputs of %s but does not list them in needs", strings.Join(missing, ", ")))
+	}
+	if len(redundant) > 0 {
+		problems = append(problems, fmt.Sprintf("needs %s, which can be omitted because they are inferred from the references to their outputs", strings.Join(redundant, ", ")))
+	}
+
+	for _, p := range problems {
+		if wf.Options.Strict {
+			return nil, fmt.Errorf("%s", p)
+		}
+		wf.Warnings = append(wf.Warnings, fmt.Sprintf("component %q: %s", id, p))
+	}
+
+	for _, m := range missing {
+		needs = append(needs, m)
+
+		if filepath.Base(m) == gitJob {
+			// Like the explicit needs, the git job is managed by the topological sorter
+			// only when any of the components needs it.
+			wf.deps[m] = []string{}
+		}
+	}
+
+	return needs, nil
+}
@@ -0,0 +1,138 @@
+package kanvas
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mumoshu/kargo"
+	"github.com/mumoshu/kargo/cmd"
+)
+
+// kubernetesDestroy returns the tasks to uninstall the application deployed by kargo.
+//
+// kargo generates the commands to plan and apply only,
+// so we generate the commands to uninstall the application here,
+// following the same precedence of the deployment methods as kargo.
+// The application deployed via gitops cannot be uninstalled,
+// because there is nothing kanvas can undo other than reverting the pull request.
+func kubernetesDestroy(id string, kc *kargo.Config, tempDir string) []Task {
+	switch {
+	case kc.ArgoCD != nil:
+		return []Task{cmdToTask(argocdAppDelete(kc))}
+	case kc.Compose != nil:
+		dir, file := composeFile(kc.Path)
+		return []Task{
+			Cmd("docker-compose-down", "docker", cmd.Args("compose", "-f", file, "down"), cmd.Dir(dir)),
+		}
+	case kc.Helm != nil:
+		return []Task{
+			Cmd("helm-uninstall", "helm", cmd.Args("uninstall", kc.Name), cmd.Dir(kc.Path)),
+		}
+	case kc.Kustomize != nil:
+		strategy := kc.Kustomize.Strategy
+		if strategy == kargo.KustomizeStrategySetImageAndCreatePR || kc.Kustomize.Git.Repo != "" {
+			return unsupportedDestroy(id, fmt.Sprintf("the manifests pushed to %s", kc.Kustomize.Git.Repo))
+		}
+
+		// We don't need to set the images before building,
+		// because the resources are deleted by their names.
+		built := filepath.Join(tempDir, "kustomize-built.yaml")
+		return []Task{
+			Cmd("kustomize-build", "kustomize", cmd.Args("build", "--output="+built), cmd.Dir(kc.Path)),
+			Cmd("kubectl-delete", "kubectl", cmd.Args("delete", "-f", built, "--ignore-not-found=true")),
+		}
+	case kc.Kompose != nil:
+		dir, file := composeFile(kc.Path)
+		// The secret references are left unresolved even when vals is enabled,
+		// because the resources are deleted by their names.
+		script := fmt.Sprintf("kompose convert --stdout -f %s | kubectl delete --ignore-not-found=true -f -", file)
+		return []Task{
+			Cmd("kompose-kubectl-delete", "bash", cmd.Args("-c", script), cmd.Dir(dir)),
+		}
+	default:
+		return unsupportedDestroy(id, "the application")
+	}
+}
+
+// argocdAppDelete returns the command to delete the ArgoCD application,
+// along with the Kubernetes resources managed by the application.
+func argocdAppDelete(kc *kargo.Config) kargo.Cmd {
+	return argocdApp("argocd-app-delete", kc, "delete", kc.Name, "--yes")
+}
+
+// argocdApp returns the command to run `argocd app` with the args against the ArgoCD server of the application.
+// It logs in to the ArgoCD server like the commands generated by kargo for apply.
+func argocdApp(id string, kc *kargo.Config, appArgs ...string) kargo.Cmd {
+	var (
+		args      *kargo.Args
+		loginArgs *kargo.Args
+	)
+
+	a := kc.ArgoCD
+
+	if a.Server != "" {
+		args = args.AppendStrings("--server", a.Server)
+		loginArgs = loginArgs.AppendStrings(a.Server)
+	} else if a.ServerFrom != "" {
+		args = args.AppendStrings("--server")
+		args = args.AppendValueFromOutput(a.ServerFrom)
+		loginArgs = loginArgs.AppendValueFromOutput(a.ServerFrom)
+	}
+
+	if a.Username != "" {
+		loginArgs = loginArgs.AppendStrings("--username", a.Username)
+	} else if a.UsernameFrom != "" {
+		loginArgs = loginArgs.AppendStrings("--username")
+		loginArgs = loginArgs.AppendValueFromOutput(a.UsernameFrom)
+	}
+
+	if a.Password != "" {
+		loginArgs = loginArgs.AppendStrings("--password", a.Password)
+	} else if a.PasswordFrom != "" {
+		loginArgs = loginArgs.AppendStrings("--password")
+		loginArgs = loginArgs.AppendValueFromOutput(a.PasswordFrom)
+	}
+
+	if a.Insecure {
+		args = args.AppendStrings("--insecure")
+		loginArgs = loginArgs.AppendStrings("--insecure")
+	} else if a.InsecureFrom != "" {
+		args = args.AppendValueIfOutput("--insecure", a.InsecureFrom)
+		loginArgs = loginArgs.AppendValueIfOutput("--insecure", a.InsecureFrom)
+	}
+
+	var script *kargo.Args
+	script = script.Append("argocd", "login", loginArgs, ";")
+	script = script.Append("argocd", "app")
+	script = script.AppendStrings(appArgs...)
+	script = script.Append(args)
+
+	return kargo.Cmd{
+		ID:   id,
+		Name: "bash",
+		Args: kargo.NewArgs("-vxc", kargo.NewBashScript(script)),
+	}
+}
+
+// composeFile returns the dir and the name of the docker-compose file at path,
+// which is either the file or the dir containing docker-compose.yml, like kargo does.
+func composeFile(path string) (string, string) {
+	if strings.HasSuffix(path, ".yml") {
+		return filepath.Dir(path), filepath.Base(path)
+	}
+
+	return path, "docker-compose.yml"
+}
+
+// unsupportedDestroy returns the task that fails destroying the component,
+// so that the component is never reported as destroyed while what it deployed is left behind.
+func unsupportedDestroy(id, what string) []Task {
+	return []Task{
+		{
+			Func: func(_ *WorkflowJob, _ Outputs) error {
+				return fmt.Errorf("destroying component %q is not supported yet. Delete %s manually", id, what)
+			},
+		},
+	}
+}
@@ -0,0 +1,70 @@
+package kanvas
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mumoshu/kargo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKubernetesDestroy(t *testing.T) {
+	commands := func(tasks []Task) []string {
+		var cmds []string
+		for _, task := range tasks {
+			for _, c := range task.Run {
+				args, err := c.Args.Collect(func(out string) (string, error) {
+					return "$" + out, nil
+				})
+				require.NoError(t, err)
+				cmds = append(cmds, c.Dir+": "+c.Name+" "+strings.Join(args, " "))
+			}
+		}
+		return cmds
+	}
+
+	require.Equal(t,
+		[]string{"/app: helm uninstall app"},
+		commands(kubernetesDestroy("app", &kargo.Config{Name: "app", Path: "/app", Helm: &kargo.Helm{}}, "/tmp")),
+	)
+
+	require.Equal(t,
+		[]string{"/app: docker compose -f compose.yml down"},
+		commands(kubernetesDestroy("app", &kargo.Config{Name: "app", Path: "/app/compose.yml", Compose: &kargo.Compose{}}, "/tmp")),
+	)
+
+	require.Equal(t,
+		[]string{
+			"/app: kustomize build --output=/tmp/kustomize-built.yaml",
+			": kubectl delete -f /tmp/kustomize-built.yaml --ignore-not-found=true",
+		},
+		commands(kubernetesDestroy("app", &kargo.Config{Name: "app", Path: "/app", Kustomize: &kargo.Kustomize{}}, "/tmp")),
+	)
+
+	require.Equal(t,
+		[]string{
+			": bash -vxc argocd login $infra.server --username admin --insecure ; argocd app delete app --yes --server $infra.server --insecure",
+		},
+		commands(kubernetesDestroy("app", &kargo.Config{
+			Name: "app",
+			Path: "/app",
+			Helm: &kargo.Helm{},
+			ArgoCD: &kargo.ArgoCD{
+				ServerFrom: "infra.server",
+				Username:   "admin",
+				Insecure:   true,
+			},
+		}, "/tmp")),
+	)
+
+	gitops := kubernetesDestroy("app", &kargo.Config{
+		Name: "app",
+		Path: "/app",
+		Kustomize: &kargo.Kustomize{
+			Strategy: kargo.KustomizeStrategySetImageAndCreatePR,
+			Git:      kargo.KustomizeGit{Repo: "myorg/gitops"},
+		},
+	}, "/tmp")
+	require.Len(t, gitops, 1)
+	require.EqualError(t, gitops[0].Func(nil, nil), `destroying component "app" is not supported yet. Delete the manifests pushed to myorg/gitops manually`)
+}
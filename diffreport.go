@@ -0,0 +1,246 @@
+package kanvas
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/mumoshu/kargo"
+)
+
+const (
+	// DiffChanges is the status of the component that applying it would make changes.
+	DiffChanges = "changes"
+	// DiffNoChanges is the status of the component that applying it would make no changes.
+	DiffNoChanges = "no-changes"
+	// DiffUnknown is the status of the component whose diff cannot tell whether there are changes,
+	// like the kubernetes component deployed via gitops.
+	DiffUnknown = "unknown"
+)
+
+const (
+	// ResourceCreate is the action to the resource that does not exist yet.
+	ResourceCreate = "create"
+	// ResourceUpdate is the action to the existing resource.
+	ResourceUpdate = "update"
+	// ResourceDelete is the action to the resource that is no longer desired.
+	ResourceDelete = "delete"
+)
+
+// DiffReport is the structured result of the diff of the workflow.
+type DiffReport struct {
+	// Env is the environment the diff is for.
+	Env string `json:"env"`
+	// Components is the diff of each component, sorted by the component ID.
+	Components []ComponentDiff `json:"components"`
+}
+
+// ComponentDiff is the structured result of the diff of a component.
+type ComponentDiff struct {
+	// ID is the ID of the component.
+	ID string `json:"id"`
+	// Status is either DiffChanges, DiffNoChanges, or DiffUnknown.
+	Status string `json:"status"`
+	// Terraform is the summary of the terraform plan, for terraform components.
+	Terraform *TerraformDiff `json:"terraform,omitempty"`
+	// Kubernetes is the changed resources, for kubernetes components.
+	Kubernetes *KubernetesDiff `json:"kubernetes,omitempty"`
+	// Docker tells whether the image would be rebuilt, for docker components.
+	Docker *DockerDiff `json:"docker,omitempty"`
+}
+
+// TerraformDiff is the summary of the terraform plan,
+// counted like `terraform plan` does in "Plan: 1 to add, 0 to change, 0 to destroy".
+type TerraformDiff struct {
+	Add     int `json:"add"`
+	Change  int `json:"change"`
+	Destroy int `json:"destroy"`
+}
+
+// KubernetesDiff is the changed Kubernetes resources.
+type KubernetesDiff struct {
+	Resources []KubernetesResource `json:"resources"`
+}
+
+// KubernetesResource is a changed Kubernetes resource.
+type KubernetesResource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Action is either ResourceCreate, ResourceUpdate, or ResourceDelete.
+	Action string `json:"action"`
+}
+
+// DockerDiff tells whether the image would be rebuilt.
+type DockerDiff struct {
+	// Ref is the content-addressed reference of the image.
+	Ref string `json:"ref"`
+	// Rebuild is true when apply would build and push the image,
+	// because the registry does not have the image yet.
+	Rebuild bool `json:"rebuild"`
+}
+
+// terraformPlanDiff returns the summary of the plan in the format of `terraform show -json`.
+func terraformPlanDiff(planJSON []byte) (*TerraformDiff, error) {
+	var plan struct {
+		ResourceChanges []struct {
+			Change struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+
+	if err := json.Unmarshal(planJSON, &plan); err != nil {
+		return nil, fmt.Errorf("unable to decode terraform plan: %w", err)
+	}
+
+	var d TerraformDiff
+	for _, rc := range plan.ResourceChanges {
+		// A replacement is either ["delete", "create"] or ["create", "delete"],
+		// and counted as one to add and one to destroy.
+		for _, a := range rc.Change.Actions {
+			switch a {
+			case "create":
+				d.Add++
+			case "update":
+				d.Change++
+			case "delete":
+				d.Destroy++
+			}
+		}
+	}
+
+	return &d, nil
+}
+
+// kubectlDiffResources returns the resources changed in the output of `kubectl diff`,
+// whose files are named like `apps.v1.Deployment.default.myapp`.
+func kubectlDiffResources(out string) []KubernetesResource {
+	resources := []KubernetesResource{}
+
+	var cur *KubernetesResource
+
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		l := s.Text()
+
+		if strings.HasPrefix(l, "diff ") {
+			fields := strings.Fields(l)
+			r, ok := kubectlDiffResource(path.Base(fields[len(fields)-1]))
+			if !ok {
+				cur = nil
+				continue
+			}
+			r.Action = ResourceUpdate
+			resources = append(resources, r)
+			cur = &resources[len(resources)-1]
+			continue
+		}
+
+		// The first hunk tells whether the resource is created or deleted,
+		// because either side of the diff is empty.
+		if cur != nil && strings.HasPrefix(l, "@@ ") {
+			if strings.HasPrefix(l, "@@ -0,0 ") {
+				cur.Action = ResourceCreate
+			} else if strings.Contains(l, " +0,0 @@") {
+				cur.Action = ResourceDelete
+			}
+			cur = nil
+		}
+	}
+
+	return resources
+}
+
+// kubectlDiffResource parses the name of the file compared by `kubectl diff`,
+// which is `[group.]version.kind.namespace.name`.
+// The group and the name can contain dots, but the version and the kind cannot.
+func kubectlDiffResource(file string) (KubernetesResource, bool) {
+	parts := strings.Split(file, ".")
+
+	// The kind is the first part starting with an upper case letter,
+	// because the group and the version are always in lower case.
+	for i := 1; i+2 < len(parts); i++ {
+		if p := parts[i]; p != "" && unicode.IsUpper(rune(p[0])) {
+			return KubernetesResource{
+				Kind:      p,
+				Namespace: parts[i+1],
+				Name:      strings.Join(parts[i+2:], "."),
+			}, true
+		}
+	}
+
+	return KubernetesResource{}, false
+}
+
+var helmDiffHeader = regexp.MustCompile(`^(\S*), (\S+), (\w+)(?: \([^)]*\))? (has changed|has been added|has been removed):$`)
+
+// helmDiffResources returns the resources changed in the output of `helm diff`,
+// whose headers look like `default, myapp, Deployment (apps) has changed:`.
+func helmDiffResources(out string) []KubernetesResource {
+	resources := []KubernetesResource{}
+
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		m := helmDiffHeader.FindStringSubmatch(strings.TrimSpace(s.Text()))
+		if m == nil {
+			continue
+		}
+
+		action := ResourceUpdate
+		switch m[4] {
+		case "has been added":
+			action = ResourceCreate
+		case "has been removed":
+			action = ResourceDelete
+		}
+
+		resources = append(resources, KubernetesResource{
+			Kind:      m[3],
+			Namespace: m[1],
+			Name:      m[2],
+			Action:    action,
+		})
+	}
+
+	return resources
+}
+
+// kubernetesDiffReport returns the func that reports the resources changed in the output of the plan commands,
+// given the commands generated by kargo for plan.
+//
+// It returns nil for the applications deployed via argocd, docker compose, kompose, or gitops,
+// whose plan commands print no diffs or the diffs without the resources in a parsable format.
+func kubernetesDiffReport(kc *kargo.Config, plan []kargo.Cmd) func(*Runtime, Outputs, string) (*ComponentDiff, error) {
+	if kc.ArgoCD != nil || kc.Compose != nil || len(plan) == 0 {
+		return nil
+	}
+
+	var parse func(string) []KubernetesResource
+
+	switch c := plan[len(plan)-1]; {
+	case kc.Helm != nil && c.Name == "helm":
+		parse = helmDiffResources
+	case kc.Kustomize != nil && c.Name == "kubectl":
+		parse = kubectlDiffResources
+	default:
+		return nil
+	}
+
+	return func(_ *Runtime, _ Outputs, out string) (*ComponentDiff, error) {
+		d := &ComponentDiff{
+			Status:     DiffNoChanges,
+			Kubernetes: &KubernetesDiff{Resources: parse(out)},
+		}
+
+		if len(d.Kubernetes.Resources) > 0 {
+			d.Status = DiffChanges
+		}
+
+		return d, nil
+	}
+}
@@ -0,0 +1,110 @@
+package kanvas
+
+import (
+	"testing"
+
+	"github.com/mumoshu/kargo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerraformPlanDiff(t *testing.T) {
+	d, err := terraformPlanDiff([]byte(`{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "aws_s3_bucket.a", "change": {"actions": ["create"]}},
+    {"address": "aws_s3_bucket.b", "change": {"actions": ["update"]}},
+    {"address": "aws_s3_bucket.c", "change": {"actions": ["delete", "create"]}},
+    {"address": "aws_s3_bucket.d", "change": {"actions": ["no-op"]}},
+    {"address": "aws_s3_bucket.e", "change": {"actions": ["delete"]}}
+  ]
+}`))
+	require.NoError(t, err)
+	require.Equal(t, &TerraformDiff{Add: 2, Change: 1, Destroy: 2}, d)
+
+	d, err = terraformPlanDiff([]byte(`{"format_version": "1.2"}`))
+	require.NoError(t, err)
+	require.Equal(t, &TerraformDiff{}, d)
+
+	_, err = terraformPlanDiff([]byte(`Error: no plan`))
+	require.Error(t, err)
+}
+
+func TestKubectlDiffResources(t *testing.T) {
+	out := `diff -u -N /tmp/LIVE-123/apps.v1.Deployment.default.myapp /tmp/MERGED-456/apps.v1.Deployment.default.myapp
+--- /tmp/LIVE-123/apps.v1.Deployment.default.myapp	2023-12-16 08:29:10.000000000 +0000
++++ /tmp/MERGED-456/apps.v1.Deployment.default.myapp	2023-12-16 08:29:10.000000000 +0000
+@@ -6,7 +6,7 @@
+-  replicas: 1
++  replicas: 2
+diff -u -N /tmp/LIVE-123/v1.Service.default.myapp.v2 /tmp/MERGED-456/v1.Service.default.myapp.v2
+--- /tmp/LIVE-123/v1.Service.default.myapp.v2	2023-12-16 08:29:10.000000000 +0000
++++ /tmp/MERGED-456/v1.Service.default.myapp.v2	2023-12-16 08:29:10.000000000 +0000
+@@ -0,0 +1,10 @@
++apiVersion: v1
+diff -u -N /tmp/LIVE-123/networking.k8s.io.v1.Ingress.web.myapp /tmp/MERGED-456/networking.k8s.io.v1.Ingress.web.myapp
+--- /tmp/LIVE-123/networking.k8s.io.v1.Ingress.web.myapp	2023-12-16 08:29:10.000000000 +0000
++++ /tmp/MERGED-456/networking.k8s.io.v1.Ingress.web.myapp	2023-12-16 08:29:10.000000000 +0000
+@@ -1,10 +0,0 @@
+-apiVersion: networking.k8s.io/v1
+`
+
+	require.Equal(t, []KubernetesResource{
+		{Kind: "Deployment", Namespace: "default", Name: "myapp", Action: ResourceUpdate},
+		{Kind: "Service", Namespace: "default", Name: "myapp.v2", Action: ResourceCreate},
+		{Kind: "Ingress", Namespace: "web", Name: "myapp", Action: ResourceDelete},
+	}, kubectlDiffResources(out))
+
+	require.Equal(t, []KubernetesResource{}, kubectlDiffResources(""))
+}
+
+func TestHelmDiffResources(t *testing.T) {
+	out := `default, myapp, Deployment (apps) has changed:
+  # Source: myapp/templates/deployment.yaml
+-   replicas: 1
++   replicas: 2
+default, myapp, Service (v1) has been added:
++ # Source: myapp/templates/service.yaml
+default, myapp-config, ConfigMap (v1) has been removed:
+- # Source: myapp/templates/configmap.yaml
+`
+
+	require.Equal(t, []KubernetesResource{
+		{Kind: "Deployment", Namespace: "default", Name: "myapp", Action: ResourceUpdate},
+		{Kind: "Service", Namespace: "default", Name: "myapp", Action: ResourceCreate},
+		{Kind: "ConfigMap", Namespace: "default", Name: "myapp-config", Action: ResourceDelete},
+	}, helmDiffResources(out))
+}
+
+func TestKubernetesDiffReport(t *testing.T) {
+	report := func(kc *kargo.Config) func(*Runtime, Outputs, string) (*ComponentDiff, error) {
+		t.Helper()
+
+		g := &kargo.Generator{TempDir: "/tmp"}
+		plan, err := g.ExecCmds(kc, kargo.Plan)
+		require.NoError(t, err)
+
+		return kubernetesDiffReport(kc, plan)
+	}
+
+	f := report(&kargo.Config{Name: "app", Path: "/app", Helm: &kargo.Helm{Chart: "mychart"}})
+	require.NotNil(t, f)
+
+	d, err := f(nil, nil, "default, app, Deployment (apps) has changed:\n")
+	require.NoError(t, err)
+	require.Equal(t, DiffChanges, d.Status)
+	require.Len(t, d.Kubernetes.Resources, 1)
+
+	d, err = f(nil, nil, "")
+	require.NoError(t, err)
+	require.Equal(t, DiffNoChanges, d.Status)
+
+	require.NotNil(t, report(&kargo.Config{
+		Name: "app",
+		Path: "/app",
+		Kustomize: &kargo.Kustomize{
+			Images: kargo.KustomizeImages{{Name: "app", NewTag: "v1"}},
+		},
+	}))
+	// argocd app diff prints nothing parsable, whatever the plan commands are.
+	require.Nil(t, kubernetesDiffReport(&kargo.Config{Name: "app", ArgoCD: &kargo.ArgoCD{}}, []kargo.Cmd{{Name: "argocd"}}))
+}
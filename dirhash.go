@@ -0,0 +1,88 @@
+package kanvas
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/moby/patternmatcher"
+)
+
+// hashDir writes the paths, modes, and contents of the files in dir to h.
+// The files matched by pm are skipped.
+// pm can be nil, which means that no file is skipped.
+func hashDir(h io.Writer, dir string, pm *patternmatcher.PatternMatcher) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		rel = filepath.ToSlash(rel)
+
+		if pm != nil {
+			ignored, err := pm.MatchesOrParentMatches(rel)
+			if err != nil {
+				return fmt.Errorf("matching %q: %w", rel, err)
+			}
+
+			if ignored {
+				// A directory can be skipped only when no exclusion pattern like `!dir/file`
+				// would re-include the files within it.
+				if d.IsDir() && !pm.Exclusions() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		return hashDirEntry(h, path, rel, d)
+	})
+}
+
+func hashDirEntry(h io.Writer, path, rel string, d fs.DirEntry) error {
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+
+	mode := info.Mode()
+
+	// Only the type and the executable bits are taken into account,
+	// because the other permission bits tend to differ across checkouts.
+	fmt.Fprintf(h, "%s\x00%s\x00%t\x00", rel, mode.Type(), mode.Perm()&0111 != 0)
+
+	switch {
+	case mode.IsRegular():
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+	case mode&fs.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprint(h, target)
+	}
+
+	fmt.Fprint(h, "\x00")
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package kanvas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+)
+
+const (
+	// dockerContextHashLen is the number of hex digits of the build context hash
+	// used as the image tag suffix.
+	dockerContextHashLen = 12
+)
+
+// dockerContextHash returns the sha256 digest of the docker build context in dir.
+//
+// The digest covers the paths, modes, and contents of the files sent to the docker daemon,
+// which means that the files ignored by the .dockerignore file are not taken into account.
+// The Dockerfile and the build args, including the resolved argsFrom values, are also taken into account,
+// because a change to either of them results in a different image.
+func dockerContextHash(dir, dockerfile string, args map[string]string) (string, error) {
+	pm, err := dockerignore(dir)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+
+	if err := hashDir(h, dir, pm); err != nil {
+		return "", fmt.Errorf("hashing docker build context %q: %w", dir, err)
+	}
+
+	if !filepath.IsAbs(dockerfile) {
+		dockerfile = filepath.Join(dir, dockerfile)
+	}
+
+	f, err := os.Open(dockerfile)
+	if err != nil {
+		return "", fmt.Errorf("reading dockerfile: %w", err)
+	}
+	defer f.Close()
+
+	fmt.Fprintf(h, "dockerfile\x00")
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("reading dockerfile: %w", err)
+	}
+
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(h, "build-arg\x00%s=%s\x00", name, args[name])
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// dockerignore returns the matcher for the .dockerignore file in dir.
+// It returns nil when there is no .dockerignore file.
+func dockerignore(dir string) (*patternmatcher.PatternMatcher, error) {
+	f, err := os.Open(filepath.Join(dir, ".dockerignore"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns, err := ignorefile.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading .dockerignore: %w", err)
+	}
+
+	pm, err := patternmatcher.New(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("parsing .dockerignore: %w", err)
+	}
+
+	return pm, nil
+}
+
+// dockerImageRef returns the tag and the reference of the image to be built.
+// The tag is the hash of the build context appended to the tag prefix in the image,
+// like `tagprefix-<hash>` for the image `repo/image:tagprefix-`.
+// The prefix not ending with any separator is separated from the hash with `-`,
+// like `latest-<hash>` for the image `repo/image:latest`.
+// If the image has no tag prefix, the tag is the hash itself.
+func dockerImageRef(image, hash string) (tag, ref string) {
+	if len(hash) > dockerContextHashLen {
+		hash = hash[:dockerContextHashLen]
+	}
+
+	repo, prefix := image, ""
+	// The colon in the registry host like `localhost:5000/image` is not a tag separator
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		repo, prefix = image[:i], image[i+1:]
+	}
+
+	if prefix != "" && !strings.ContainsAny(prefix[len(prefix)-1:], "-_.") {
+		prefix += "-"
+	}
+
+	tag = prefix + hash
+
+	return tag, repo + ":" + tag
+}
@@ -0,0 +1,103 @@
+package kanvas
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerContextHash(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	}
+
+	hash := func(args map[string]string) string {
+		t.Helper()
+		h, err := dockerContextHash(dir, "Dockerfile", args)
+		require.NoError(t, err)
+		return h
+	}
+
+	write("Dockerfile", "FROM alpine\n")
+	write(".dockerignore", "tmp\n*.log\n!keep.log\n")
+	write("main.go", "package main\n")
+
+	h := hash(nil)
+	require.Len(t, h, 64)
+	require.Equal(t, h, hash(nil))
+
+	// Ignored files don't affect the hash
+	write("tmp/cache", "a")
+	write("debug.log", "a")
+	require.Equal(t, h, hash(nil))
+
+	// Re-included files do
+	write("keep.log", "a")
+	h2 := hash(nil)
+	require.NotEqual(t, h, h2)
+
+	write("main.go", "package main\n\nfunc main() {}\n")
+	h3 := hash(nil)
+	require.NotEqual(t, h2, h3)
+
+	write("Dockerfile", "FROM alpine:3.19\n")
+	h4 := hash(nil)
+	require.NotEqual(t, h3, h4)
+
+	require.NotEqual(t, h4, hash(map[string]string{"VERSION": "1"}))
+}
+
+func TestDockerImageRef(t *testing.T) {
+	const hash = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	for _, tc := range []struct {
+		image, tag, ref string
+	}{
+		{"repo/image:tagprefix-", "tagprefix-0123456789ab", "repo/image:tagprefix-0123456789ab"},
+		{"repo/image", "0123456789ab", "repo/image:0123456789ab"},
+		{"localhost:5000/image", "0123456789ab", "localhost:5000/image:0123456789ab"},
+		{"localhost:5000/image:v-", "v-0123456789ab", "localhost:5000/image:v-0123456789ab"},
+		{"repo/image:latest", "latest-0123456789ab", "repo/image:latest-0123456789ab"},
+		{"repo/image:v1.", "v1.0123456789ab", "repo/image:v1.0123456789ab"},
+	} {
+		tag, ref := dockerImageRef(tc.image, hash)
+		require.Equal(t, tc.tag, tag, tc.image)
+		require.Equal(t, tc.ref, ref, tc.image)
+	}
+}
+
+func TestDockerImageRef_ArgsFrom(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM alpine\n"), 0644))
+
+	d, err := newDriver("image", dir, Component{
+		Docker: &Docker{
+			Image:    "example.com/app",
+			ArgsFrom: map[string]string{"BASE": "base.ref"},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	ref := func(base string) string {
+		t.Helper()
+		o := Outputs{}
+		require.NoError(t, d.Apply[0].Exec(nil, []string{"--build-arg", "BASE=" + base}, o))
+		return o.String("tag")
+	}
+
+	require.Equal(t, ref("example.com/base:1"), ref("example.com/base:1"))
+	require.NotEqual(t, ref("example.com/base:1"), ref("example.com/base:2"))
+
+	// `kanvas output` in the exported workflows computes the same tag from the resolved args.
+	require.NotNil(t, d.OutputArgs)
+	o := Outputs{}
+	require.NoError(t, d.OutputArgsFunc([]string{"--build-arg", "BASE=example.com/base:1"}, o))
+	require.Equal(t, ref("example.com/base:1"), o.String("tag"))
+}
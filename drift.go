@@ -0,0 +1,44 @@
+package kanvas
+
+import (
+	"github.com/mumoshu/kargo"
+)
+
+// OutputChanges is the output of the component whose drift is detected,
+// which is either true or false depending on whether applying the component would make changes.
+const OutputChanges = "kanvas.changes"
+
+// kubernetesDrift returns the read-only tasks to detect the changes to the application deployed by kargo,
+// given the commands generated by kargo for plan.
+//
+// It returns nil for the applications deployed via docker compose, kompose, or gitops,
+// whose plan commands cannot tell whether there are changes.
+func kubernetesDrift(kc *kargo.Config, plan []kargo.Cmd) []Task {
+	if kc.ArgoCD != nil {
+		// argocd app diff exits with 1 when there are changes, and 2 on errors.
+		return []Task{changesExitCode(1, cmdToTask(argocdApp("argocd-app-diff", kc, "diff", kc.Name)))}
+	}
+
+	if kc.Compose != nil || len(plan) == 0 {
+		return nil
+	}
+
+	tasks := cmdsToSeq(plan)
+	last := &tasks[len(tasks)-1]
+	c := last.Run[0]
+
+	switch {
+	case kc.Helm != nil && c.Name == "helm":
+		// helm diff exits with 2 when there are changes, only with --detailed-exitcode.
+		c.Args = kargo.NewArgs(c.Args, "--detailed-exitcode")
+		last.Run = []kargo.Cmd{c}
+		last.ChangesExitCode = 2
+	case kc.Kustomize != nil && c.Name == "kubectl":
+		// kubectl diff exits with 1 when there are changes, and greater than 1 on errors.
+		last.ChangesExitCode = 1
+	default:
+		return nil
+	}
+
+	return tasks
+}
@@ -0,0 +1,49 @@
+package kanvas
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mumoshu/kargo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKubernetesDrift(t *testing.T) {
+	drift := func(kc *kargo.Config) []Task {
+		t.Helper()
+
+		g := &kargo.Generator{TempDir: "/tmp"}
+		plan, err := g.ExecCmds(kc, kargo.Plan)
+		require.NoError(t, err)
+
+		return kubernetesDrift(kc, plan)
+	}
+
+	last := func(tasks []Task) (string, int) {
+		t.Helper()
+
+		task := tasks[len(tasks)-1]
+		args, err := task.Run[0].Args.Collect(func(out string) (string, error) {
+			return "$" + out, nil
+		})
+		require.NoError(t, err)
+
+		return task.Run[0].Name + " " + strings.Join(args, " "), task.ChangesExitCode
+	}
+
+	c, code := last(drift(&kargo.Config{Name: "app", Path: "/app", Helm: &kargo.Helm{Chart: "mychart"}}))
+	require.Equal(t, "helm diff upgrade --install app mychart --detailed-exitcode", c)
+	require.Equal(t, 2, code)
+
+	c, code = last(drift(&kargo.Config{
+		Name: "app",
+		Path: "/app",
+		Kustomize: &kargo.Kustomize{
+			Images: kargo.KustomizeImages{{Name: "app", NewTag: "v1"}},
+		},
+	}))
+	require.Equal(t, "kubectl diff -f /tmp/kustomize-built.yaml --server-side=true", c)
+	require.Equal(t, 1, code)
+
+	require.Nil(t, drift(&kargo.Config{Name: "app", Path: "/app", Compose: &kargo.Compose{}}))
+}
@@ -6,11 +6,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"dario.cat/mergo"
-	"github.com/hashicorp/hcl/v2"
 	"github.com/helmfile/vals"
 	"github.com/mumoshu/kargo"
 	"github.com/mumoshu/kargo/cmd"
@@ -19,12 +20,40 @@ import (
 
 type Task struct {
 	IfOutputEq IfOutputEq
-	Run        []kargo.Cmd
-	OutputFunc func(*Runtime, map[string]string) error
+	// UnlessOutputEq skips the task when the output equals to the value.
+	// This is evaluated in addition to IfOutputEq.
+	UnlessOutputEq IfOutputEq
+	Run            []kargo.Cmd
+	OutputFunc     func(*Runtime, Outputs) error
 
 	// Func is the func called instead of Run.
 	// If Func is set, Run and OutputFunc are ignored.
-	Func func(*WorkflowJob, map[string]string) error
+	Func func(*WorkflowJob, Outputs) error
+
+	// Args is resolved against the outputs of other jobs
+	// and passed to Exec.
+	Args *kargo.Args
+	// Exec is the func called with the resolved Args instead of Run.
+	// Unlike Func, this is for in-process tasks that depend on the outputs of other jobs.
+	// If Exec is set, Run and OutputFunc are ignored.
+	Exec func(r *Runtime, args []string, o Outputs) error
+	// ExecOutputsOnly marks Exec as the one that only computes the outputs of the job itself,
+	// like the tag of the image to be built.
+	// The exported CI workflows compute such outputs via `kanvas output` instead.
+	ExecOutputsOnly bool
+
+	// Setup marks the task as the preparation needed for reading the outputs of the job, like terraform init.
+	// The exported CI workflows run the setup tasks even when the job is not affected by the changes,
+	// so that the affected jobs can read the outputs of the job.
+	Setup bool
+
+	// ChangesExitCode is the exit code of the commands in Run that means changes are detected,
+	// like 2 for `terraform plan -detailed-exitcode`.
+	// The commands exited with the code are not considered failed,
+	// and the OutputChanges output of the job is set to true.
+	// Otherwise, OutputChanges is set to false unless already set to true.
+	// Zero means the commands do not detect changes.
+	ChangesExitCode int
 }
 
 type IfOutputEq struct {
@@ -33,10 +62,41 @@ type IfOutputEq struct {
 }
 
 type Driver struct {
-	Diff       []Task
-	Apply      []Task
+	Diff  []Task
+	Apply []Task
+	// Destroy is the list of tasks to destroy what Apply deployed,
+	// like terraform destroy or helm uninstall.
+	// It is empty when the component has nothing to destroy.
+	Destroy []Task
+	// Drift is the list of read-only tasks to detect the changes Apply would make,
+	// which set the OutputChanges output of the job.
+	// It is empty when the component does not support detecting changes.
+	Drift []Task
+	// Preview is the list of read-only tasks to show what Apply is going to do,
+	// run to show the diff when asking for the approval right before Apply.
+	// It is empty when running Diff again is fine for that,
+	// and set when Diff would replace what Apply consumes,
+	// like the saved terraform plan that has been reviewed.
+	Preview []Task
+	// DiffReport returns the structured diff of the component after Diff succeeded,
+	// given the outputs of the job and the combined output of the Diff commands.
+	// It is nil when the component has nothing to report,
+	// or the diff cannot tell whether there are changes.
+	DiffReport func(r *Runtime, o Outputs, out string) (*ComponentDiff, error)
 	Output     func(format string) []string
-	OutputFunc func(*Runtime, Op, map[string]string) error
+	OutputFunc func(*Runtime, Op, Outputs) error
+	// OutputArgs is the args OutputFunc needs resolved from the outputs of the other jobs,
+	// like the argsFrom of the docker build, which change the image tag.
+	// The exported workflows resolve them and pass them to `kanvas output` after `--`,
+	// which calls OutputArgsFunc with the resolved args before OutputFunc.
+	// It is nil when OutputFunc needs nothing from the other jobs.
+	OutputArgs     *kargo.Args
+	OutputArgsFunc func(args []string, o Outputs) error
+	// Artifacts is the list of files produced by Diff and consumed by Apply,
+	// like terraform plan files.
+	// Each path is relative to the current working directory.
+	// This is used by the CI exporters to pass the files from the plan to the apply workflow.
+	Artifacts []string
 }
 
 type Op int
@@ -44,8 +104,16 @@ type Op int
 const (
 	Diff Op = iota
 	Apply
+	Destroy
 )
 
+// DefaultParallelism is the default maximum number of jobs
+// that run concurrently within each phase of the workflow.
+const DefaultParallelism = 4
+
+// ResumeLatest is the value of Options.Resume that resumes the latest run.
+const ResumeLatest = "latest"
+
 type Options struct {
 	Env        string
 	ConfigFile string
@@ -58,10 +126,63 @@ type Options struct {
 	UseAI bool
 	// Skip is a list of components to skip.
 	Skip []string
+	// PlanDir is the directory to store the terraform plan files.
+	// If set, diff writes the plan file of each terraform job into this directory,
+	// and apply applies the plan file instead of planning again.
+	// Apply fails when the plan file is missing or stale.
+	PlanDir string
+	// Approve is a list of approval gates to approve in advance.
+	// Each gate is either a job ID for a component approval,
+	// or an environment name for an environment approval.
+	Approve []string
 	// SkippedJobsOutputs is a map of outputs for skipped jobs.
 	// The keys must be found in the Skip list.
 	// For example, if Skip is ["foo"], then SkippedJobsOutputs must have a key "foo".
-	SkippedJobsOutputs map[string]map[string]string
+	SkippedJobsOutputs map[string]Outputs
+	// Parallelism is the maximum number of jobs to run concurrently.
+	// If zero, this defaults to DefaultParallelism.
+	// Set it to 1 to run the jobs one by one.
+	Parallelism int
+	// Phased makes the jobs run phase by phase, where each phase is a level of the dependency graph.
+	// A phase starts only after all the jobs in the previous phase are done,
+	// which makes the order of the jobs reproducible across runs.
+	// If false, each job starts as soon as all the jobs it needs are done.
+	Phased bool
+	// Timeout is the maximum duration of the whole diff or apply.
+	// The jobs being run are interrupted when it times out.
+	// Zero means no timeout.
+	Timeout time.Duration
+	// Resume is the ID of the run to resume, or ResumeLatest to resume the latest run.
+	// Apply skips the components that succeeded in the run and reuses their outputs.
+	// If empty, apply starts a new run.
+	Resume string
+	// Only is a list of selectors of the components to diff or apply.
+	// Each selector is a glob pattern matched against the component IDs, like app or /product1/*.
+	// See MatchID for details.
+	// The components needed by the selected components are not diffed or applied,
+	// but their outputs are read so that the selected components can use them.
+	// If empty, all the components are selected.
+	Only []string
+	// WithDeps selects the components needed by the components selected via Only, transitively.
+	WithDeps bool
+	// WithDependents selects the components that need the components selected via Only, transitively.
+	WithDependents bool
+	// Force makes apply run all the components,
+	// even the ones whose input fingerprints match the last successful apply.
+	Force bool
+	// AffectedOnly makes the exported CI workflows run the commands of only the components
+	// affected by the changes, as computed by `kanvas affected`.
+	// The other components only read their outputs for the affected components.
+	AffectedOnly bool
+	// Teardown makes the CI exporters also export the workflow that destroys the components
+	// in the reverse order when a pull request is closed.
+	Teardown bool
+	// DiffReport makes diff produce the structured diff of each component,
+	// which may need to run additional commands like terraform show.
+	DiffReport bool
+	// Strict makes loading the config fail on the problems that are otherwise warned,
+	// like the components referenced via valueFrom but missing in needs.
+	Strict bool
 }
 
 func (o Options) GetConfigFilePath() string {
@@ -76,25 +197,33 @@ func concat(a ...[]interface{}) []interface{} {
 	return r
 }
 
-func kanvasOutputCommandForID(id string) func(format string) []string {
+func kanvasOutputCommandForID(id string, opts Options) func(format string) []string {
 	return func(format string) []string {
-		return append([]string{
+		c := append([]string{
 			"kanvas", "output", "-t", id, "-f",
 		},
 			format,
 		)
+		// The job ID is namespaced by the environment
+		// when the environment needs other environments.
+		// We need to pass the environment so that the output command
+		// can find the job.
+		if opts.Env != "" {
+			c = append(c, "-e", opts.Env)
+		}
+		return c
 	}
 }
 
 func newDriver(id, dir string, c Component, opts Options) (*Driver, error) {
-	output := kanvasOutputCommandForID(id)
+	output := kanvasOutputCommandForID(id, opts)
 
 	if c.AWS != nil {
 		return &Driver{
 			Diff:   nil,
 			Apply:  nil,
 			Output: output,
-			OutputFunc: func(r *Runtime, op Op, o map[string]string) error {
+			OutputFunc: func(r *Runtime, op Op, o Outputs) error {
 				// Get a get-caller-identity result and compare
 				// returned Account with the one in the config
 				var buf bytes.Buffer
@@ -119,22 +248,34 @@ func newDriver(id, dir string, c Component, opts Options) (*Driver, error) {
 			},
 		}, nil
 	} else if c.Docker != nil {
-		// TODO Append some unique-ish ID of the to-be-produced image
 		image := c.Docker.Image
 		dockerfile := c.Docker.File
 		if dockerfile == "" {
 			dockerfile = "Dockerfile"
 		}
+
+		// ref is the image reference tagged with the hash of the build context.
+		// It is computed by the first task of diff and apply,
+		// and then referenced as an output of this job by the subsequent tasks.
+		ref := (&kargo.Args{}).AppendValueFromOutput(filepath.Base(id) + ".ref")
+
 		buildArgs := []interface{}{"build"}
 		for name, value := range c.Docker.Args {
 			buildArgs = append(buildArgs, "--build-arg", fmt.Sprintf("%s=%s", name, value))
 		}
+		// The names are sorted so that the exported workflows are stable across exports.
+		argsFromNames := make([]string, 0, len(c.Docker.ArgsFrom))
+		for name := range c.Docker.ArgsFrom {
+			argsFromNames = append(argsFromNames, name)
+		}
+		sort.Strings(argsFromNames)
+
 		dynBuildArgs := &kargo.Args{}
-		for name, valueFrom := range c.Docker.ArgsFrom {
+		for _, name := range argsFromNames {
 			dynBuildArgs = dynBuildArgs.Append("--build-arg")
 			dynBuildArgs = dynBuildArgs.AppendValueFromOutputWithPrefix(
 				fmt.Sprintf("%s=", name),
-				valueFrom,
+				c.Docker.ArgsFrom[name],
 			)
 		}
 
@@ -147,7 +288,8 @@ func newDriver(id, dir string, c Component, opts Options) (*Driver, error) {
 		dockerBuild := cmd.New(
 			"docker-build",
 			"docker",
-			cmd.Args(concat(buildArgs, []interface{}{"-t", image, "-f", dockerfile})...),
+			cmd.Args(buildArgs...),
+			cmd.Args("-t", ref, "-f", dockerfile),
 			cmd.Args(dynBuildArgs),
 			cmd.Args(dynTags),
 			cmd.Args("."),
@@ -156,7 +298,8 @@ func newDriver(id, dir string, c Component, opts Options) (*Driver, error) {
 		dockerBuildxLoad := cmd.New(
 			"docker-buildx-push",
 			"docker",
-			cmd.Args(concat(buildArgs, []interface{}{"--load", "--platform", "linux/amd64", "-t", image, "-f", dockerfile})...),
+			cmd.Args(concat(buildArgs, []interface{}{"--load", "--platform", "linux/amd64"})...),
+			cmd.Args("-t", ref, "-f", dockerfile),
 			cmd.Args(dynBuildArgs),
 			cmd.Args(dynTags),
 			cmd.Args("."),
@@ -164,18 +307,53 @@ func newDriver(id, dir string, c Component, opts Options) (*Driver, error) {
 		dockerPush := cmd.New(
 			"docker-push",
 			"docker",
-			cmd.Args("push", image),
+			cmd.Args("push", ref),
 		)
 		dockerBuildxPush := cmd.New(
 			"docker-buildx-push",
 			"docker",
-			cmd.Args(concat(buildArgs, []interface{}{"--push", "--platform", "linux/amd64", "-t", image, "-f", dockerfile, "."})...),
+			cmd.Args(concat(buildArgs, []interface{}{"--push", "--platform", "linux/amd64"})...),
+			cmd.Args("-t", ref, "-f", dockerfile),
+			cmd.Args(dynBuildArgs),
+			cmd.Args(dynTags),
+			cmd.Args("."),
 		)
 
+		// imageRef computes the tag from the build context and the build args.
+		// dynArgs is the resolved dynBuildArgs, like `--build-arg NAME=value`,
+		// so that the image built with different argsFrom values gets a different tag.
+		imageRef := func(dynArgs []string, o Outputs) error {
+			args := make(map[string]string, len(c.Docker.Args)+len(c.Docker.ArgsFrom))
+			for name, value := range c.Docker.Args {
+				args[name] = value
+			}
+			for i := 0; i+1 < len(dynArgs); i += 2 {
+				name, value, _ := strings.Cut(dynArgs[i+1], "=")
+				args[name] = value
+			}
+
+			hash, err := dockerContextHash(dir, dockerfile, args)
+			if err != nil {
+				return err
+			}
+
+			o["tag"], o["ref"] = dockerImageRef(image, hash)
+
+			return nil
+		}
+
 		var diff, apply []Task
 
+		dockerImageRefTask := Task{
+			Args: dynBuildArgs,
+			Exec: func(r *Runtime, args []string, o Outputs) error {
+				return imageRef(args, o)
+			},
+			ExecOutputsOnly: true,
+		}
+
 		dockerBuildXCheckAvailability := Task{
-			OutputFunc: func(r *Runtime, o map[string]string) error {
+			OutputFunc: func(r *Runtime, o Outputs) error {
 				if err := r.Exec(dir, []string{"docker", "buildx", "inspect"}); err != nil {
 					o["kanvas.buildx"] = "false"
 				} else {
@@ -190,6 +368,10 @@ func newDriver(id, dir string, c Component, opts Options) (*Driver, error) {
 				Key:   "kanvas.buildx",
 				Value: "true",
 			},
+			UnlessOutputEq: IfOutputEq{
+				Key:   "kanvas.exists",
+				Value: "true",
+			},
 			Run: []kargo.Cmd{
 				dockerBuildxPush,
 			},
@@ -199,6 +381,10 @@ func newDriver(id, dir string, c Component, opts Options) (*Driver, error) {
 				Key:   "kanvas.buildx",
 				Value: "false",
 			},
+			UnlessOutputEq: IfOutputEq{
+				Key:   "kanvas.exists",
+				Value: "true",
+			},
 			Run: []kargo.Cmd{
 				dockerBuild,
 				dockerPush,
@@ -224,21 +410,39 @@ func newDriver(id, dir string, c Component, opts Options) (*Driver, error) {
 		}
 
 		diff = append(diff,
+			dockerImageRefTask,
 			dockerBuildXCheckAvailability,
 			dockerBuildXBuildLoadIfAvailable,
 			dockerBuildIfBuildxNotAvailable,
 		)
 
 		apply = append(apply,
+			dockerImageRefTask,
 			dockerBuildXCheckAvailability,
 		)
 
+		var (
+			destroy, drift []Task
+			diffReport     func(*Runtime, Outputs, string) (*ComponentDiff, error)
+		)
+
+		if c.Docker.DeleteOnDestroy {
+			if c.Docker.Kind != nil {
+				return nil, fmt.Errorf("invalid docker component: deleteOnDestroy cannot be used with kind, because the image is not pushed to any registry")
+			}
+
+			destroy = append(destroy,
+				dockerImageRefTask,
+				Cmd("regctl-tag-delete", "regctl", cmd.Args("tag", "delete", ref)),
+			)
+		}
+
 		if c.Docker.Kind != nil {
 			args := []interface{}{"load", "docker-image"}
 			if c.Docker.Kind.ClusterName != "" {
 				args = append(args, "--name", c.Docker.Kind.ClusterName)
 			}
-			args = append(args, image)
+			args = append(args, ref)
 			kindLoadImageCmd := cmd.New(
 				"kind-load-image",
 				"kind",
@@ -255,26 +459,118 @@ func newDriver(id, dir string, c Component, opts Options) (*Driver, error) {
 				dockerBuildIfBuildxNotAvailable,
 				kindLoadImage,
 			)
+
+			// Apply always builds and loads the image into the cluster,
+			// because there is no registry to tell whether the cluster has the image.
+			diffReport = func(_ *Runtime, o Outputs, _ string) (*ComponentDiff, error) {
+				return &ComponentDiff{
+					Status: DiffChanges,
+					Docker: &DockerDiff{Ref: o.String("ref"), Rebuild: true},
+				}, nil
+			}
 		} else {
+			// The image is content-addressed so we can safely skip building and pushing
+			// when the registry already has the image with the same tag.
+			imageExists := func(r *Runtime, ref string) bool {
+				var buf bytes.Buffer
+				return r.Exec(dir, []string{"docker", "manifest", "inspect", ref}, ExecStdout(&buf)) == nil
+			}
+
+			dockerCheckExistence := Task{
+				OutputFunc: func(r *Runtime, o Outputs) error {
+					o["kanvas.exists"] = strconv.FormatBool(imageExists(r, o.String("ref")))
+					return nil
+				},
+			}
+
 			apply = append(apply,
+				dockerCheckExistence,
 				dockerBuildXPushIfAvailable,
 				dockerBuildAndPushIfBuildxNotAvailable,
 			)
+
+			// The image needs to be built and pushed when the registry does not have it yet.
+			// We cannot tell it for kind, because there is no registry to inspect.
+			drift = append(drift,
+				dockerImageRefTask,
+				dockerCheckExistence,
+				Task{
+					OutputFunc: func(r *Runtime, o Outputs) error {
+						o[OutputChanges] = strconv.FormatBool(o["kanvas.exists"] != "true")
+						return nil
+					},
+				},
+			)
+
+			diffReport = func(r *Runtime, o Outputs, _ string) (*ComponentDiff, error) {
+				d := &ComponentDiff{
+					Status: DiffNoChanges,
+					Docker: &DockerDiff{Ref: o.String("ref"), Rebuild: !imageExists(r, o.String("ref"))},
+				}
+
+				if d.Docker.Rebuild {
+					d.Status = DiffChanges
+				}
+
+				return d, nil
+			}
 		}
 
-		return &Driver{
-			Diff:   diff,
-			Apply:  apply,
-			Output: output,
-			OutputFunc: func(r *Runtime, op Op, o map[string]string) error {
-				var buf bytes.Buffer
-				if err := r.Exec(dir, []string{"docker", "inspect", "--format={{.ID}}", image}, ExecStdout(&buf)); err != nil {
-					return fmt.Errorf("docker-inspect failed: %w", err)
+		// imageID returns the digest of the image in the registry,
+		// or the ID of the local image when the registry does not have it, like for kind.
+		// It returns an empty string when the image is not built yet.
+		imageID := func(r *Runtime, ref string) string {
+			var buf bytes.Buffer
+			if c.Docker.Kind == nil {
+				// The image is not available locally when it is pushed via buildx,
+				// or the build is skipped because the image already exists in the registry.
+				if err := r.Exec(dir, []string{"docker", "buildx", "imagetools", "inspect", "--format={{json .Manifest}}", ref}, ExecStdout(&buf)); err == nil {
+					var m struct {
+						Digest string `json:"digest"`
+					}
+					if err := json.Unmarshal(buf.Bytes(), &m); err == nil && m.Digest != "" {
+						return m.Digest
+					}
+				}
+				buf.Reset()
+			}
+
+			if err := r.Exec(dir, []string{"docker", "image", "inspect", "--format={{.ID}}", ref}, ExecStdout(&buf)); err == nil {
+				return strings.TrimSpace(buf.String())
+			}
+
+			return ""
+		}
+
+		d := &Driver{
+			Diff:       diff,
+			Apply:      apply,
+			Destroy:    destroy,
+			Drift:      drift,
+			DiffReport: diffReport,
+			Output:     output,
+			OutputFunc: func(r *Runtime, op Op, o Outputs) error {
+				// The outputs read without running any task, like via `kanvas output` in the exported workflows,
+				// get the resolved argsFrom values via OutputArgsFunc.
+				if o.String("ref") == "" {
+					if err := imageRef(nil, o); err != nil {
+						return err
+					}
+				}
+
+				if id := imageID(r, o.String("ref")); id != "" {
+					o["id"] = id
 				}
-				o["id"] = strings.TrimSpace(buf.String())
 				return nil
 			},
-		}, nil
+		}
+
+		if len(c.Docker.ArgsFrom) > 0 {
+			d.OutputArgs = dynBuildArgs
+			d.OutputArgsFunc = imageRef
+		}
+
+		return d, nil
 	} else if c.Terraform != nil {
 		var args []string
 
@@ -297,85 +593,193 @@ func newDriver(id, dir string, c Component, opts Options) (*Driver, error) {
 			}
 		}
 
+		for _, f := range c.Terraform.VarFiles {
+			args = append(args, "-var-file", f)
+		}
+
+		if p := c.Terraform.Parallelism; p > 0 {
+			args = append(args, fmt.Sprintf("-parallelism=%d", p))
+		}
+
+		if r := c.Terraform.Refresh; r != nil {
+			args = append(args, fmt.Sprintf("-refresh=%t", *r))
+		}
+
+		var initArgs []string
+		for _, bc := range c.Terraform.BackendConfig {
+			if bc.File != "" {
+				if bc.Name != "" || bc.Value != "" {
+					return nil, fmt.Errorf("invalid backend config %v: file is mutually exclusive with name and value", bc)
+				}
+				initArgs = append(initArgs, "-backend-config", bc.File)
+			} else if bc.Name != "" {
+				initArgs = append(initArgs, "-backend-config", fmt.Sprintf("%s=%s", bc.Name, bc.Value))
+			} else {
+				return nil, fmt.Errorf("invalid backend config %v: it must have either name or file", bc)
+			}
+		}
+
 		applyArgs := append([]string{}, args...)
 		applyArgs = append(applyArgs, "-auto-approve")
 
-		return &Driver{
-			Diff: []Task{
-				Cmd("terraform-init", "terraform", cmd.Args("init"), cmd.Dir(dir)),
-				Cmd("terraform-plan", "terraform", cmd.Args("plan", args, dynArgs), cmd.Dir(dir)),
-			},
-			Apply: []Task{
-				Cmd("terraform-init", "terraform", cmd.Args("init"), cmd.Dir(dir)),
-				Cmd("terraform-apply", "terraform", cmd.Args("apply", applyArgs, dynArgs), cmd.Dir(dir)),
-			},
-			Output: output,
-			OutputFunc: func(r *Runtime, op Op, o map[string]string) error {
-				var buf bytes.Buffer
-				if err := r.Exec(dir, []string{"terraform", "output", "-json"}, ExecStdout(&buf)); err != nil {
-					return fmt.Errorf("terraform-output failed: %w", err)
+		init := []Task{
+			setup(Cmd("terraform-init", "terraform", cmd.Args("init", initArgs), cmd.Dir(dir))),
+		}
+
+		// Drift never creates the workspace, because it must not change anything.
+		// A missing workspace fails the drift detection instead.
+		driftInit := append([]Task{}, init...)
+
+		var outputOpts []ExecOption
+
+		if ws := c.Terraform.Workspace; ws != "" {
+			init = append(init,
+				setup(Cmd("terraform-workspace-select", "terraform", cmd.Args("workspace", "select", "-or-create", ws), cmd.Dir(dir))),
+			)
+			driftInit = append(driftInit,
+				setup(Cmd("terraform-workspace-select", "terraform", cmd.Args("workspace", "select", ws), cmd.Dir(dir))),
+			)
+
+			// terraform-output can be run without the preceding terraform-workspace-select,
+			// like when it is run via `kanvas output` in a CI job.
+			// TF_WORKSPACE ensures that the outputs are read from the right workspace.
+			outputOpts = append(outputOpts, ExecAddEnv(map[string]string{"TF_WORKSPACE": ws}))
+		}
+
+		diff := append(append([]Task{}, init...),
+			Cmd("terraform-plan", "terraform", cmd.Args("plan", args, dynArgs), cmd.Dir(dir)),
+		)
+		apply := append(append([]Task{}, init...),
+			Cmd("terraform-apply", "terraform", cmd.Args("apply", applyArgs, dynArgs), cmd.Dir(dir)),
+		)
+		// Destroy never uses the saved plan files,
+		// because they are the plans to apply, not to destroy.
+		destroy := append(append([]Task{}, init...),
+			Cmd("terraform-destroy", "terraform", cmd.Args("destroy", applyArgs, dynArgs), cmd.Dir(dir)),
+		)
+		// Like destroy, drift never uses the saved plan files.
+		drift := append(driftInit,
+			changesExitCode(2, Cmd("terraform-plan", "terraform", cmd.Args("plan", "-detailed-exitcode", args, dynArgs), cmd.Dir(dir))),
+		)
+
+		var (
+			artifacts []string
+			preview   []Task
+			// showPlan is the plan file to be read by terraform show for the diff report,
+			// relative to the dir of the component.
+			showPlan string
+		)
+
+		if opts.PlanDir == "" && opts.DiffReport {
+			// The plan is saved only to be read by terraform show,
+			// so it is never applied nor exported as an artifact.
+			planFile, err := filepath.Abs(terraformPlanFile(opts.TempDir, id))
+			if err != nil {
+				return nil, err
+			}
+
+			diff = append(append([]Task{}, init...),
+				Cmd("terraform-plan", "terraform", cmd.Args("plan", "-out", planFile, args, dynArgs), cmd.Dir(dir)),
+			)
+			showPlan = planFile
+		}
+
+		if opts.PlanDir != "" {
+			planFile := terraformPlanFile(opts.PlanDir, id)
+
+			// The plan file is referenced from within the dir of the component
+			plan, err := filepath.Rel(dir, planFile)
+			if err != nil {
+				if plan, err = filepath.Abs(planFile); err != nil {
+					return nil, err
 				}
+			}
+
+			fingerprint := []interface{}{
+				"tools", CommandTerraformPlanFingerprint,
+				"--" + FlagTerraformPlanFingerprintPlan, plan,
+			}
 
-				out := buf.String()
+			// Variables and targets cannot be set when applying a saved plan,
+			// because they are already recorded in the plan.
+			var savedApplyArgs []string
+			if p := c.Terraform.Parallelism; p > 0 {
+				savedApplyArgs = append(savedApplyArgs, fmt.Sprintf("-parallelism=%d", p))
+			}
 
-				d := json.NewDecoder(bytes.NewBufferString(out))
+			// The fingerprint is written before planning, because it also creates the plan dir
+			// that terraform plan -out expects to exist.
+			diff = append(append([]Task{}, init...),
+				Cmd("terraform-plan-fingerprint", "kanvas", cmd.Args(fingerprint...), cmd.Args("--", args, dynArgs), cmd.Dir(dir)),
+				Cmd("terraform-plan", "terraform", cmd.Args("plan", "-out", plan, args, dynArgs), cmd.Dir(dir)),
+			)
+			apply = append(append([]Task{}, init...),
+				Cmd("terraform-plan-verify", "kanvas", cmd.Args(fingerprint...), cmd.Args("--"+FlagTerraformPlanFingerprintVerify, "--", args, dynArgs), cmd.Dir(dir)),
+				Cmd("terraform-apply", "terraform", cmd.Args("apply", savedApplyArgs, plan), cmd.Dir(dir)),
+			)
+			// The preview shows the saved plan as is, so that the plan applied is the one reviewed.
+			preview = append(append([]Task{}, init...),
+				Cmd("terraform-plan-verify", "kanvas", cmd.Args(fingerprint...), cmd.Args("--"+FlagTerraformPlanFingerprintVerify, "--", args, dynArgs), cmd.Dir(dir)),
+				Cmd("terraform-show", "terraform", cmd.Args("show", plan), cmd.Dir(dir)),
+			)
+			artifacts = []string{planFile, planFile + terraformPlanFingerprintExt}
+			showPlan = plan
+		}
 
-				type terraformOutput struct {
-					Sensitive bool        `json:"sensitive"`
-					Type      string      `json:"type"`
-					Value     interface{} `json:"value"`
+		return &Driver{
+			Diff:      diff,
+			Apply:     apply,
+			Destroy:   destroy,
+			Drift:     drift,
+			Preview:   preview,
+			Artifacts: artifacts,
+			DiffReport: func(r *Runtime, o Outputs, _ string) (*ComponentDiff, error) {
+				if showPlan == "" {
+					return nil, nil
 				}
 
-				m := map[string]terraformOutput{}
-				if err := d.Decode(&m); err != nil {
-					return fmt.Errorf("unable to decode terraform outputs: %w", err)
+				var buf bytes.Buffer
+				if err := r.Exec(dir, []string{"terraform", "show", "-json", showPlan}, append([]ExecOption{ExecStdout(&buf)}, outputOpts...)...); err != nil {
+					return nil, fmt.Errorf("terraform-show failed: %w", err)
 				}
 
-				for k, out := range m {
-					switch tpe := out.Type; tpe {
-					case "string":
-						o[k] = out.Value.(string)
-					case "number":
-						o[k] = strconv.Itoa(out.Value.(int))
-					case "bool":
-						o[k] = strconv.FormatBool(out.Value.(bool))
-					default:
-						return fmt.Errorf("unable to unmarshal terraform output %q of type %q", k, tpe)
-					}
+				tf, err := terraformPlanDiff(buf.Bytes())
+				if err != nil {
+					return nil, err
 				}
 
-				o["_raw"] = buf.String()
+				d := &ComponentDiff{Status: DiffNoChanges, Terraform: tf}
+				if tf.Add+tf.Change+tf.Destroy > 0 {
+					d.Status = DiffChanges
+				}
 
-				if op != Diff {
-					return nil
+				return d, nil
+			},
+			Output: output,
+			OutputFunc: func(r *Runtime, op Op, o Outputs) error {
+				var buf bytes.Buffer
+				if err := r.Exec(dir, []string{"terraform", "output", "-json"}, append([]ExecOption{ExecStdout(&buf)}, outputOpts...)...); err != nil {
+					return fmt.Errorf("terraform-output failed: %w", err)
 				}
 
-				type Output struct {
-					Name    string   `hcl:"name,label"`
-					Options hcl.Body `hcl:",remain"`
+				if err := terraformOutputs(buf.Bytes(), o); err != nil {
+					return err
 				}
 
-				type Config struct {
-					Outputs []Output `hcl:"output,block"`
-					Options hcl.Body `hcl:",remain"`
+				o["_raw"] = buf.String()
+
+				if op != Diff {
+					return nil
 				}
 
-				files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+				names, err := terraformOutputNames(dir)
 				if err != nil {
-					return fmt.Errorf("unable to glob %s/*.tf: %w", dir, err)
+					return err
 				}
 
-				for _, f := range files {
-					var config Config
-					err := decodeHCLFile(f, nil, &config)
-					if err != nil {
-						return fmt.Errorf("failed to load configuration %s: %v", f, err)
-					}
-					fmt.Fprintf(os.Stderr, "Decoded HCL config: %v\n", config)
-					for _, out := range config.Outputs {
-						if _, ok := o[out.Name]; !ok {
-							o[out.Name] = "<computed>"
-						}
+				for _, name := range names {
+					if _, ok := o[name]; !ok {
+						o[name] = computedOutput
 					}
 				}
 
@@ -438,10 +842,15 @@ func newDriver(id, dir string, c Component, opts Options) (*Driver, error) {
 		}
 
 		return &Driver{
-			Diff:   cmdsToSeq(diff),
-			Apply:  cmdsToSeq(apply),
-			Output: output,
-			OutputFunc: func(r *Runtime, op Op, o map[string]string) error {
+			Diff:    cmdsToSeq(diff),
+			Apply:   cmdsToSeq(apply),
+			Destroy: kubernetesDestroy(id, &kc, opts.TempDir),
+			Drift:   kubernetesDrift(&kc, diff),
+			// The kubernetes components deployed via gitops or argocd
+			// are reported as unknown, because their plan commands tell nothing about the changes.
+			DiffReport: kubernetesDiffReport(&kc, diff),
+			Output:     output,
+			OutputFunc: func(r *Runtime, op Op, o Outputs) error {
 				if op == Diff {
 					return nil
 				}
@@ -476,7 +885,7 @@ func newDriver(id, dir string, c Component, opts Options) (*Driver, error) {
 			Diff:   nil,
 			Apply:  nil,
 			Output: output,
-			OutputFunc: func(r *Runtime, op Op, o map[string]string) error {
+			OutputFunc: func(r *Runtime, op Op, o Outputs) error {
 				rt, err := vals.New(vals.Options{CacheSize: 512})
 				if err != nil {
 					return fmt.Errorf("unable to init vals: %w", err)
@@ -506,7 +915,7 @@ func newDriver(id, dir string, c Component, opts Options) (*Driver, error) {
 			Diff:   nil,
 			Apply:  nil,
 			Output: output,
-			OutputFunc: func(r *Runtime, op Op, o map[string]string) error {
+			OutputFunc: func(r *Runtime, op Op, o Outputs) error {
 				return nil
 			},
 		}, nil
@@ -519,7 +928,7 @@ func newDriver(id, dir string, c Component, opts Options) (*Driver, error) {
 		Diff:   nil,
 		Apply:  nil,
 		Output: output,
-		OutputFunc: func(r *Runtime, op Op, o map[string]string) error {
+		OutputFunc: func(r *Runtime, op Op, o Outputs) error {
 			return nil
 		},
 	}, nil
@@ -531,6 +940,20 @@ func Cmd(id, name string, opts ...cmd.Option) Task {
 	return cmdToStep(c)
 }
 
+// setup marks the task as a setup task.
+// See Task.Setup.
+func setup(t Task) Task {
+	t.Setup = true
+	return t
+}
+
+// changesExitCode sets the exit code of the task that means changes are detected.
+// See Task.ChangesExitCode.
+func changesExitCode(code int, t Task) Task {
+	t.ChangesExitCode = code
+	return t
+}
+
 func cmdToTask(cmd kargo.Cmd) Task {
 	return Task{
 		Run: []kargo.Cmd{cmd},
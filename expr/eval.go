@@ -0,0 +1,248 @@
+package expr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Scope is where the references within the expressions are resolved.
+type Scope struct {
+	// Output returns the value of the output of the component.
+	// It returns an error made by NotFound when the component has no such output,
+	// so that the default given via `??` is used instead.
+	Output func(component, output string) (interface{}, error)
+	// Env returns the value of the environment variable, and whether it is set.
+	// If nil, os.LookupEnv is used.
+	Env func(name string) (string, bool)
+}
+
+type notFoundError struct {
+	err error
+}
+
+func (e *notFoundError) Error() string {
+	return e.err.Error()
+}
+
+func (e *notFoundError) Unwrap() error {
+	return e.err
+}
+
+// NotFound marks err as the error for the missing value,
+// which makes `??` fall back to its default.
+func NotFound(err error) error {
+	return &notFoundError{err: err}
+}
+
+// IsNotFound reports whether err is made by NotFound.
+func IsNotFound(err error) bool {
+	var e *notFoundError
+	return errors.As(err, &e)
+}
+
+// Evaluate parses and evaluates the expression, and formats the result by String.
+func Evaluate(s string, scope Scope) (string, error) {
+	n, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	v, err := Eval(n, scope)
+	if err != nil {
+		return "", err
+	}
+
+	return String(v), nil
+}
+
+// Eval evaluates the expression.
+// The result is typed like the values decoded from JSON.
+func Eval(n Node, scope Scope) (interface{}, error) {
+	switch n := n.(type) {
+	case Literal:
+		return n.Value, nil
+	case Ref:
+		return scope.Output(n.Component, n.Output)
+	case Env:
+		lookup := scope.Env
+		if lookup == nil {
+			lookup = os.LookupEnv
+		}
+		v, ok := lookup(n.Name)
+		if !ok {
+			return nil, NotFound(fmt.Errorf("environment variable %q is not set", n.Name))
+		}
+		return v, nil
+	case Index:
+		x, err := Eval(n.X, scope)
+		if err != nil {
+			return nil, err
+		}
+		i, err := Eval(n.Index, scope)
+		if err != nil {
+			return nil, err
+		}
+		return index(x, i)
+	case Field:
+		x, err := Eval(n.X, scope)
+		if err != nil {
+			return nil, err
+		}
+		return index(x, n.Name)
+	case Call:
+		args := make([]interface{}, len(n.Args))
+		for i, a := range n.Args {
+			v, err := Eval(a, scope)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		return call(n.Func, args)
+	case Coalesce:
+		x, err := Eval(n.X, scope)
+		if err != nil && !IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil && x != nil && x != "" {
+			return x, nil
+		}
+		return Eval(n.Default, scope)
+	}
+
+	return nil, fmt.Errorf("unexpected node %T", n)
+}
+
+// index returns the i-th item of the list or the value for the key i of the map.
+// A string is parsed as JSON beforehand,
+// like the outputs of the CI jobs that are always strings.
+func index(x, i interface{}) (interface{}, error) {
+	if s, ok := x.(string); ok {
+		if err := json.Unmarshal([]byte(s), &x); err != nil {
+			return nil, fmt.Errorf("unable to index %q: it is not a JSON list or map", s)
+		}
+	}
+
+	switch x := x.(type) {
+	case []interface{}:
+		f, ok := i.(float64)
+		if !ok || f != math.Trunc(f) {
+			return nil, fmt.Errorf("list index must be an integer, but got %s", String(i))
+		}
+		if f < 0 || int(f) >= len(x) {
+			return nil, NotFound(fmt.Errorf("list index %d is out of range of %d items", int(f), len(x)))
+		}
+		return x[int(f)], nil
+	case map[string]interface{}:
+		k, ok := i.(string)
+		if !ok {
+			return nil, fmt.Errorf("map key must be a string, but got %s", String(i))
+		}
+		v, ok := x[k]
+		if !ok {
+			return nil, NotFound(fmt.Errorf("map key %q does not exist", k))
+		}
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("unable to index %s: it is not a list or map", String(x))
+}
+
+func call(f string, args []interface{}) (interface{}, error) {
+	switch f {
+	case FuncFormat:
+		format, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: the format must be a string, but got %s", f, String(args[0]))
+		}
+		strs := make([]string, len(args)-1)
+		for i, a := range args[1:] {
+			strs[i] = String(a)
+		}
+		return Format(format, strs...)
+	case FuncJSON:
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: the argument must be a string, but got %s", f, String(args[0]))
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("unknown function %q", f)
+}
+
+// Format replaces `{N}` in format with the N-th of args, counted from 0,
+// and `{{` and `}}` with `{` and `}` respectively,
+// like the format function of the GitHub Actions expressions.
+func Format(format string, args ...string) (string, error) {
+	var b strings.Builder
+
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+
+		switch {
+		case c == '{' && strings.HasPrefix(format[i:], "{{"):
+			b.WriteByte('{')
+			i++
+		case c == '}' && strings.HasPrefix(format[i:], "}}"):
+			b.WriteByte('}')
+			i++
+		case c == '{':
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("format %q: unterminated placeholder at %d", format, i)
+			}
+			n, err := strconv.Atoi(format[i+1 : i+end])
+			if err != nil || n < 0 {
+				return "", fmt.Errorf("format %q: invalid placeholder %q", format, format[i:i+end+1])
+			}
+			if n >= len(args) {
+				return "", fmt.Errorf("format %q: placeholder %q has no argument", format, format[i:i+end+1])
+			}
+			b.WriteString(args[n])
+			i += end
+		case c == '}':
+			return "", fmt.Errorf("format %q: unexpected } at %d", format, i)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// String formats the value to be passed to a command,
+// like a terraform var, a helm set, or a docker build arg.
+//
+// Strings are returned as is, and numbers are formatted without exponents,
+// like 3 rather than 3e+00.
+// Lists and maps are formatted in JSON with the keys sorted,
+// which terraform reads as the list and the object of the same values.
+func String(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return string(data)
+}
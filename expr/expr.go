@@ -0,0 +1,423 @@
+// Package expr implements the expressions used to refer to the outputs of other components,
+// like `valueFrom` of terraform vars.
+//
+// The simplest expression is a reference to an output like `infra.vpc_id`,
+// which is the component ID and the output name separated by the first dot.
+// The expressions are extended with:
+//
+//   - Indexing into lists and maps, like `infra.subnet_ids[0]` and `infra.tags["team"]`
+//   - Parsing JSON, like `json(infra._raw).vpc_id.value`
+//   - Formatting, like `format("{0}:{1}", image.repo, git.sha)`
+//   - Defaults, like `infra.domain ?? "example.com"`
+//   - Environment variables, like `env.AWS_REGION`, which is why no component can be named env
+//   - String, number, boolean, and null literals
+//
+// The syntax and the functions are modeled after the GitHub Actions expressions,
+// so that the expressions are evaluated the same way in the exported CI workflows.
+// The exception is `??`, which GitHub Actions does not have.
+// It is translated to `||` there, which behaves the same for the outputs, because they are strings in the workflows.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Node is a node of the syntax tree of an expression.
+type Node interface {
+	node()
+}
+
+// Literal is a string, a float64 number, a bool, or a nil null.
+type Literal struct {
+	Value interface{}
+}
+
+// Ref is the reference to the output of a component, like `infra.vpc_id`.
+// The output name is everything after the first dot,
+// so that the output names containing dots like `pullRequest.id` can be referenced as they are.
+type Ref struct {
+	Component string
+	Output    string
+}
+
+// Env is the reference to an environment variable, like `env.AWS_REGION`.
+type Env struct {
+	Name string
+}
+
+// Index is the indexing into a list or a map, like `infra.subnet_ids[0]`.
+type Index struct {
+	X     Node
+	Index Node
+}
+
+// Field is the access to a field of a map, like `.value` of `json(infra._raw).vpc_id.value`.
+type Field struct {
+	X    Node
+	Name string
+}
+
+// Call is the call to a function, either `format` or `json`.
+type Call struct {
+	Func string
+	Args []Node
+}
+
+// Coalesce is the default for the missing, null, or empty value, like `infra.domain ?? "example.com"`.
+type Coalesce struct {
+	X       Node
+	Default Node
+}
+
+func (Literal) node()  {}
+func (Ref) node()      {}
+func (Env) node()      {}
+func (Index) node()    {}
+func (Field) node()    {}
+func (Call) node()     {}
+func (Coalesce) node() {}
+
+const (
+	// FuncFormat replaces `{N}` in the first argument with the N-th of the rest of the arguments, counted from 0.
+	// `{{` and `}}` are replaced with `{` and `}` respectively.
+	FuncFormat = "format"
+	// FuncJSON parses the string argument as JSON.
+	FuncJSON = "json"
+)
+
+// EnvPrefix is the prefix of the references to environment variables.
+const EnvPrefix = "env"
+
+// Parse parses the expression.
+func Parse(s string) (Node, error) {
+	p := &parser{src: s}
+
+	if err := p.lex(); err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", s, err)
+	}
+
+	n, err := p.parseCoalesce()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", s, err)
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("invalid expression %q: unexpected %q at %d", s, t.text, t.pos)
+	}
+
+	return n, nil
+}
+
+// Refs returns the references to the outputs of the components within the expression,
+// in the order of appearance.
+func Refs(n Node) []Ref {
+	var refs []Ref
+
+	var visit func(n Node)
+	visit = func(n Node) {
+		switch n := n.(type) {
+		case Ref:
+			refs = append(refs, n)
+		case Index:
+			visit(n.X)
+			visit(n.Index)
+		case Field:
+			visit(n.X)
+		case Call:
+			for _, a := range n.Args {
+				visit(a)
+			}
+		case Coalesce:
+			visit(n.X)
+			visit(n.Default)
+		}
+	}
+
+	visit(n)
+
+	return refs
+}
+
+// ParseRefs parses the expression and returns the references to the outputs of the components within it.
+func ParseRefs(s string) ([]Ref, error) {
+	n, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return Refs(n), nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	// tokenPath is a reference like `infra.vpc_id` or `/product1/base.id`,
+	// a function name, a field name after a dot, or a keyword like `true`.
+	tokenPath
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	// value is the value of the string and number tokens.
+	value interface{}
+}
+
+type parser struct {
+	src    string
+	tokens []token
+	i      int
+}
+
+func isPathStart(c byte) bool {
+	return c == '_' || c == '/' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isPathChar(c byte) bool {
+	return isPathStart(c) || c == '.' || c == '-' || ('0' <= c && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func (p *parser) lex() error {
+	s := p.src
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(s[i:], "??"):
+			p.tokens = append(p.tokens, token{kind: tokenPunct, text: "??", pos: i})
+			i += 2
+		case strings.ContainsRune("()[],.", rune(c)):
+			// A dot is a punctuation only after a call or an index, like `json(x).value`,
+			// because the dots within paths are consumed along with the paths.
+			p.tokens = append(p.tokens, token{kind: tokenPunct, text: string(c), pos: i})
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return fmt.Errorf("unterminated string at %d", i)
+			}
+			v, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return fmt.Errorf("invalid string at %d: %w", i, err)
+			}
+			p.tokens = append(p.tokens, token{kind: tokenString, text: s[i : j+1], pos: i, value: v})
+			i = j + 1
+		case c == '\'':
+			j := strings.IndexByte(s[i+1:], '\'')
+			if j < 0 {
+				return fmt.Errorf("unterminated string at %d", i)
+			}
+			p.tokens = append(p.tokens, token{kind: tokenString, text: s[i : i+j+2], pos: i, value: s[i+1 : i+j+1]})
+			i += j + 2
+		case isDigit(c) || (c == '-' && i+1 < len(s) && isDigit(s[i+1])):
+			j := i + 1
+			for j < len(s) && (isDigit(s[j]) || s[j] == '.') {
+				j++
+			}
+			v, err := strconv.ParseFloat(s[i:j], 64)
+			if err != nil {
+				return fmt.Errorf("invalid number %q at %d", s[i:j], i)
+			}
+			p.tokens = append(p.tokens, token{kind: tokenNumber, text: s[i:j], pos: i, value: v})
+			i = j
+		case isPathStart(c):
+			j := i + 1
+			for j < len(s) && isPathChar(s[j]) {
+				j++
+			}
+			p.tokens = append(p.tokens, token{kind: tokenPath, text: s[i:j], pos: i})
+			i = j
+		default:
+			return fmt.Errorf("unexpected %q at %d", c, i)
+		}
+	}
+
+	p.tokens = append(p.tokens, token{kind: tokenEOF, text: "end of expression", pos: len(s)})
+
+	return nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) isPunct(s string) bool {
+	t := p.peek()
+	return t.kind == tokenPunct && t.text == s
+}
+
+func (p *parser) expect(s string) error {
+	if t := p.next(); t.kind != tokenPunct || t.text != s {
+		return fmt.Errorf("expected %q but got %q at %d", s, t.text, t.pos)
+	}
+	return nil
+}
+
+// parseCoalesce parses `x ?? y`, which is right-associative.
+func (p *parser) parseCoalesce() (Node, error) {
+	x, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isPunct("??") {
+		return x, nil
+	}
+
+	p.next()
+
+	d, err := p.parseCoalesce()
+	if err != nil {
+		return nil, err
+	}
+
+	return Coalesce{X: x, Default: d}, nil
+}
+
+// parsePostfix parses the indexing and the field access following a primary expression.
+func (p *parser) parsePostfix() (Node, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.isPunct("["):
+			p.next()
+			i, err := p.parseCoalesce()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = Index{X: x, Index: i}
+		case p.isPunct("."):
+			p.next()
+			t := p.next()
+			if t.kind != tokenPath || strings.Contains(t.text, "/") {
+				return nil, fmt.Errorf("expected a field name but got %q at %d", t.text, t.pos)
+			}
+			for _, name := range strings.Split(t.text, ".") {
+				if name == "" {
+					return nil, fmt.Errorf("empty field name in %q at %d", t.text, t.pos)
+				}
+				x = Field{X: x, Name: name}
+			}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenString, tokenNumber:
+		return Literal{Value: t.value}, nil
+	case tokenPunct:
+		if t.text != "(" {
+			break
+		}
+		x, err := p.parseCoalesce()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	case tokenPath:
+		if p.isPunct("(") {
+			return p.parseCall(t)
+		}
+
+		switch t.text {
+		case "true":
+			return Literal{Value: true}, nil
+		case "false":
+			return Literal{Value: false}, nil
+		case "null":
+			return Literal{Value: nil}, nil
+		}
+
+		component, output, ok := strings.Cut(t.text, ".")
+		if !ok || component == "" || output == "" {
+			return nil, fmt.Errorf("%q at %d must be a reference like component.output", t.text, t.pos)
+		}
+
+		if component == EnvPrefix {
+			return Env{Name: output}, nil
+		}
+
+		return Ref{Component: component, Output: output}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *parser) parseCall(name token) (Node, error) {
+	p.next()
+
+	var args []Node
+	for !p.isPunct(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		a, err := p.parseCoalesce()
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, a)
+	}
+
+	p.next()
+
+	switch name.text {
+	case FuncFormat:
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s at %d needs the format string", name.text, name.pos)
+		}
+	case FuncJSON:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s at %d needs exactly one argument", name.text, name.pos)
+		}
+	default:
+		return nil, fmt.Errorf("unknown function %q at %d", name.text, name.pos)
+	}
+
+	return Call{Func: name.text, Args: args}, nil
+}
@@ -0,0 +1,219 @@
+package expr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testcases := []struct {
+		expr string
+		want Node
+	}{
+		{
+			expr: "infra.vpc_id",
+			want: Ref{Component: "infra", Output: "vpc_id"},
+		},
+		{
+			expr: "app.pullRequest.id",
+			want: Ref{Component: "app", Output: "pullRequest.id"},
+		},
+		{
+			expr: "/product1/base.cluster_endpoint",
+			want: Ref{Component: "/product1/base", Output: "cluster_endpoint"},
+		},
+		{
+			expr: "infra.subnet_ids[0]",
+			want: Index{X: Ref{Component: "infra", Output: "subnet_ids"}, Index: Literal{Value: float64(0)}},
+		},
+		{
+			expr: `json(infra._raw).vpc_id.value`,
+			want: Field{
+				X: Field{
+					X:    Call{Func: FuncJSON, Args: []Node{Ref{Component: "infra", Output: "_raw"}}},
+					Name: "vpc_id",
+				},
+				Name: "value",
+			},
+		},
+		{
+			expr: `format('{0}:{1}', image.repo, git.sha)`,
+			want: Call{Func: FuncFormat, Args: []Node{
+				Literal{Value: "{0}:{1}"},
+				Ref{Component: "image", Output: "repo"},
+				Ref{Component: "git", Output: "sha"},
+			}},
+		},
+		{
+			expr: `infra.x ?? env.X ?? "none"`,
+			want: Coalesce{
+				X:       Ref{Component: "infra", Output: "x"},
+				Default: Coalesce{X: Env{Name: "X"}, Default: Literal{Value: "none"}},
+			},
+		},
+		{
+			expr: `(infra.tags)["team"]`,
+			want: Index{X: Ref{Component: "infra", Output: "tags"}, Index: Literal{Value: "team"}},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.expr, func(t *testing.T) {
+			got, err := Parse(tc.expr)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	testcases := []struct {
+		expr string
+		err  string
+	}{
+		{
+			expr: "infra",
+			err:  `invalid expression "infra": "infra" at 0 must be a reference like component.output`,
+		},
+		{
+			expr: "infra.x[0",
+			err:  `invalid expression "infra.x[0": expected "]" but got "end of expression" at 9`,
+		},
+		{
+			expr: `infra.x ?? "none`,
+			err:  `invalid expression "infra.x ?? \"none": unterminated string at 11`,
+		},
+		{
+			expr: `upper(infra.x)`,
+			err:  `invalid expression "upper(infra.x)": unknown function "upper" at 0`,
+		},
+		{
+			expr: `infra.x infra.y`,
+			err:  `invalid expression "infra.x infra.y": unexpected "infra.y" at 8`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, err := Parse(tc.expr)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestRefs(t *testing.T) {
+	refs, err := ParseRefs(`format("{0}-{1}", infra.subnet_ids[0], app.name ?? env.NAME)`)
+	require.NoError(t, err)
+	require.Equal(t, []Ref{
+		{Component: "infra", Output: "subnet_ids"},
+		{Component: "app", Output: "name"},
+	}, refs)
+}
+
+func TestEvaluate(t *testing.T) {
+	outputs := map[string]map[string]interface{}{
+		"infra": {
+			"vpc_id":     "vpc-1",
+			"subnet_ids": []interface{}{"subnet-1", "subnet-2"},
+			"tags":       map[string]interface{}{"team": "platform"},
+			"replicas":   float64(3),
+			"empty":      "",
+			"_raw":       `{"vpc_id":{"value":"vpc-1"},"azs":{"value":["a","b"]}}`,
+		},
+		"git": {
+			"sha": "abc123",
+		},
+	}
+
+	scope := Scope{
+		Output: func(component, output string) (interface{}, error) {
+			v, ok := outputs[component][output]
+			if !ok {
+				return nil, NotFound(errors.New("not found"))
+			}
+			return v, nil
+		},
+		Env: func(name string) (string, bool) {
+			if name == "REGION" {
+				return "us-east-1", true
+			}
+			return "", false
+		},
+	}
+
+	testcases := []struct {
+		expr string
+		want string
+	}{
+		{expr: "infra.vpc_id", want: "vpc-1"},
+		{expr: "infra.replicas", want: "3"},
+		{expr: "infra.subnet_ids", want: `["subnet-1","subnet-2"]`},
+		{expr: "infra.subnet_ids[1]", want: "subnet-2"},
+		{expr: `infra.tags["team"]`, want: "platform"},
+		{expr: "json(infra._raw).vpc_id.value", want: "vpc-1"},
+		{expr: `infra._raw["azs"]["value"][0]`, want: "a"},
+		{expr: `format("{0}:{1}", infra.vpc_id, git.sha)`, want: "vpc-1:abc123"},
+		{expr: `format("{{{0}}}", infra.replicas)`, want: "{3}"},
+		{expr: `infra.missing ?? "none"`, want: "none"},
+		{expr: `infra.empty ?? "none"`, want: "none"},
+		{expr: `infra.subnet_ids[5] ?? infra.subnet_ids[0]`, want: "subnet-1"},
+		{expr: `infra.vpc_id ?? "none"`, want: "vpc-1"},
+		{expr: "env.REGION", want: "us-east-1"},
+		{expr: `env.UNSET ?? "us-west-2"`, want: "us-west-2"},
+		{expr: "git.sha", want: "abc123"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.expr, func(t *testing.T) {
+			got, err := Evaluate(tc.expr, scope)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestEvaluate_Errors(t *testing.T) {
+	scope := Scope{
+		Output: func(component, output string) (interface{}, error) {
+			if component == "broken" {
+				return nil, errors.New("job \"broken\" does not exist")
+			}
+			if output == "id" {
+				return "x-1", nil
+			}
+			return nil, NotFound(errors.New("not found"))
+		},
+		Env: func(name string) (string, bool) {
+			return "", false
+		},
+	}
+
+	testcases := []struct {
+		expr string
+		err  string
+	}{
+		{expr: "app.missing", err: "not found"},
+		{expr: `broken.id ?? "none"`, err: `job "broken" does not exist`},
+		{expr: "app.id[0]", err: `unable to index "x-1": it is not a JSON list or map`},
+		{expr: "env.UNSET", err: `environment variable "UNSET" is not set`},
+		{expr: `format("{1}", app.id)`, err: `format "{1}": placeholder "{1}" has no argument`},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, err := Evaluate(tc.expr, scope)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	require.Equal(t, "", String(nil))
+	require.Equal(t, "a", String("a"))
+	require.Equal(t, "true", String(true))
+	require.Equal(t, "1.5", String(1.5))
+	require.Equal(t, "100000000", String(float64(1e8)))
+	require.Equal(t, `{"a":1,"b":[true]}`, String(map[string]interface{}{"b": []interface{}{true}, "a": float64(1)}))
+}
@@ -117,7 +117,7 @@ type AWSSecret struct {
 	// ID is the ID of the secret
 	// Examples:
 	// - myteam/mydoc
-	ID string `yaml:"arn"`
+	ID string `yaml:"id"`
 	// ARN is the ARN of the secret
 	// Examples:
 	// - arn:aws:secretsmanager:<REGION>:<ACCOUNT_ID>:secret:/myteam/mydoc
@@ -0,0 +1,104 @@
+package kanvas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/moby/patternmatcher"
+)
+
+// inputIgnores is the list of patterns for files in the dir that are not inputs of the job.
+// Those are the files written by kanvas and the tools it runs,
+// which would otherwise change the input fingerprint on every run.
+// The directories are matched at any depth, because the dir can contain other components,
+// like when the component is rooted at the base dir.
+var inputIgnores = []string{
+	"**/.git",
+	"**/.kanvas",
+	"**/.terraform",
+	"**/*.tfstate",
+	"**/*.tfstate.backup",
+	"**/*" + terraformPlanFileExt,
+	"**/*" + terraformPlanFileExt + terraformPlanFingerprintExt,
+}
+
+// Fingerprint returns the fingerprint of the resolved config of the job.
+//
+// The fingerprint covers the config of the component or the test after
+// the environment defaults and overrides are applied, the dir, and the needs.
+// It changes whenever the job would run differently given the same outputs of the jobs it needs,
+// except for the changes to the files in the dir.
+func (j *WorkflowJob) Fingerprint() (string, error) {
+	config, err := json.Marshal(j.config)
+	if err != nil {
+		return "", fmt.Errorf("marshaling config: %w", err)
+	}
+
+	h := sha256.New()
+
+	fmt.Fprintf(h, "config\x00%s\x00", config)
+	fmt.Fprintf(h, "dir\x00%s\x00", j.Dir)
+
+	for _, n := range j.Needs {
+		fmt.Fprintf(h, "need\x00%s\x00", n)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// InputFingerprint returns the fingerprint of all the inputs of the job.
+//
+// In addition to Fingerprint, it covers the contents of the files in the dir,
+// and inputs, which is the outputs of the jobs the job needs, keyed by the job IDs.
+// Applying the job again with the same input fingerprint is expected to change nothing.
+func (j *WorkflowJob) InputFingerprint(inputs map[string]Outputs) (string, error) {
+	fp, err := j.Fingerprint()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+
+	fmt.Fprintf(h, "fingerprint\x00%s\x00", fp)
+
+	if j.Dir != "" {
+		pm, err := patternmatcher.New(inputIgnores)
+		if err != nil {
+			return "", err
+		}
+
+		if err := hashDir(h, j.Dir, pm); err != nil {
+			return "", fmt.Errorf("hashing dir %q: %w", j.Dir, err)
+		}
+	}
+
+	ids := make([]string, 0, len(inputs))
+	for id := range inputs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		outputs := inputs[id]
+
+		// The bookkeeping outputs like the number of attempts change across the runs with the same inputs,
+		// which would otherwise make the dependents applied again for nothing.
+		keys := make([]string, 0, len(outputs))
+		for k := range outputs {
+			if !strings.HasPrefix(k, bookkeepingOutputPrefix) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fmt.Fprintf(h, "input\x00%s\x00%s\x00%s\x00", id, k, outputs.String(k))
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
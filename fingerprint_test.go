@@ -0,0 +1,83 @@
+package kanvas_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davinci-std/kanvas"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkflowJobFingerprint(t *testing.T) {
+	o := kanvas.Options{
+		TempDir: t.TempDir(),
+	}
+
+	fingerprints := func(c kanvas.Component) map[string]string {
+		t.Helper()
+
+		w, err := kanvas.NewWorkflow(c, o)
+		require.NoError(t, err)
+
+		fps := map[string]string{}
+		for id, job := range w.WorkflowJobs {
+			fp, err := job.Fingerprint()
+			require.NoError(t, err)
+			fps[id] = fp
+		}
+
+		return fps
+	}
+
+	c := newComponent()
+	before := fingerprints(c)
+	require.Equal(t, before, fingerprints(newComponent()), "the fingerprints must be stable")
+
+	deploy := c.Components["deploy"]
+	deploy.Kubernetes.Config.Kustomize.Git.Path = "path/to/another/dir"
+	c.Components["deploy"] = deploy
+
+	after := fingerprints(c)
+	require.NotEqual(t, before["deploy"], after["deploy"])
+	require.Equal(t, before["image"], after["image"])
+	require.Equal(t, before["prereq"], after["prereq"])
+}
+
+func TestWorkflowJobInputFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte("a"), 0644))
+
+	j := &kanvas.WorkflowJob{Dir: dir}
+	inputs := map[string]kanvas.Outputs{"image": {"id": "sha256:1"}}
+
+	fingerprint := func(inputs map[string]kanvas.Outputs) string {
+		t.Helper()
+
+		fp, err := j.InputFingerprint(inputs)
+		require.NoError(t, err)
+
+		return fp
+	}
+
+	before := fingerprint(inputs)
+	require.Equal(t, before, fingerprint(map[string]kanvas.Outputs{"image": {"id": "sha256:1"}}))
+	require.NotEqual(t, before, fingerprint(map[string]kanvas.Outputs{"image": {"id": "sha256:2"}}))
+	require.Equal(t, before, fingerprint(map[string]kanvas.Outputs{"image": {"id": "sha256:1", kanvas.OutputAttempts: "2"}}),
+		"the bookkeeping outputs must not change the fingerprint")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".kanvas", "cache"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".kanvas", "cache", "app.json"), []byte("{}"), 0644))
+	require.Equal(t, before, fingerprint(inputs), "the files written by kanvas must not change the fingerprint")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "infra"), 0755))
+	nested := fingerprint(inputs)
+	for _, d := range []string{".terraform", "infra/.terraform/providers", "infra/.git", "infra/.kanvas"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, d), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, d, "file"), []byte("x"), 0644))
+	}
+	require.Equal(t, nested, fingerprint(inputs), "the files written by the tools in the nested dirs must not change the fingerprint")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte("b"), 0644))
+	require.NotEqual(t, before, fingerprint(inputs))
+}
@@ -29,7 +29,7 @@ func newGitHubFilesDriver(conf *GitHubFiles) (*Driver, error) {
 	return &Driver{
 		Diff: []Task{
 			{
-				Func: func(j *WorkflowJob, _ map[string]string) error {
+				Func: func(j *WorkflowJob, _ Outputs) error {
 					vars := getJsonnetVars()
 					if len(vars) == 0 {
 						return fmt.Errorf("GitHubFiles driver requires GITHUB_REPOSITORY to be set to OWNER/REPO_NAME for the template to access `std.extVar(\"github_repo_name\")` and `std.extVar(\"github_repo_owner\")`")
@@ -40,7 +40,7 @@ func newGitHubFilesDriver(conf *GitHubFiles) (*Driver, error) {
 		},
 		Apply: []Task{
 			{
-				Func: func(j *WorkflowJob, _ map[string]string) error {
+				Func: func(j *WorkflowJob, _ Outputs) error {
 					vars := getJsonnetVars()
 					if len(vars) == 0 {
 						return fmt.Errorf("GitHubFiles driver requires GITHUB_REPOSITORY to be set to OWNER/REPO_NAME for the template to access `std.extVar(\"github_repo_name\")` and `std.extVar(\"github_repo_owner\")`")
@@ -50,7 +50,7 @@ func newGitHubFilesDriver(conf *GitHubFiles) (*Driver, error) {
 			},
 		},
 		Output: nil,
-		OutputFunc: func(r *Runtime, op Op, o map[string]string) error {
+		OutputFunc: func(r *Runtime, op Op, o Outputs) error {
 			return nil
 		},
 	}, nil
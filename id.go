@@ -30,8 +30,11 @@ func ID(components ...string) string {
 	for i, n := range components {
 		if n == "" {
 			continue
-		} else if n[0] == '/' {
+		} else if n[0] == '/' && i > 0 {
 			return normalize(n)
+		} else if n[0] == '/' {
+			// Absolute path as the first component is the namespace
+			// of the rest of the components.
 		} else if i == 0 {
 			n = "/" + n
 		}
@@ -19,3 +19,7 @@ func TestID(t *testing.T) {
 		require.Equal(t, "/bar/baz", ID("foo", "/bar/baz"))
 	})
 }
+
+func TestIDNamespaced(t *testing.T) {
+	require.Equal(t, "/preview/app/image", ID("/preview/app", "image"))
+}
@@ -0,0 +1,177 @@
+// schemadoc generates the Go file containing the doc comments of the types in the kanvas config,
+// so that `kanvas schema` can describe the fields in the JSON Schema.
+//
+// Usage:
+//
+//	go run ./internal/schemadoc schema_doc.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/davinci-std/kanvas"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s OUTPUT_FILE\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	if err := run(os.Args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(output string) error {
+	types := map[string]map[string]struct{}{}
+	collect(reflect.TypeOf(kanvas.Component{}), types)
+
+	pkgs := make([]string, 0, len(types))
+	for pkg := range types {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	descs := map[string]string{}
+	for _, pkg := range pkgs {
+		dir, err := packageDir(pkg)
+		if err != nil {
+			return err
+		}
+
+		if err := parseDocs(dir, types[pkg], descs); err != nil {
+			return err
+		}
+	}
+
+	keys := make([]string, 0, len(descs))
+	for k := range descs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by schemadoc. DO NOT EDIT.\n\n")
+	buf.WriteString("package kanvas\n\n")
+	buf.WriteString("// schemaDescriptions maps the types in the config and their fields to their doc comments,\n")
+	buf.WriteString("// like \"kanvas.Component\" and \"kanvas.Component.Dir\".\n")
+	buf.WriteString("var schemaDescriptions = map[string]string{\n")
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s: %s,\n", strconv.Quote(k), strconv.Quote(descs[k]))
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("formatting the generated code: %w", err)
+	}
+
+	return os.WriteFile(output, src, 0644)
+}
+
+// collect adds the named struct types reachable from t to types, keyed by their package paths.
+func collect(t reflect.Type, types map[string]map[string]struct{}) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct || t.Name() == "" {
+		return
+	}
+
+	if _, ok := types[t.PkgPath()][t.Name()]; ok {
+		return
+	}
+	if types[t.PkgPath()] == nil {
+		types[t.PkgPath()] = map[string]struct{}{}
+	}
+	types[t.PkgPath()][t.Name()] = struct{}{}
+
+	for i := 0; i < t.NumField(); i++ {
+		collect(t.Field(i).Type, types)
+	}
+}
+
+func packageDir(pkg string) (string, error) {
+	out, err := exec.Command("go", "list", "-f", "{{.Dir}}", pkg).Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to find the dir of %s: %w", pkg, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// parseDocs adds the doc comments of the types in the package in dir and their fields to descs.
+func parseDocs(dir string, types map[string]struct{}, descs map[string]string) error {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", dir, err)
+	}
+
+	for name, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, d := range f.Decls {
+				gd, ok := d.(*ast.GenDecl)
+				if !ok || gd.Tok != token.TYPE {
+					continue
+				}
+
+				for _, spec := range gd.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if _, ok := types[ts.Name.Name]; !ok {
+						continue
+					}
+
+					st, ok := ts.Type.(*ast.StructType)
+					if !ok {
+						continue
+					}
+
+					key := name + "." + ts.Name.Name
+
+					doc := ts.Doc
+					if doc == nil && len(gd.Specs) == 1 {
+						doc = gd.Doc
+					}
+					if text := docText(doc); text != "" {
+						descs[key] = text
+					}
+
+					for _, field := range st.Fields.List {
+						text := docText(field.Doc)
+						if text == "" {
+							continue
+						}
+						for _, n := range field.Names {
+							descs[key+"."+n.Name] = text
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func docText(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	return strings.TrimSpace(doc.Text())
+}
@@ -0,0 +1,189 @@
+package interpreter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/davinci-std/kanvas"
+
+	"golang.org/x/term"
+)
+
+// approve blocks until all the approval gates of the job are approved.
+//
+// A gate is approved when either:
+// - the gate is listed in the Approve option (--approve),
+// - the approval file for the gate exists, or
+// - the user answers yes to the interactive prompt.
+//
+// Before prompting, it runs the diff for all the jobs gated by the same gate,
+// and shows the accumulated diff so that the user knows what is going to be applied.
+func (p *Interpreter) approve(ctx context.Context, j *WorkflowJob) error {
+	for _, g := range j.Approvals {
+		if err := p.approveGate(ctx, g); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// approval is the result of asking for the approval of a gate,
+// shared by all the jobs gated by the gate.
+type approval struct {
+	once sync.Once
+	err  error
+}
+
+// approveGate asks for the approval of the gate only once,
+// and every job gated by the gate waits for the answer.
+//
+// approvalMu is held only while looking up the approval,
+// so that a gate never blocks the jobs gated by the other gates while diffing.
+func (p *Interpreter) approveGate(ctx context.Context, g kanvas.ApprovalGate) error {
+	p.approvalMu.Lock()
+	a, ok := p.approvals[g.ID]
+	if !ok {
+		a = &approval{}
+		p.approvals[g.ID] = a
+	}
+	p.approvalMu.Unlock()
+
+	a.once.Do(func() {
+		a.err = p.askApproval(ctx, g)
+	})
+
+	return a.err
+}
+
+func (p *Interpreter) askApproval(ctx context.Context, g kanvas.ApprovalGate) error {
+	for _, a := range p.Workflow.Options.Approve {
+		if a == g.ID {
+			fmt.Fprintf(os.Stderr, "Approval gate %q is approved via options\n", g.ID)
+			return nil
+		}
+	}
+
+	file := p.approvalFile(g)
+	if _, err := os.Stat(file); err == nil {
+		// The approval file is consumed so that
+		// it does not approve the future runs unexpectedly.
+		if err := os.Remove(file); err != nil {
+			return fmt.Errorf("unable to remove approval file %s: %w", file, err)
+		}
+		fmt.Fprintf(os.Stderr, "Approval gate %q is approved via %s\n", g.ID, file)
+		return nil
+	}
+
+	// There is no point in diffing the gated jobs when nobody can answer the prompt.
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("approval gate %q is not approved: rerun with --approve %s, or create %s to approve it", g.ID, g.ID, file)
+	}
+
+	diff, err := p.approvalDiff(ctx, g)
+	if err != nil {
+		return err
+	}
+
+	// The prompts for the gates approved concurrently are shown one by one.
+	p.promptMu.Lock()
+	defer p.promptMu.Unlock()
+
+	fmt.Fprintf(os.Stderr, "Diff to be applied after approval gate %q:\n%s", g.ID, diff)
+
+	fmt.Fprintf(os.Stderr, "Do you approve applying %q? [y/N]: ", g.ID)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("reading answer for approval gate %q: %w", g.ID, err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return fmt.Errorf("approval gate %q is rejected", g.ID)
+	}
+}
+
+// approvalDiff runs the diff for all the jobs gated by the gate, and returns the accumulated diff.
+// The preview of the job is run instead of the diff when the job has one,
+// so that e.g. the saved terraform plans are shown as is rather than planned again.
+//
+// The jobs are owned by the scheduler, which may be running some of them concurrently.
+// So the diff is run for the copies of the jobs, with their own outputs and output writer.
+// The copies refer to the outputs of each other, and to the outputs of the other jobs done so far.
+func (p *Interpreter) approvalDiff(ctx context.Context, g kanvas.ApprovalGate) (string, error) {
+	ids := p.gatedJobs(g.ID)
+
+	scope := make(map[string]*WorkflowJob, len(p.WorkflowJobs))
+	for id, job := range p.WorkflowJobs {
+		scope[id] = job
+	}
+
+	var diff bytes.Buffer
+
+	for _, id := range ids {
+		job := p.WorkflowJobs[id]
+
+		copied := &WorkflowJob{
+			ID:          job.ID,
+			Outputs:     kanvas.Outputs{},
+			output:      &diff,
+			scope:       scope,
+			WorkflowJob: job.WorkflowJob,
+		}
+		scope[id] = copied
+
+		fmt.Fprintf(&diff, "--- %s\n", id)
+
+		steps := job.Driver.Preview
+		if len(steps) == 0 {
+			steps = job.Driver.Diff
+		}
+
+		if err := p.runWithExtraArgs(ctx, copied, kanvas.Diff, steps); err != nil {
+			return "", fmt.Errorf("diffing %q for approval gate %q: %w", id, g.ID, err)
+		}
+	}
+
+	return diff.String(), nil
+}
+
+// approvalFile returns the path to the file that approves the gate when exists.
+func (p *Interpreter) approvalFile(g kanvas.ApprovalGate) string {
+	return p.storePath("approvals", filepath.FromSlash(g.Path()))
+}
+
+// gatedJobs returns the IDs of the jobs gated by the gate, in the order of the plan,
+// so that each job is diffed after the jobs whose outputs it refers to.
+func (p *Interpreter) gatedJobs(gate string) []string {
+	var ids []string
+	for _, phase := range p.Workflow.Plan {
+		phase = append([]string{}, phase...)
+		sort.Strings(phase)
+
+		for _, id := range phase {
+			j, ok := p.WorkflowJobs[id]
+			if !ok {
+				continue
+			}
+
+			for _, g := range j.Approvals {
+				if g.ID == gate {
+					ids = append(ids, id)
+					break
+				}
+			}
+		}
+	}
+
+	return ids
+}
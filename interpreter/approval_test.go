@@ -0,0 +1,115 @@
+package interpreter
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/davinci-std/kanvas"
+	"github.com/mumoshu/kargo"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpreterApprovalDiff(t *testing.T) {
+	p := newTestInterpreter(kanvas.Options{}, map[string][]string{"app": {"infra"}}, []string{"infra"}, []string{"app"})
+
+	gate := kanvas.ApprovalGate{ID: "production"}
+
+	p.WorkflowJobs["infra"].Approvals = []kanvas.ApprovalGate{gate}
+	p.WorkflowJobs["infra"].Driver = &kanvas.Driver{
+		OutputFunc: func(r *kanvas.Runtime, op kanvas.Op, o kanvas.Outputs) error {
+			o["endpoint"] = "https://example.com"
+			return nil
+		},
+	}
+
+	var args *kargo.Args
+	args = args.AppendValueFromOutputWithPrefix("endpoint=", "infra.endpoint")
+
+	var got []string
+
+	p.WorkflowJobs["app"].Approvals = []kanvas.ApprovalGate{gate}
+	p.WorkflowJobs["app"].Driver = &kanvas.Driver{
+		Diff: []kanvas.Task{
+			{
+				Args: args,
+				Exec: func(r *kanvas.Runtime, args []string, o kanvas.Outputs) error {
+					got = args
+					return nil
+				},
+			},
+		},
+	}
+
+	diff, err := p.approvalDiff(context.Background(), gate)
+	require.NoError(t, err)
+	require.Equal(t, "--- infra\n--- app\n", diff)
+	require.Equal(t, []string{"endpoint=https://example.com"}, got)
+
+	// The jobs owned by the scheduler are left untouched.
+	for _, id := range []string{"infra", "app"} {
+		require.Empty(t, p.WorkflowJobs[id].Outputs, id)
+		require.Nil(t, p.WorkflowJobs[id].output, id)
+	}
+}
+
+func TestInterpreterApprovalDiff_Preview(t *testing.T) {
+	p := newTestInterpreter(kanvas.Options{}, nil, []string{"infra"})
+
+	gate := kanvas.ApprovalGate{ID: "env:production"}
+
+	var ran []string
+
+	task := func(name string) kanvas.Task {
+		return kanvas.Task{
+			Func: func(job *kanvas.WorkflowJob, o kanvas.Outputs) error {
+				ran = append(ran, name)
+				return nil
+			},
+		}
+	}
+
+	p.WorkflowJobs["infra"].Approvals = []kanvas.ApprovalGate{gate}
+	p.WorkflowJobs["infra"].Driver = &kanvas.Driver{
+		Diff:    []kanvas.Task{task("diff")},
+		Preview: []kanvas.Task{task("preview")},
+	}
+
+	_, err := p.approvalDiff(context.Background(), gate)
+	require.NoError(t, err)
+	require.Equal(t, []string{"preview"}, ran, "the preview must be run instead of the diff, which would overwrite the saved plans")
+}
+
+func TestInterpreterApprovalFile(t *testing.T) {
+	p := newTestInterpreter(kanvas.Options{}, nil, []string{"infra"})
+	p.Workflow.Dir = "/work"
+
+	require.Equal(t, filepath.FromSlash("/work/.kanvas/approvals/env/production"), p.approvalFile(kanvas.ApprovalGate{ID: "env:production"}))
+	require.Equal(t, filepath.FromSlash("/work/.kanvas/approvals/components/production"), p.approvalFile(kanvas.ApprovalGate{ID: "/production"}))
+}
+
+func TestInterpreterApproveGate_NotTerminal(t *testing.T) {
+	p := newTestInterpreter(kanvas.Options{}, nil, []string{"infra"})
+	p.Workflow.Dir = t.TempDir()
+
+	gate := kanvas.ApprovalGate{ID: "production"}
+
+	var diffed bool
+
+	p.WorkflowJobs["infra"].Approvals = []kanvas.ApprovalGate{gate}
+	p.WorkflowJobs["infra"].Driver = &kanvas.Driver{
+		Diff: []kanvas.Task{
+			{
+				Func: func(job *kanvas.WorkflowJob, o kanvas.Outputs) error {
+					diffed = true
+					return nil
+				},
+			},
+		},
+	}
+
+	err := p.approveGate(context.Background(), gate)
+	require.ErrorContains(t, err, `approval gate "production" is not approved`)
+	require.False(t, diffed, "the gated jobs must not be diffed when nobody can approve the gate")
+}
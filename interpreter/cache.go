@@ -0,0 +1,76 @@
+package interpreter
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/davinci-std/kanvas"
+)
+
+// cacheEntry is the input fingerprint and the outputs of the last successful apply of a job.
+type cacheEntry struct {
+	Fingerprint string         `json:"fingerprint"`
+	Outputs     kanvas.Outputs `json:"outputs"`
+}
+
+// cacheFile returns the path to the cache entry of the job.
+// The entries are per environment, because the same job ID is used across environments.
+func (p *Interpreter) cacheFile(id string) string {
+	return p.storePath("cache", p.Workflow.Options.Env, storeName(id)+".json")
+}
+
+// inputFingerprint returns the input fingerprint of the job,
+// given the outputs of the jobs it needs.
+func (p *Interpreter) inputFingerprint(j *WorkflowJob) (string, error) {
+	inputs := map[string]kanvas.Outputs{}
+	for _, n := range j.Needs {
+		if job, ok := p.WorkflowJobs[n]; ok {
+			inputs[n] = job.Outputs
+		}
+	}
+
+	return j.InputFingerprint(inputs)
+}
+
+// cached returns the outputs of the last successful apply of the job,
+// when the job is up to date, that is, its input fingerprint matches the one of the last successful apply.
+// It returns nil when the job needs to be applied.
+func (p *Interpreter) cached(j *WorkflowJob, fp string) kanvas.Outputs {
+	var e cacheEntry
+	ok, err := readJSON(p.cacheFile(j.ID), &e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read the cache of component %q: %v\n", j.ID, err)
+		return nil
+	} else if !ok {
+		return nil
+	}
+
+	if e.Fingerprint != fp {
+		return nil
+	}
+
+	if e.Outputs == nil {
+		e.Outputs = kanvas.Outputs{}
+	}
+
+	return e.Outputs
+}
+
+// invalidateCache removes the cache entry of the job,
+// so that a failed apply is never mistaken as up to date.
+func (p *Interpreter) invalidateCache(j *WorkflowJob) error {
+	if err := removeFile(p.cacheFile(j.ID)); err != nil {
+		return fmt.Errorf("removing cache: %w", err)
+	}
+
+	return nil
+}
+
+// saveCache records the input fingerprint and the outputs of the successful apply of the job.
+func (p *Interpreter) saveCache(j *WorkflowJob, fp string) error {
+	if err := writeJSON(p.cacheFile(j.ID), cacheEntry{Fingerprint: fp, Outputs: j.Outputs}); err != nil {
+		return fmt.Errorf("writing cache: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package interpreter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davinci-std/kanvas"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpreterApply_Cache(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte("a"), 0644))
+
+	var ran int
+
+	apply := func(opts kanvas.Options, alwaysRun bool) {
+		t.Helper()
+
+		opts.Parallelism = 1
+
+		p := newTestInterpreter(opts, nil, []string{"a"})
+		p.Workflow.Dir = dir
+
+		a := p.WorkflowJobs["a"]
+		a.Dir = dir
+		a.AlwaysRun = alwaysRun
+		a.Driver = &kanvas.Driver{
+			Apply: []kanvas.Task{
+				{
+					Func: func(job *kanvas.WorkflowJob, o kanvas.Outputs) error {
+						ran++
+						o["id"] = "a-1"
+						return nil
+					},
+				},
+			},
+		}
+
+		require.NoError(t, p.Apply(context.Background()))
+		require.Equal(t, kanvas.Outputs{"id": "a-1"}, a.Outputs)
+	}
+
+	apply(kanvas.Options{}, false)
+	require.Equal(t, 1, ran)
+
+	apply(kanvas.Options{}, false)
+	require.Equal(t, 1, ran, "the up-to-date component must be skipped")
+
+	apply(kanvas.Options{Force: true}, false)
+	require.Equal(t, 2, ran)
+
+	apply(kanvas.Options{}, true)
+	require.Equal(t, 3, ran)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte("b"), 0644))
+
+	apply(kanvas.Options{}, false)
+	require.Equal(t, 4, ran)
+
+	apply(kanvas.Options{Env: "prod"}, false)
+	require.Equal(t, 5, ran, "the cache must be per environment")
+}
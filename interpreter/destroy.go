@@ -0,0 +1,83 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/davinci-std/kanvas"
+)
+
+// Destroy destroys what the components deployed, in the reverse order of apply.
+//
+// Each component is destroyed only after all the components that need it are destroyed.
+// Before destroying anything, the outputs of all the components are read in the order of apply,
+// because the components need the outputs of the components they need to destroy themselves,
+// like the variables passed to terraform destroy.
+func (p *Interpreter) Destroy(ctx context.Context) error {
+	if err := p.Run(ctx, func(ctx context.Context, job *WorkflowJob) error {
+		return p.readOutputsForDestroy(ctx, job)
+	}); err != nil {
+		return fmt.Errorf("reading outputs: %w", err)
+	}
+
+	return p.runAll(ctx, true, func(ctx context.Context, job *WorkflowJob) error {
+		return p.destroyJob(ctx, job)
+	})
+}
+
+// DestroyOrder returns the IDs of the components to be destroyed, in the order they are destroyed
+// when the components are destroyed one by one.
+func (p *Interpreter) DestroyOrder() []string {
+	var ids []string
+
+	for i := len(p.Workflow.Plan) - 1; i >= 0; i-- {
+		for _, id := range p.Workflow.Plan[i] {
+			j := p.WorkflowJobs[id]
+			if j.Skipped != nil || j.OutputOnly || len(j.Driver.Destroy) == 0 {
+				continue
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// readOutputsForDestroy reads the outputs of the job like apply would produce,
+// running only the setup tasks of apply like terraform init.
+func (p *Interpreter) readOutputsForDestroy(ctx context.Context, j *WorkflowJob) error {
+	var setup []kanvas.Task
+	for _, t := range j.Driver.Apply {
+		if t.Setup {
+			setup = append(setup, t)
+		}
+	}
+
+	return p.runWithExtraArgs(ctx, j, kanvas.Apply, setup)
+}
+
+func (p *Interpreter) destroyJob(ctx context.Context, j *WorkflowJob) error {
+	if j.OutputOnly || len(j.Driver.Destroy) == 0 {
+		return nil
+	}
+
+	// The outputs read before destroying are visible to the other jobs
+	// while running the destroy tasks, which reset the outputs of the job.
+	outputs := j.Outputs
+
+	if err := p.runWithRetry(ctx, j, kanvas.Destroy, j.Driver.Destroy); err != nil {
+		return err
+	}
+
+	j.Outputs = outputs
+
+	// The component needs to be applied again, even though its inputs have not changed.
+	if err := p.invalidateCache(j); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Component %q is destroyed\n", j.ID)
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package interpreter
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/davinci-std/kanvas"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpreterDestroy(t *testing.T) {
+	for _, phased := range []bool{false, true} {
+		phased := phased
+		t.Run(map[bool]string{false: "dag", true: "phased"}[phased], func(t *testing.T) {
+			p := newTestInterpreter(
+				kanvas.Options{Phased: phased},
+				map[string][]string{"b": {"a"}, "c": {"b"}, "d": {"a"}},
+				[]string{"a"}, []string{"b", "d"}, []string{"c"},
+			)
+			p.Workflow.Dir = t.TempDir()
+
+			var (
+				mu        sync.Mutex
+				destroyed []string
+			)
+
+			for id, j := range p.WorkflowJobs {
+				id, j := id, j
+				j.Driver = &kanvas.Driver{
+					OutputFunc: func(r *kanvas.Runtime, op kanvas.Op, o kanvas.Outputs) error {
+						o["name"] = id + "-1"
+						return nil
+					},
+				}
+
+				// d has nothing to destroy
+				if id == "d" {
+					continue
+				}
+
+				j.Driver.Destroy = []kanvas.Task{
+					{
+						Func: func(job *kanvas.WorkflowJob, o kanvas.Outputs) error {
+							for _, n := range job.Needs {
+								require.Equal(t, n+"-1", p.WorkflowJobs[n].Outputs["name"], "the outputs of %q must be read before destroying %q", n, id)
+							}
+
+							mu.Lock()
+							destroyed = append(destroyed, id)
+							mu.Unlock()
+
+							return nil
+						},
+					},
+				}
+			}
+
+			cache := p.cacheFile("a")
+			require.NoError(t, p.saveCache(p.WorkflowJobs["a"], "fp"))
+
+			require.Equal(t, []string{"c", "b", "a"}, p.DestroyOrder())
+			require.NoError(t, p.Destroy(context.Background()))
+			require.Equal(t, []string{"c", "b", "a"}, destroyed)
+
+			_, err := os.Stat(cache)
+			require.True(t, os.IsNotExist(err), "the cache of the destroyed component must be removed")
+		})
+	}
+}
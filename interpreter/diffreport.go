@@ -0,0 +1,100 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/davinci-std/kanvas"
+)
+
+// DiffReport runs the diff like Diff, and returns the structured diff of each component
+// produced by its driver from the output of the diff commands.
+//
+// The components whose drivers cannot tell whether there are changes are reported as kanvas.DiffUnknown.
+// The components whose drivers have nothing to diff, like the git component, are not reported.
+// Set the DiffReport option of the workflow to make the drivers prepare for the report,
+// like saving the terraform plans to be read by terraform show.
+func (p *Interpreter) DiffReport(ctx context.Context) (*kanvas.DiffReport, error) {
+	var (
+		mu         sync.Mutex
+		components []kanvas.ComponentDiff
+	)
+
+	if err := p.Run(ctx, func(ctx context.Context, job *WorkflowJob) error {
+		if job.OutputOnly || len(job.Driver.Diff) == 0 {
+			return p.diffJob(ctx, job)
+		}
+
+		d, err := p.diffJobReport(ctx, job)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		components = append(components, *d)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(components, func(i, j int) bool {
+		return components[i].ID < components[j].ID
+	})
+
+	if components == nil {
+		components = []kanvas.ComponentDiff{}
+	}
+
+	return &kanvas.DiffReport{
+		Env:        p.Workflow.Options.Env,
+		Components: components,
+	}, nil
+}
+
+// diffJobReport diffs the job while capturing the output of the diff commands,
+// and returns the structured diff of the job.
+func (p *Interpreter) diffJobReport(ctx context.Context, j *WorkflowJob) (*kanvas.ComponentDiff, error) {
+	var buf bytes.Buffer
+
+	// The output is still written to where it was,
+	// so that the diff is as readable as the one without the report.
+	orig := j.output
+	out := orig
+	if out == nil {
+		out = os.Stderr
+	}
+	j.output = io.MultiWriter(out, &buf)
+	defer func() {
+		j.output = orig
+	}()
+
+	if err := p.diffJob(ctx, j); err != nil {
+		return nil, err
+	}
+
+	var d *kanvas.ComponentDiff
+
+	if f := j.Driver.DiffReport; f != nil {
+		var err error
+		d, err = f(p.runtime.WithContext(ctx), j.Outputs, buf.String())
+		if err != nil {
+			return nil, fmt.Errorf("reporting diff: %w", err)
+		}
+	}
+
+	if d == nil {
+		d = &kanvas.ComponentDiff{Status: kanvas.DiffUnknown}
+	}
+
+	d.ID = j.ID
+
+	return d, nil
+}
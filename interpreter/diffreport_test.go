@@ -0,0 +1,59 @@
+package interpreter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/davinci-std/kanvas"
+	"github.com/mumoshu/kargo/cmd"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpreterDiffReport(t *testing.T) {
+	p := newTestInterpreter(
+		kanvas.Options{Env: "prod", DiffReport: true},
+		map[string][]string{"app": {"infra"}},
+		[]string{"infra", "unknown", "git"},
+		[]string{"app"},
+	)
+
+	echo := func(s string) []kanvas.Task {
+		return []kanvas.Task{kanvas.Cmd("echo", "echo", cmd.Args(s))}
+	}
+
+	// report reports the changes when the diff printed "changed",
+	// so that we can verify the report is given the output of the diff of the component.
+	report := func(_ *kanvas.Runtime, _ kanvas.Outputs, out string) (*kanvas.ComponentDiff, error) {
+		status := kanvas.DiffNoChanges
+		if strings.TrimSpace(out) == "changed" {
+			status = kanvas.DiffChanges
+		}
+		return &kanvas.ComponentDiff{Status: status}, nil
+	}
+
+	p.WorkflowJobs["infra"].Driver = &kanvas.Driver{Diff: echo("unchanged"), DiffReport: report}
+	p.WorkflowJobs["app"].Driver = &kanvas.Driver{Diff: echo("changed"), DiffReport: report}
+	p.WorkflowJobs["unknown"].Driver = &kanvas.Driver{Diff: echo("changed")}
+	p.WorkflowJobs["git"].Driver = &kanvas.Driver{}
+
+	r, err := p.DiffReport(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, "prod", r.Env)
+
+	var statuses []string
+	for _, c := range r.Components {
+		statuses = append(statuses, c.ID+"="+c.Status)
+	}
+	require.Equal(t, []string{
+		"app=changes",
+		"infra=no-changes",
+		"unknown=unknown",
+	}, statuses)
+
+	for _, j := range p.WorkflowJobs {
+		require.IsType(t, &prefixWriter{}, j.output, "the output of %q must be restored", j.ID)
+	}
+}
@@ -0,0 +1,174 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"sync"
+
+	"github.com/davinci-std/kanvas"
+)
+
+const (
+	// DriftNoChanges is the status of the component that is up to date with its config.
+	DriftNoChanges = "no-changes"
+	// DriftChanges is the status of the component that applying it would make changes.
+	DriftChanges = "changes"
+	// DriftError is the status of the component whose drift could not be detected due to an error.
+	DriftError = "error"
+	// DriftUnsupported is the status of the component whose driver does not support detecting drift,
+	// like the kubernetes component deployed via gitops.
+	DriftUnsupported = "unsupported"
+)
+
+// DriftReport is the result of Drift.
+type DriftReport struct {
+	// Env is the environment the drift is detected for.
+	Env string `json:"env"`
+	// Drifted is true when any of the components has changes.
+	Drifted bool `json:"drifted"`
+	// Failed is true when the drift of any of the components could not be detected due to an error.
+	Failed bool `json:"failed"`
+	// Components is the drift status of each component, sorted by the component ID.
+	Components []ComponentDrift `json:"components"`
+}
+
+// ComponentDrift is the drift status of a component.
+type ComponentDrift struct {
+	// ID is the ID of the component.
+	ID string `json:"id"`
+	// Status is either DriftNoChanges, DriftChanges, DriftError, or DriftUnsupported.
+	Status string `json:"status"`
+	// Error is the error that occurred while detecting the drift, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Drift detects the changes applying the components would make, by running their read-only drift tasks,
+// like `terraform plan -detailed-exitcode`.
+//
+// Unlike Diff, a component that failed does not stop the others.
+// It is reported as DriftError, along with the components that need it.
+// The components whose drivers have nothing to diff, like the git component, are not reported.
+func (p *Interpreter) Drift(ctx context.Context) (*DriftReport, error) {
+	var (
+		mu         sync.Mutex
+		components []ComponentDrift
+	)
+
+	failed := map[string]struct{}{}
+
+	report := func(id, status string, err error) {
+		d := ComponentDrift{ID: id, Status: status}
+		if err != nil {
+			d.Error = err.Error()
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		components = append(components, d)
+		if status == DriftError {
+			failed[id] = struct{}{}
+		}
+	}
+
+	hasFailed := func(id string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		_, ok := failed[id]
+		return ok
+	}
+
+	if err := p.Run(ctx, func(ctx context.Context, job *WorkflowJob) error {
+		for _, n := range job.Needs {
+			if hasFailed(n) {
+				report(job.ID, DriftError, fmt.Errorf("the drift of %q needed by this component could not be detected", n))
+				return nil
+			}
+		}
+
+		status, err := p.driftJob(ctx, job)
+		if err != nil {
+			// The interruption like timeouts fails the whole drift detection
+			// rather than being reported as a failure of the component.
+			if ctx.Err() != nil {
+				return err
+			}
+
+			status = DriftError
+		}
+
+		if status != "" {
+			report(job.ID, status, err)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(components, func(i, j int) bool {
+		return components[i].ID < components[j].ID
+	})
+
+	r := &DriftReport{
+		Env:        p.Workflow.Options.Env,
+		Components: components,
+	}
+
+	if r.Components == nil {
+		r.Components = []ComponentDrift{}
+	}
+
+	for _, c := range r.Components {
+		switch c.Status {
+		case DriftChanges:
+			r.Drifted = true
+		case DriftError:
+			r.Failed = true
+		}
+	}
+
+	return r, nil
+}
+
+// driftJob detects the drift of the job, and returns its status.
+// It returns an empty status for the job that has nothing to diff,
+// or the job whose outputs are only read for the selected jobs.
+func (p *Interpreter) driftJob(ctx context.Context, j *WorkflowJob) (string, error) {
+	if j.OutputOnly || len(j.Driver.Diff) == 0 {
+		return "", p.readOutputs(ctx, j, kanvas.Diff)
+	}
+
+	if len(j.Driver.Drift) == 0 {
+		// The outputs are still needed by the jobs that need this job.
+		if err := p.readOutputs(ctx, j, kanvas.Diff); err != nil {
+			return "", err
+		}
+		return DriftUnsupported, nil
+	}
+
+	if err := p.runWithRetry(ctx, j, kanvas.Diff, j.Driver.Drift); err != nil {
+		return "", err
+	}
+
+	if j.Outputs[kanvas.OutputChanges] == "true" {
+		return DriftChanges, nil
+	}
+
+	return DriftNoChanges, nil
+}
+
+// exitCode returns the exit code of the command that failed with err,
+// or -1 when err is not from the command that exited.
+func exitCode(err error) int {
+	var e *exec.ExitError
+	if errors.As(err, &e) {
+		return e.ExitCode()
+	}
+
+	return -1
+}
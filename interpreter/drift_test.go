@@ -0,0 +1,54 @@
+package interpreter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/davinci-std/kanvas"
+	"github.com/mumoshu/kargo/cmd"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpreterDrift(t *testing.T) {
+	p := newTestInterpreter(
+		kanvas.Options{Env: "prod"},
+		map[string][]string{"changed": {"unchanged"}, "dependent": {"failed"}},
+		[]string{"unchanged", "failed", "unsupported", "git"},
+		[]string{"changed", "dependent"},
+	)
+
+	exit := func(code string) []kanvas.Task {
+		t := kanvas.Cmd("check", "sh", cmd.Args("-c", "exit "+code))
+		t.ChangesExitCode = 2
+		return []kanvas.Task{t}
+	}
+
+	diff := []kanvas.Task{{}}
+
+	p.WorkflowJobs["unchanged"].Driver = &kanvas.Driver{Diff: diff, Drift: exit("0")}
+	p.WorkflowJobs["changed"].Driver = &kanvas.Driver{Diff: diff, Drift: exit("2")}
+	p.WorkflowJobs["failed"].Driver = &kanvas.Driver{Diff: diff, Drift: exit("1")}
+	p.WorkflowJobs["dependent"].Driver = &kanvas.Driver{Diff: diff, Drift: exit("0")}
+	p.WorkflowJobs["unsupported"].Driver = &kanvas.Driver{Diff: diff}
+	p.WorkflowJobs["git"].Driver = &kanvas.Driver{}
+
+	r, err := p.Drift(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, "prod", r.Env)
+	require.True(t, r.Drifted)
+	require.True(t, r.Failed)
+
+	var statuses []string
+	for _, c := range r.Components {
+		statuses = append(statuses, c.ID+"="+c.Status)
+	}
+	require.Equal(t, []string{
+		"changed=changes",
+		"dependent=error",
+		"failed=error",
+		"unchanged=no-changes",
+		"unsupported=unsupported",
+	}, statuses)
+}
@@ -1,22 +1,46 @@
 package interpreter
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/davinci-std/kanvas"
+	"github.com/davinci-std/kanvas/expr"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/mumoshu/kargo"
+	"golang.org/x/sync/errgroup"
 )
 
 type WorkflowJob struct {
 	ID      string
-	Outputs map[string]string
+	Outputs kanvas.Outputs
 	Ran     bool
 
+	// output is where the output of the commands run for the job is written to.
+	// If nil, the output is written to the standard error.
+	output io.Writer
+
+	// inputFingerprint is the input fingerprint of the job computed before applying it.
+	// It is empty when the job is not applied yet, or it always runs.
+	inputFingerprint string
+
+	// attempts is the attempts of the last run of the job with a retry policy.
+	attempts []Attempt
+
+	// scope is the jobs whose outputs the job refers to.
+	// If nil, the outputs are read from Interpreter.WorkflowJobs.
+	scope map[string]*WorkflowJob
+
 	*kanvas.WorkflowJob
 }
 
@@ -25,7 +49,23 @@ type Interpreter struct {
 	WorkflowJobs map[string]*WorkflowJob
 	runtime      *kanvas.Runtime
 
-	EnableParallel bool
+	// Parallelism is the maximum number of jobs to run concurrently within each phase.
+	// The jobs are run one by one when this is 1.
+	Parallelism int
+
+	// outputMu serializes the writes to the standard error
+	// from the jobs running concurrently.
+	outputMu sync.Mutex
+
+	approvalMu sync.Mutex
+	approvals  map[string]*approval
+	// promptMu serializes the interactive prompts for the approval gates.
+	promptMu sync.Mutex
+
+	// state is the state of the apply being run, persisted so that the apply can be resumed.
+	// It is nil for diffs.
+	state   *RunState
+	stateMu sync.Mutex
 }
 
 func New(wf *kanvas.Workflow, r *kanvas.Runtime) *Interpreter {
@@ -34,21 +74,65 @@ func New(wf *kanvas.Workflow, r *kanvas.Runtime) *Interpreter {
 		v := v
 		wjs[k] = &WorkflowJob{
 			ID:          k,
-			Outputs:     make(map[string]string),
+			Outputs:     kanvas.Outputs{},
 			WorkflowJob: v,
 		}
 	}
 
+	parallelism := wf.Options.Parallelism
+	if parallelism <= 0 {
+		parallelism = kanvas.DefaultParallelism
+	}
+
 	return &Interpreter{
 		Workflow:     wf,
 		WorkflowJobs: wjs,
 		runtime:      r,
+		Parallelism:  parallelism,
+		approvals:    map[string]*approval{},
 	}
 }
 
-func (p *Interpreter) Run(f func(job *WorkflowJob) error) error {
-	for _, phase := range p.Workflow.Plan {
-		if err := p.parallel(phase, f); err != nil {
+// Run runs f for each job in the workflow, up to p.Parallelism jobs concurrently.
+//
+// By default, each job is started as soon as all the jobs it needs are done.
+// When the Phased option is set, the jobs are run phase by phase instead.
+// Either way, the ctx passed to f is cancelled when any of the other jobs running concurrently failed,
+// or ctx is done, like when kanvas received a signal or timed out.
+func (p *Interpreter) Run(ctx context.Context, f func(ctx context.Context, job *WorkflowJob) error) error {
+	return p.runAll(ctx, false, f)
+}
+
+// runAll is Run that runs the jobs in the reverse order when reverse is true,
+// that is, each job is run only after all the jobs that need it are done.
+func (p *Interpreter) runAll(ctx context.Context, reverse bool, f func(ctx context.Context, job *WorkflowJob) error) error {
+	if t := p.Workflow.Options.Timeout; t > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, t, fmt.Errorf("timed out after %s", t))
+		defer cancel()
+	}
+
+	if p.Parallelism > 1 {
+		// The output of each job is prefixed with the job ID
+		// so that the interleaved output of the concurrent jobs stays readable.
+		// This needs to be done before running any job,
+		// so that the writers are never replaced while the jobs are running.
+		for id, job := range p.WorkflowJobs {
+			job.output = newPrefixWriter(os.Stderr, &p.outputMu, id)
+		}
+	}
+
+	if !p.Workflow.Options.Phased {
+		return p.dag(ctx, reverse, f)
+	}
+
+	for i := range p.Workflow.Plan {
+		phase := p.Workflow.Plan[i]
+		if reverse {
+			phase = p.Workflow.Plan[len(p.Workflow.Plan)-1-i]
+		}
+
+		if err := p.parallel(ctx, phase, f); err != nil {
 			return err
 		}
 	}
@@ -56,7 +140,126 @@ func (p *Interpreter) Run(f func(job *WorkflowJob) error) error {
 	return nil
 }
 
-func (p *Interpreter) run(name string, f func(job *WorkflowJob) error) error {
+// dag runs each job in the workflow as soon as all the jobs it needs are done,
+// up to p.Parallelism jobs concurrently.
+//
+// Unlike parallel, a slow job holds back only the jobs that need it,
+// not the whole next phase.
+// When any of the jobs failed, or parent is done, no more jobs are started,
+// and the jobs running at the time are cancelled.
+// When reverse is true, each job is run as soon as all the jobs that need it are done instead.
+func (p *Interpreter) dag(parent context.Context, reverse bool, f func(ctx context.Context, job *WorkflowJob) error) error {
+	// The jobs to run are the ones in the plan,
+	// which excludes the top-level components that only group the sub-components.
+	pending := map[string]int{}
+	for _, phase := range p.Workflow.Plan {
+		for _, id := range phase {
+			pending[id] = 0
+		}
+	}
+
+	dependents := map[string][]string{}
+	for id := range pending {
+		job, ok := p.WorkflowJobs[id]
+		if !ok {
+			return fmt.Errorf("component %q is not defined", id)
+		}
+
+		for _, n := range job.Needs {
+			// A job that is not in the plan is never run,
+			// so it doesn't block the jobs that need it.
+			if _, ok := pending[n]; !ok {
+				continue
+			}
+			if reverse {
+				pending[n]++
+				dependents[id] = append(dependents[id], n)
+			} else {
+				pending[id]++
+				dependents[n] = append(dependents[n], id)
+			}
+		}
+	}
+
+	var ready []string
+	for id, n := range pending {
+		if n == 0 {
+			ready = append(ready, id)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	type result struct {
+		id  string
+		err error
+	}
+
+	var (
+		results = make(chan result)
+		running int
+		done    int
+		fs      failures
+	)
+
+	for {
+		if ctx.Err() == nil {
+			// The ready jobs are started in the order of their IDs
+			// so that the order is stable across runs as much as possible.
+			sort.Strings(ready)
+
+			for len(ready) > 0 && running < p.Parallelism {
+				id := ready[0]
+				ready = ready[1:]
+				running++
+
+				go func() {
+					results <- result{id: id, err: p.run(ctx, id, f)}
+				}()
+			}
+		}
+
+		if running == 0 {
+			break
+		}
+
+		r := <-results
+		running--
+		done++
+
+		if r.err != nil {
+			if fs.add(parent, ctx, r.id, r.err) {
+				cancel()
+			}
+			continue
+		}
+
+		for _, d := range dependents[r.id] {
+			pending[d]--
+			if pending[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+
+	if fs.errs != nil {
+		// The jobs that were ready but not started are cancelled as well.
+		fs.cancelled = append(fs.cancelled, ready...)
+	}
+
+	if err := fs.err(parent, "components"); err != nil {
+		return err
+	}
+
+	if done != len(pending) {
+		return fmt.Errorf("BUG: only %d of %d components were run. The dependency graph might contain a cycle", done, len(pending))
+	}
+
+	return nil
+}
+
+func (p *Interpreter) run(ctx context.Context, name string, f func(ctx context.Context, job *WorkflowJob) error) error {
 	job, ok := p.WorkflowJobs[name]
 	if !ok {
 		return fmt.Errorf("component %q is not defined", name)
@@ -64,76 +267,252 @@ func (p *Interpreter) run(name string, f func(job *WorkflowJob) error) error {
 
 	if job.Skipped != nil {
 		job.Outputs = job.Skipped
+		p.recordJob(job, JobSkipped, nil)
 		return nil
 	}
 
-	if err := f(job); err != nil {
+	p.recordJob(job, JobRunning, nil)
+
+	err := f(ctx, job)
+
+	if w, ok := job.output.(*prefixWriter); ok {
+		w.Flush()
+	}
+
+	if err != nil {
+		p.recordJob(job, JobFailed, err)
 		return fmt.Errorf("component %q: %w", name, err)
 	}
 
+	if job.OutputOnly {
+		// The job is not applied, so it must not be skipped when the run is resumed.
+		p.recordJob(job, JobSkipped, nil)
+	} else {
+		p.recordJob(job, JobSucceeded, nil)
+	}
+
 	return nil
 }
 
-func (p *Interpreter) parallel(names []string, f func(job *WorkflowJob) error) error {
+// parallel runs the jobs in a phase of the workflow,
+// up to p.Parallelism jobs concurrently.
+//
+// When any of the jobs failed, the other jobs are cancelled
+// and the commands being run for them are killed.
+// The cancelled jobs are reported separately from the failed ones.
+func (p *Interpreter) parallel(parent context.Context, names []string, f func(ctx context.Context, job *WorkflowJob) error) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
 	var (
-		errs  error
-		errCh = make(chan error, len(names))
+		mu sync.Mutex
+		fs failures
+		g  errgroup.Group
 	)
 
+	g.SetLimit(p.Parallelism)
+
 	for _, n := range names {
 		n := n
-		if p.EnableParallel {
-			go func() {
-				errCh <- p.run(n, f)
-			}()
-		} else {
-			errCh <- p.run(n, f)
+		g.Go(func() error {
+			var err error
+			if ctx.Err() == nil {
+				err = p.run(ctx, n, f)
+			} else {
+				err = ctx.Err()
+			}
+
+			if err == nil {
+				return nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if fs.add(parent, ctx, n, err) {
+				cancel()
+			}
+
+			return nil
+		})
+	}
+
+	_ = g.Wait()
+
+	return fs.err(parent, fmt.Sprintf("component group %v", names))
+}
+
+// failures collects the errors of the jobs run concurrently.
+type failures struct {
+	// errs is the errors of the jobs that failed on their own.
+	errs error
+	// cancelled is the IDs of the jobs cancelled due to the failure of another job.
+	cancelled []string
+	// interrupted is the IDs of the jobs interrupted because the parent context is done,
+	// like when kanvas received a signal or timed out.
+	interrupted []string
+}
+
+// add records err returned by the job id run with ctx, which is derived from parent.
+// It returns true when the error is from a job failed on its own,
+// which means that the other jobs need to be cancelled.
+func (fs *failures) add(parent, ctx context.Context, id string, err error) bool {
+	switch {
+	case parent.Err() != nil:
+		fs.interrupted = append(fs.interrupted, id)
+	case ctx.Err() != nil:
+		// A job that failed after the cancellation is likely to be failed due to the cancellation.
+		fs.cancelled = append(fs.cancelled, id)
+	default:
+		fs.errs = multierror.Append(fs.errs, err)
+		return true
+	}
+
+	return false
+}
+
+// err returns the error that describes the failures, or nil when nothing failed.
+// It always returns an error when parent is done, even if no job was interrupted.
+func (fs *failures) err(parent context.Context, group string) error {
+	var msgs []string
+
+	if fs.errs != nil {
+		msgs = append(msgs, fmt.Sprintf("failed running %s: %s", group, fs.errs))
+	}
+
+	if len(fs.cancelled) > 0 {
+		sort.Strings(fs.cancelled)
+		msgs = append(msgs, fmt.Sprintf("cancelled component(s) %v due to the failure", fs.cancelled))
+	}
+
+	if parent.Err() != nil {
+		cause := context.Cause(parent)
+		if len(fs.interrupted) == 0 {
+			return fmt.Errorf("%w: %s", cause, strings.Join(append(msgs, "no component was running"), "\n"))
 		}
+		sort.Strings(fs.interrupted)
+		msgs = append(msgs, fmt.Sprintf("interrupted component(s) %v", fs.interrupted))
+		return fmt.Errorf("%w: %s", cause, strings.Join(msgs, "\n"))
+	}
+
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// runWithRetry runs the job with its retry policy, if any.
+// Each failed attempt is reported to the output of the job, and each attempt is recorded to the run state.
+// The number of attempts is recorded as the kanvas.attempts output of the job.
+func (p *Interpreter) runWithRetry(ctx context.Context, j *WorkflowJob, op kanvas.Op, steps []kanvas.Task) error {
+	if j.Retry == nil {
+		return p.runWithExtraArgs(ctx, j, op, steps)
 	}
 
-	for i := 0; i < len(names); i++ {
-		if err := <-errCh; err != nil {
-			errs = multierror.Append(errs, err)
+	j.attempts = nil
+
+	attempts, err := j.Retry.Do(ctx, func(attempt int) error {
+		return p.runWithExtraArgs(ctx, j, op, steps)
+	}, func(attempt int, err error, delay time.Duration) {
+		j.attempts = append(j.attempts, Attempt{Error: err.Error(), Delay: delay.Round(time.Millisecond).String()})
+
+		out := j.output
+		if out == nil {
+			out = os.Stderr
 		}
+		fmt.Fprintf(out, "Attempt %d/%d of component %q failed: %v\nRetrying in %s\n", attempt, j.Retry.Attempts, j.ID, err, delay.Round(time.Millisecond))
+	})
+
+	last := Attempt{}
+	if err != nil {
+		last.Error = err.Error()
 	}
+	j.attempts = append(j.attempts, last)
 
-	if errs != nil {
-		return fmt.Errorf("failed running component group %v: %s", names, errs)
+	if err != nil {
+		if attempts > 1 {
+			return fmt.Errorf("failed after %d attempts: %w", attempts, err)
+		}
+		return err
 	}
 
+	j.Outputs[kanvas.OutputAttempts] = strconv.Itoa(attempts)
+
 	return nil
 }
 
-func (p *Interpreter) runWithExtraArgs(j *WorkflowJob, op kanvas.Op, steps []kanvas.Task) error {
-	outputs := map[string]string{}
+func (p *Interpreter) runWithExtraArgs(ctx context.Context, j *WorkflowJob, op kanvas.Op, steps []kanvas.Task) error {
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, j.Timeout, fmt.Errorf("timed out after %s", j.Timeout))
+		defer cancel()
+	}
+
+	// The commands run by the drivers, like the ones to read outputs, are interrupted along with the job.
+	r := p.runtime.WithContext(ctx)
+
+	outputs := kanvas.Outputs{}
+
+	// The outputs are visible to the job itself while running the steps,
+	// so that a step can refer to the outputs of the preceding steps
+	// like `<job>.<output>`.
+	j.Outputs = outputs
+
 	for _, step := range steps {
 		if step.IfOutputEq.Key != "" {
-			if step.IfOutputEq.Value != outputs[step.IfOutputEq.Key] {
+			if step.IfOutputEq.Value != outputs.String(step.IfOutputEq.Key) {
+				continue
+			}
+		}
+
+		if step.UnlessOutputEq.Key != "" {
+			if step.UnlessOutputEq.Value == outputs.String(step.UnlessOutputEq.Key) {
 				continue
 			}
 		}
 
 		if step.Func != nil {
-			if err := step.Func(j, outputs); err != nil {
+			if err := step.Func(j.WorkflowJob, outputs); err != nil {
+				return err
+			}
+		} else if step.Exec != nil {
+			args, err := p.collectArgs(j, step.Args)
+			if err != nil {
+				return err
+			}
+
+			if err := step.Exec(r, args, outputs); err != nil {
 				return err
 			}
 		} else {
 			for _, c := range step.Run {
-				if err := p.runCmd(j, c); err != nil {
+				err := p.runCmd(ctx, j, c)
+				if step.ChangesExitCode != 0 && exitCode(err) == step.ChangesExitCode {
+					outputs[kanvas.OutputChanges] = "true"
+					continue
+				}
+
+				if err != nil {
 					return fmt.Errorf("command %s: %w", c, err)
 				}
+
+				if step.ChangesExitCode != 0 && outputs.String(kanvas.OutputChanges) == "" {
+					outputs[kanvas.OutputChanges] = "false"
+				}
 			}
 
 			if step.OutputFunc != nil {
-				if err := step.OutputFunc(p.runtime, outputs); err != nil {
+				if err := step.OutputFunc(r, outputs); err != nil {
 					return err
 				}
 			}
 		}
 	}
 
-	if j.Driver.OutputFunc != nil {
-		if err := j.Driver.OutputFunc(p.runtime, op, outputs); err != nil {
+	// The outputs of the destroyed job are gone along with what the job deployed.
+	if j.Driver.OutputFunc != nil && op != kanvas.Destroy {
+		if err := j.Driver.OutputFunc(r, op, outputs); err != nil {
 			return err
 		}
 	}
@@ -143,35 +522,24 @@ func (p *Interpreter) runWithExtraArgs(j *WorkflowJob, op kanvas.Op, steps []kan
 	return nil
 }
 
-func (p *Interpreter) runCmd(j *WorkflowJob, cmd kargo.Cmd) error {
-	args, err := cmd.Args.Collect(func(out string) (string, error) {
-		jobOutput := strings.SplitN(out, ".", 2)
-		if len(jobOutput) != 2 {
-			return "", fmt.Errorf("could not find dot(.) within %q", out)
-		}
-		jobName := jobOutput[0]
-		outName := jobOutput[1]
-
-		fullJobName := kanvas.SiblingID(j.ID, jobName)
+// readOutputs reads the outputs of the job via Driver.OutputFunc,
+// without running any of its steps.
+func (p *Interpreter) readOutputs(ctx context.Context, j *WorkflowJob, op kanvas.Op) error {
+	outputs := kanvas.Outputs{}
 
-		job, ok := p.WorkflowJobs[fullJobName]
-		if !ok {
-			return "", fmt.Errorf("job %q does not exist", jobName)
+	if j.Driver.OutputFunc != nil {
+		if err := j.Driver.OutputFunc(p.runtime.WithContext(ctx), op, outputs); err != nil {
+			return fmt.Errorf("reading outputs: %w", err)
 		}
+	}
 
-		val, ok := job.Outputs[outName]
-		if !ok {
-			var debug string
-			if os.Getenv("DEBUG") == "1" {
-				debug = fmt.Sprintf(". Available outputs: %v", job.Outputs)
-			} else {
-				debug = ". Set DEBUG=1 to see all the outputs"
-			}
-			return "", fmt.Errorf(`output "%s.%s" does not exist. Ensure that %q outputs %q%s`, jobName, outName, jobName, outName, debug)
-		}
+	j.Outputs = outputs
 
-		return val, nil
-	})
+	return nil
+}
+
+func (p *Interpreter) runCmd(ctx context.Context, j *WorkflowJob, cmd kargo.Cmd) error {
+	args, err := p.collectArgs(j, cmd.Args)
 	if err != nil {
 		return fmt.Errorf("while collecting args for command %q: %w", cmd.Name, err)
 	}
@@ -189,21 +557,71 @@ func (p *Interpreter) runCmd(j *WorkflowJob, cmd kargo.Cmd) error {
 		opts = append(opts, kanvas.ExecAddEnv(cmd.AddEnv))
 	}
 
-	if err := p.runtime.Exec(dir, c, opts...); err != nil {
+	if j.output != nil {
+		opts = append(opts, kanvas.ExecOutput(j.output))
+	}
+
+	if err := p.runtime.ExecContext(ctx, dir, c, opts...); err != nil {
 		return fmt.Errorf("command %q: %w", cmd.Name, err)
 	}
 
 	return nil
 }
 
-func (p *Interpreter) Apply() error {
-	if err := p.Run(func(job *WorkflowJob) error {
-		if err := p.applyJob(job); err != nil {
+// collectArgs resolves the dynamic args against the outputs of the other jobs.
+// Each dynamic arg is an expression like `infra.subnet_ids[0] ?? "none"`.
+func (p *Interpreter) collectArgs(j *WorkflowJob, a *kargo.Args) ([]string, error) {
+	scope := expr.Scope{
+		Output: func(jobName, outName string) (interface{}, error) {
+			fullJobName := kanvas.SiblingID(j.ID, jobName)
+
+			jobs := p.WorkflowJobs
+			if j.scope != nil {
+				jobs = j.scope
+			}
+
+			job, ok := jobs[fullJobName]
+			if !ok {
+				return nil, fmt.Errorf("job %q does not exist", jobName)
+			}
+
+			val, ok := job.Outputs[outName]
+			if !ok {
+				var debug string
+				if os.Getenv("DEBUG") == "1" {
+					debug = fmt.Sprintf(". Available outputs: %v", job.Outputs)
+				} else {
+					debug = ". Set DEBUG=1 to see all the outputs"
+				}
+				return nil, expr.NotFound(fmt.Errorf(`output "%s.%s" does not exist. Ensure that %q outputs %q%s`, jobName, outName, jobName, outName, debug))
+			}
+
+			return val, nil
+		},
+	}
+
+	return a.Collect(func(out string) (string, error) {
+		return expr.Evaluate(out, scope)
+	})
+}
+
+func (p *Interpreter) Apply(ctx context.Context) error {
+	if id := p.Workflow.Options.Resume; id != "" {
+		if err := p.resumeRun(id); err != nil {
+			return err
+		}
+	} else if err := p.startRun(); err != nil {
+		return err
+	}
+
+	if err := p.Run(ctx, func(ctx context.Context, job *WorkflowJob) error {
+		if err := p.applyJob(ctx, job); err != nil {
 			return err
 		}
 
 		return nil
 	}); err != nil {
+		fmt.Fprintf(os.Stderr, "The state of the run is saved as %q. Run `kanvas apply --resume=%s` to resume the apply from the failed components\n", p.state.ID, p.state.ID)
 		return err
 	}
 
@@ -225,10 +643,12 @@ func (p *Interpreter) Apply() error {
 	//   }
 	// }
 
+	// Every output is printed as a string, like the numbers as "123" and the lists as JSON-encoded strings,
+	// so that the consumers like the kanvas client keep working regardless of the types of the outputs.
 	out := map[string]map[string]string{}
 
 	for _, job := range p.WorkflowJobs {
-		out[job.ID] = job.Outputs
+		out[job.ID] = job.Outputs.Strings()
 	}
 
 	res, err := json.MarshalIndent(out, "", "  ")
@@ -241,18 +661,22 @@ func (p *Interpreter) Apply() error {
 	return nil
 }
 
-func (p *Interpreter) Diff() error {
-	return p.Run(func(job *WorkflowJob) error {
-		return p.diffJob(job)
+func (p *Interpreter) Diff(ctx context.Context) error {
+	return p.Run(ctx, func(ctx context.Context, job *WorkflowJob) error {
+		return p.diffJob(ctx, job)
 	})
 }
 
-func (p *Interpreter) diffJob(j *WorkflowJob) error {
+func (p *Interpreter) diffJob(ctx context.Context, j *WorkflowJob) error {
 	if j.Ran {
 		return nil
 	}
 
-	if err := p.runWithExtraArgs(j, kanvas.Diff, j.Driver.Diff); err != nil {
+	if j.OutputOnly {
+		return p.readOutputs(ctx, j, kanvas.Diff)
+	}
+
+	if err := p.runWithRetry(ctx, j, kanvas.Diff, j.Driver.Diff); err != nil {
 		return err
 	}
 
@@ -261,16 +685,55 @@ func (p *Interpreter) diffJob(j *WorkflowJob) error {
 	return nil
 }
 
-func (p *Interpreter) applyJob(j *WorkflowJob) error {
+func (p *Interpreter) applyJob(ctx context.Context, j *WorkflowJob) error {
 	if j.Ran {
 		return nil
 	}
 
-	if err := p.runWithExtraArgs(j, kanvas.Apply, j.Driver.Apply); err != nil {
+	if j.OutputOnly {
+		return p.readOutputs(ctx, j, kanvas.Apply)
+	}
+
+	// The input fingerprint is recorded even when forced,
+	// so that the next apply can reuse the outputs of the forced apply.
+	var fp string
+	if !j.AlwaysRun {
+		var err error
+		fp, err = p.inputFingerprint(j)
+		if err != nil {
+			return fmt.Errorf("computing input fingerprint: %w", err)
+		}
+		j.inputFingerprint = fp
+
+		if !p.Workflow.Options.Force {
+			if outputs := p.cached(j, fp); outputs != nil {
+				fmt.Fprintf(os.Stderr, "Component %q is up to date. Skipping\n", j.ID)
+				j.Outputs = outputs
+				j.Ran = true
+				return nil
+			}
+		}
+
+		if err := p.invalidateCache(j); err != nil {
+			return err
+		}
+	}
+
+	if err := p.approve(ctx, j); err != nil {
+		return err
+	}
+
+	if err := p.runWithRetry(ctx, j, kanvas.Apply, j.Driver.Apply); err != nil {
 		return err
 	}
 
 	j.Ran = true
 
+	if fp != "" {
+		if err := p.saveCache(j, fp); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
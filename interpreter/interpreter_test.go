@@ -0,0 +1,421 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davinci-std/kanvas"
+	"github.com/mumoshu/kargo"
+	"github.com/mumoshu/kargo/cmd"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestInterpreter(opts kanvas.Options, needs map[string][]string, plan ...[]string) *Interpreter {
+	wf := &kanvas.Workflow{
+		Plan:         plan,
+		WorkflowJobs: map[string]*kanvas.WorkflowJob{},
+		Options:      opts,
+	}
+
+	for _, phase := range plan {
+		for _, id := range phase {
+			wf.WorkflowJobs[id] = &kanvas.WorkflowJob{
+				Needs: needs[id],
+			}
+		}
+	}
+
+	return New(wf, kanvas.NewRuntime())
+}
+
+func TestInterpreterRun_Parallelism(t *testing.T) {
+	p := newTestInterpreter(kanvas.Options{Parallelism: 2, Phased: true}, nil, []string{"a", "b", "c", "d", "e"}, []string{"f"})
+
+	var (
+		running, max int32
+		mu           sync.Mutex
+		ran          []string
+	)
+
+	err := p.Run(context.Background(), func(ctx context.Context, job *WorkflowJob) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		ran = append(ran, job.ID)
+		mu.Unlock()
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, int32(2), max)
+	require.Len(t, ran, 6)
+	require.Equal(t, "f", ran[5], "the next phase must start after the previous phase completed")
+}
+
+func TestInterpreterRun_DefaultParallelism(t *testing.T) {
+	p := newTestInterpreter(kanvas.Options{}, nil, []string{"a"})
+
+	require.Equal(t, kanvas.DefaultParallelism, p.Parallelism)
+}
+
+func TestInterpreterRun_CancelSiblingsOnFailure(t *testing.T) {
+	p := newTestInterpreter(kanvas.Options{Parallelism: 2, Phased: true}, nil, []string{"a", "b", "c"}, []string{"d"})
+
+	var ran []string
+
+	err := p.Run(context.Background(), func(ctx context.Context, job *WorkflowJob) error {
+		switch job.ID {
+		case "a":
+			return errors.New("boom")
+		case "b":
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(10 * time.Second):
+				return errors.New("b was not cancelled")
+			}
+		default:
+			ran = append(ran, job.ID)
+			return nil
+		}
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `component "a": boom`)
+	require.Contains(t, err.Error(), "cancelled component(s) [b c]")
+	require.NotContains(t, err.Error(), "was not cancelled")
+	require.Empty(t, ran)
+}
+
+func TestInterpreterRun_DAG(t *testing.T) {
+	// c needs only b, so it must start without waiting for the slow a.
+	p := newTestInterpreter(
+		kanvas.Options{Parallelism: 2},
+		map[string][]string{
+			"c": {"b"},
+			"d": {"a", "c"},
+		},
+		[]string{"a", "b"}, []string{"c"}, []string{"d"},
+	)
+
+	var (
+		mu  sync.Mutex
+		ran []string
+		c   = make(chan struct{})
+	)
+
+	err := p.Run(context.Background(), func(ctx context.Context, job *WorkflowJob) error {
+		switch job.ID {
+		case "a":
+			select {
+			case <-c:
+			case <-time.After(10 * time.Second):
+				return errors.New("c did not run while a was running")
+			}
+		case "c":
+			close(c)
+		}
+
+		mu.Lock()
+		ran = append(ran, job.ID)
+		mu.Unlock()
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"b", "c", "a", "d"}, ran)
+}
+
+func TestInterpreterRun_DAGCancelOnFailure(t *testing.T) {
+	p := newTestInterpreter(
+		kanvas.Options{Parallelism: 2},
+		map[string][]string{
+			"c": {"a"},
+			"d": {"b"},
+		},
+		[]string{"a", "b"}, []string{"c", "d"},
+	)
+
+	var ran []string
+
+	err := p.Run(context.Background(), func(ctx context.Context, job *WorkflowJob) error {
+		switch job.ID {
+		case "a":
+			return errors.New("boom")
+		case "b":
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(10 * time.Second):
+				return errors.New("b was not cancelled")
+			}
+		default:
+			ran = append(ran, job.ID)
+			return nil
+		}
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `component "a": boom`)
+	require.Contains(t, err.Error(), "cancelled component(s) [b]")
+	require.NotContains(t, err.Error(), "was not cancelled")
+	require.Empty(t, ran)
+}
+
+func TestInterpreterRun_Interrupted(t *testing.T) {
+	for _, phased := range []bool{false, true} {
+		p := newTestInterpreter(kanvas.Options{Parallelism: 2, Phased: phased}, nil, []string{"a", "b"}, []string{"c"})
+
+		ctx, cancel := context.WithCancelCause(context.Background())
+
+		var ran []string
+
+		err := p.Run(ctx, func(ctx context.Context, job *WorkflowJob) error {
+			switch job.ID {
+			case "a":
+				cancel(&kanvas.InterruptError{Signal: os.Interrupt})
+				<-ctx.Done()
+				return ctx.Err()
+			case "b":
+				<-ctx.Done()
+				return ctx.Err()
+			default:
+				ran = append(ran, job.ID)
+				return nil
+			}
+		})
+		require.Error(t, err)
+
+		var ie *kanvas.InterruptError
+		require.ErrorAs(t, err, &ie)
+		require.Contains(t, err.Error(), "interrupted component(s) [a b]")
+		require.Empty(t, ran)
+	}
+}
+
+func TestInterpreterRun_Timeout(t *testing.T) {
+	p := newTestInterpreter(kanvas.Options{Timeout: 10 * time.Millisecond}, nil, []string{"a"})
+
+	err := p.Run(context.Background(), func(ctx context.Context, job *WorkflowJob) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.EqualError(t, err, "timed out after 10ms: interrupted component(s) [a]")
+}
+
+func TestInterpreterDiff_Retry(t *testing.T) {
+	p := newTestInterpreter(kanvas.Options{Parallelism: 1}, nil, []string{"a"})
+
+	var attempts int
+
+	a := p.WorkflowJobs["a"]
+	a.Retry = &kanvas.RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+	a.Driver = &kanvas.Driver{
+		Diff: []kanvas.Task{
+			{
+				Func: func(job *kanvas.WorkflowJob, o kanvas.Outputs) error {
+					attempts++
+					if attempts < 3 {
+						return errors.New("flaky")
+					}
+					return nil
+				},
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	a.output = &out
+
+	require.NoError(t, p.Diff(context.Background()))
+	require.Equal(t, 3, attempts)
+	require.Equal(t, "3", a.Outputs[kanvas.OutputAttempts])
+	require.Len(t, a.attempts, 3)
+	require.Contains(t, a.attempts[0].Error, "flaky")
+	require.Equal(t, "1ms", a.attempts[0].Delay)
+	require.Contains(t, a.attempts[1].Error, "flaky")
+	require.Equal(t, Attempt{}, a.attempts[2])
+	require.Contains(t, out.String(), `Attempt 1/3 of component "a" failed: flaky`)
+	require.Contains(t, out.String(), `Attempt 2/3 of component "a" failed: flaky`)
+}
+
+func TestInterpreterDiff_OutputOnly(t *testing.T) {
+	p := newTestInterpreter(kanvas.Options{Parallelism: 1}, map[string][]string{"b": {"a"}}, []string{"a"}, []string{"b"})
+
+	var ran []string
+
+	a := p.WorkflowJobs["a"]
+	a.OutputOnly = true
+	a.Driver = &kanvas.Driver{
+		Diff: []kanvas.Task{
+			{
+				Func: func(job *kanvas.WorkflowJob, o kanvas.Outputs) error {
+					ran = append(ran, "a")
+					return nil
+				},
+			},
+		},
+		OutputFunc: func(r *kanvas.Runtime, op kanvas.Op, o kanvas.Outputs) error {
+			o["id"] = "a-1"
+			return nil
+		},
+	}
+
+	p.WorkflowJobs["b"].Driver = &kanvas.Driver{
+		Diff: []kanvas.Task{
+			{
+				Func: func(job *kanvas.WorkflowJob, o kanvas.Outputs) error {
+					ran = append(ran, "b")
+					return nil
+				},
+			},
+		},
+	}
+
+	require.NoError(t, p.Diff(context.Background()))
+	require.Equal(t, []string{"b"}, ran, "the output-only job must not be diffed")
+	require.Equal(t, kanvas.Outputs{"id": "a-1"}, a.Outputs)
+}
+
+func TestInterpreterDiff_TypedOutputs(t *testing.T) {
+	p := newTestInterpreter(kanvas.Options{Parallelism: 1}, map[string][]string{"app": {"infra"}}, []string{"infra"}, []string{"app"})
+
+	p.WorkflowJobs["infra"].Driver = &kanvas.Driver{
+		OutputFunc: func(r *kanvas.Runtime, op kanvas.Op, o kanvas.Outputs) error {
+			o["replicas"] = float64(3)
+			o["subnets"] = []interface{}{"subnet-1", "subnet-2"}
+			o["tags"] = map[string]interface{}{"team": "platform"}
+			return nil
+		},
+	}
+
+	var got []string
+
+	var args *kargo.Args
+	args = args.AppendValueFromOutputWithPrefix("replicas=", "infra.replicas")
+	args = args.AppendValueFromOutputWithPrefix("subnets=", "infra.subnets")
+	args = args.AppendValueFromOutputWithPrefix("tags=", "infra.tags")
+
+	p.WorkflowJobs["app"].Driver = &kanvas.Driver{
+		Diff: []kanvas.Task{
+			{
+				Args: args,
+				Exec: func(r *kanvas.Runtime, args []string, o kanvas.Outputs) error {
+					got = args
+					return nil
+				},
+			},
+		},
+	}
+
+	require.NoError(t, p.Diff(context.Background()))
+	require.Equal(t, []string{
+		"replicas=3",
+		`subnets=["subnet-1","subnet-2"]`,
+		`tags={"team":"platform"}`,
+	}, got)
+	require.Equal(t, []interface{}{"subnet-1", "subnet-2"}, p.WorkflowJobs["infra"].Outputs["subnets"])
+}
+
+func TestInterpreterDiff_Expressions(t *testing.T) {
+	t.Setenv("KANVAS_TEST_REGION", "eu-west-1")
+
+	p := newTestInterpreter(kanvas.Options{Parallelism: 1}, map[string][]string{"app": {"infra"}}, []string{"infra"}, []string{"app"})
+
+	p.WorkflowJobs["infra"].Driver = &kanvas.Driver{
+		OutputFunc: func(r *kanvas.Runtime, op kanvas.Op, o kanvas.Outputs) error {
+			o["subnets"] = []interface{}{"subnet-1", "subnet-2"}
+			o["_raw"] = `{"vpc_id":{"value":"vpc-1"}}`
+			return nil
+		},
+	}
+
+	var got []string
+
+	var args *kargo.Args
+	args = args.AppendValueFromOutputWithPrefix("subnet=", "infra.subnets[1]")
+	args = args.AppendValueFromOutputWithPrefix("vpc=", "json(infra._raw).vpc_id.value")
+	args = args.AppendValueFromOutputWithPrefix("name=", `format("{0}-{1}", infra.subnets[0], env.KANVAS_TEST_REGION)`)
+	args = args.AppendValueFromOutputWithPrefix("domain=", `infra.domain ?? "example.com"`)
+
+	p.WorkflowJobs["app"].Driver = &kanvas.Driver{
+		Diff: []kanvas.Task{
+			{
+				Args: args,
+				Exec: func(r *kanvas.Runtime, args []string, o kanvas.Outputs) error {
+					got = args
+					return nil
+				},
+			},
+		},
+	}
+
+	require.NoError(t, p.Diff(context.Background()))
+	require.Equal(t, []string{
+		"subnet=subnet-2",
+		"vpc=vpc-1",
+		"name=subnet-1-eu-west-1",
+		"domain=example.com",
+	}, got)
+}
+
+func TestInterpreterRunWithExtraArgs_ChangesExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		code string
+		want string
+	}{
+		{"0", "false"},
+		{"2", "true"},
+	} {
+		t.Run("exit "+tc.code, func(t *testing.T) {
+			p := newTestInterpreter(kanvas.Options{}, nil, []string{"a"})
+
+			task := kanvas.Cmd("check", "sh", cmd.Args("-c", "exit "+tc.code))
+			task.ChangesExitCode = 2
+
+			j := p.WorkflowJobs["a"]
+			j.Driver = &kanvas.Driver{}
+
+			require.NoError(t, p.runWithExtraArgs(context.Background(), j, kanvas.Diff, []kanvas.Task{task}))
+			require.Equal(t, tc.want, j.Outputs[kanvas.OutputChanges])
+		})
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		mu  sync.Mutex
+	)
+
+	a := newPrefixWriter(&buf, &mu, "a")
+	b := newPrefixWriter(&buf, &mu, "/b/c")
+
+	_, err := a.Write([]byte("hello\nwor"))
+	require.NoError(t, err)
+	_, err = b.Write([]byte("foo\n"))
+	require.NoError(t, err)
+	_, err = a.Write([]byte("ld\nbye"))
+	require.NoError(t, err)
+	require.NoError(t, a.Flush())
+	require.NoError(t, b.Flush())
+
+	require.Equal(t, "[a] hello\n[/b/c] foo\n[a] world\n[a] bye\n", buf.String())
+}
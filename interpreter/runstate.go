@@ -0,0 +1,282 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/davinci-std/kanvas"
+)
+
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobSkipped   = "skipped"
+
+	runStateFile = "state.json"
+
+	// runsToKeep is the number of the latest runs kept under .kanvas/runs.
+	// The older runs are removed when a new run starts.
+	runsToKeep = 20
+)
+
+// RunState is the state of an apply, persisted under .kanvas/runs/<id>,
+// so that a failed apply can be resumed without rerunning the succeeded jobs.
+type RunState struct {
+	ID   string               `json:"id"`
+	Env  string               `json:"env,omitempty"`
+	Jobs map[string]*JobState `json:"jobs"`
+}
+
+// JobState is the state of a job in the run.
+type JobState struct {
+	Status string `json:"status"`
+	// Fingerprint is the input fingerprint of the succeeded job,
+	// given the outputs of the jobs it needs in the run.
+	// See kanvas.WorkflowJob.InputFingerprint.
+	Fingerprint string         `json:"fingerprint,omitempty"`
+	Outputs     kanvas.Outputs `json:"outputs,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	// Attempts is the attempts of the job with a retry policy, in the order they were made.
+	Attempts []Attempt `json:"attempts,omitempty"`
+}
+
+// Attempt is an attempt to run the job with a retry policy.
+type Attempt struct {
+	// Error is the error of the failed attempt.
+	// It is empty when the attempt succeeded.
+	Error string `json:"error,omitempty"`
+	// Delay is the wait before the next attempt, if any.
+	Delay string `json:"delay,omitempty"`
+}
+
+// runsDir returns the directory that contains the run states.
+func (p *Interpreter) runsDir() string {
+	return p.storePath("runs")
+}
+
+// startRun creates the state of a new run,
+// and removes the old runs except for the latest ones.
+func (p *Interpreter) startRun() error {
+	dir := p.runsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating runs dir %s: %w", dir, err)
+	}
+
+	id := time.Now().Format("20060102150405")
+	for i := 2; ; i++ {
+		err := os.Mkdir(filepath.Join(dir, id), 0755)
+		if err == nil {
+			break
+		} else if !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("creating run dir: %w", err)
+		}
+		id = fmt.Sprintf("%s-%d", time.Now().Format("20060102150405"), i)
+	}
+
+	p.state = &RunState{
+		ID:   id,
+		Env:  p.Workflow.Options.Env,
+		Jobs: map[string]*JobState{},
+	}
+
+	if err := p.saveRunState(); err != nil {
+		return err
+	}
+
+	return p.pruneRuns()
+}
+
+// pruneRuns removes the runs older than the latest runsToKeep runs.
+func (p *Interpreter) pruneRuns() error {
+	ids, err := subdirs(p.runsDir())
+	if err != nil {
+		return fmt.Errorf("reading runs dir: %w", err)
+	}
+
+	for len(ids) > runsToKeep {
+		if err := os.RemoveAll(filepath.Join(p.runsDir(), ids[0])); err != nil {
+			return fmt.Errorf("removing old run %q: %w", ids[0], err)
+		}
+		ids = ids[1:]
+	}
+
+	return nil
+}
+
+// resumeRun loads the state of the run and marks the succeeded jobs as skipped,
+// so that they are not run again and their outputs are reused.
+//
+// It refuses to resume when the input fingerprint of any succeeded job has changed since the run,
+// that is, its config or the files in its dir have changed,
+// because the outputs of the succeeded jobs might no longer be valid.
+// The changes to the other files, like a new git commit touching only the docs, don't prevent resuming.
+func (p *Interpreter) resumeRun(id string) error {
+	if id == kanvas.ResumeLatest {
+		latest, err := p.latestRun()
+		if err != nil {
+			return err
+		}
+		id = latest
+	}
+
+	var s RunState
+	ok, err := readJSON(filepath.Join(p.runsDir(), id, runStateFile), &s)
+	if err != nil {
+		return fmt.Errorf("reading run %q: %w", id, err)
+	} else if !ok {
+		return fmt.Errorf("run %q does not exist in %s", id, p.runsDir())
+	}
+
+	if env := p.Workflow.Options.Env; s.Env != env {
+		return fmt.Errorf("refusing to resume run %q: it was for environment %q, but the current environment is %q", id, s.Env, env)
+	}
+
+	var (
+		succeeded []string
+		changed   []string
+	)
+
+	for jobID, js := range s.Jobs {
+		if js.Status != JobSucceeded {
+			continue
+		}
+
+		job, ok := p.WorkflowJobs[jobID]
+		if !ok {
+			// The job has been removed from the config, so nothing depends on it anymore
+			delete(s.Jobs, jobID)
+			continue
+		}
+
+		// The jobs that always run, like the git job and the tests, read the current state.
+		// So they are run again rather than skipped.
+		if job.AlwaysRun {
+			continue
+		}
+
+		// The job is resumed with the outputs of the jobs it needs in the run,
+		// so the fingerprint is computed against them, not the current ones.
+		inputs := map[string]kanvas.Outputs{}
+		for _, n := range job.Needs {
+			if ns, ok := s.Jobs[n]; ok {
+				inputs[n] = ns.Outputs
+			}
+		}
+
+		fp, err := job.InputFingerprint(inputs)
+		if err != nil {
+			return fmt.Errorf("component %q: %w", jobID, err)
+		}
+
+		if fp != js.Fingerprint {
+			changed = append(changed, jobID)
+			continue
+		}
+
+		succeeded = append(succeeded, jobID)
+	}
+
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		return fmt.Errorf("refusing to resume run %q: the config or the files of the succeeded component(s) %v have changed since the run", id, changed)
+	}
+
+	for _, jobID := range succeeded {
+		outputs := s.Jobs[jobID].Outputs
+		if outputs == nil {
+			outputs = kanvas.Outputs{}
+		}
+		p.WorkflowJobs[jobID].Skipped = outputs
+	}
+
+	sort.Strings(succeeded)
+	fmt.Fprintf(os.Stderr, "Resuming run %q. Skipping the succeeded component(s) %v\n", id, succeeded)
+
+	p.state = &s
+
+	return nil
+}
+
+// latestRun returns the ID of the latest run.
+// The run IDs are timestamps, so the latest run is the last one in the lexical order.
+func (p *Interpreter) latestRun() (string, error) {
+	ids, err := subdirs(p.runsDir())
+	if err != nil {
+		return "", fmt.Errorf("reading runs dir: %w", err)
+	}
+
+	if len(ids) == 0 {
+		return "", fmt.Errorf("there is no run to resume in %s", p.runsDir())
+	}
+
+	return ids[len(ids)-1], nil
+}
+
+// recordJob records the status of the job to the run state, if any.
+func (p *Interpreter) recordJob(j *WorkflowJob, status string, err error) {
+	if p.state == nil {
+		return
+	}
+
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	// The jobs skipped on resume stay succeeded,
+	// so that the run can be resumed again.
+	if cur, ok := p.state.Jobs[j.ID]; ok && status == JobSkipped && cur.Status == JobSucceeded {
+		return
+	}
+
+	js := &JobState{
+		Status: status,
+	}
+
+	if status == JobSucceeded || status == JobFailed {
+		js.Attempts = j.attempts
+	}
+
+	if status == JobSucceeded {
+		fp := j.inputFingerprint
+		if fp == "" {
+			var fpErr error
+			if fp, fpErr = p.inputFingerprint(j); fpErr != nil {
+				fmt.Fprintf(os.Stderr, "Unable to compute the input fingerprint of component %q: %v\n", j.ID, fpErr)
+			}
+		}
+		js.Fingerprint = fp
+	}
+
+	if status == JobSucceeded || status == JobSkipped {
+		js.Outputs = j.Outputs
+	}
+
+	if err != nil {
+		js.Error = err.Error()
+	}
+
+	p.state.Jobs[j.ID] = js
+
+	if err := p.saveRunStateLocked(); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to save the run state: %v\n", err)
+	}
+}
+
+func (p *Interpreter) saveRunState() error {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	return p.saveRunStateLocked()
+}
+
+func (p *Interpreter) saveRunStateLocked() error {
+	if err := writeJSON(filepath.Join(p.runsDir(), p.state.ID, runStateFile), p.state); err != nil {
+		return fmt.Errorf("writing run state: %w", err)
+	}
+
+	return nil
+}
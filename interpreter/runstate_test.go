@@ -0,0 +1,118 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davinci-std/kanvas"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpreterApply_Resume(t *testing.T) {
+	dir := t.TempDir()
+	aDir := filepath.Join(dir, "a")
+	require.NoError(t, os.MkdirAll(aDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(aDir, "main.tf"), []byte("a"), 0644))
+
+	var (
+		aRuns int
+		bFail = true
+	)
+
+	newInterpreter := func(opts kanvas.Options) *Interpreter {
+		p := newTestInterpreter(opts, map[string][]string{"b": {"a"}}, []string{"a"}, []string{"b"})
+		p.Workflow.Dir = dir
+
+		p.WorkflowJobs["a"].Dir = aDir
+		p.WorkflowJobs["a"].Driver = &kanvas.Driver{
+			Apply: []kanvas.Task{
+				{
+					Func: func(job *kanvas.WorkflowJob, o kanvas.Outputs) error {
+						aRuns++
+						o["id"] = "a-1"
+						return nil
+					},
+				},
+			},
+		}
+		p.WorkflowJobs["b"].Driver = &kanvas.Driver{
+			Apply: []kanvas.Task{
+				{
+					Func: func(job *kanvas.WorkflowJob, o kanvas.Outputs) error {
+						if bFail {
+							return errors.New("b failed")
+						}
+						return nil
+					},
+				},
+			},
+		}
+
+		return p
+	}
+
+	p := newInterpreter(kanvas.Options{Parallelism: 1})
+	require.ErrorContains(t, p.Apply(context.Background()), "b failed")
+
+	data, err := os.ReadFile(filepath.Join(dir, ".kanvas", "runs", p.state.ID, "state.json"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"status": "succeeded"`)
+	require.Contains(t, string(data), `"error": "b failed"`)
+
+	bFail = false
+
+	p = newInterpreter(kanvas.Options{Parallelism: 1, Resume: kanvas.ResumeLatest})
+	require.NoError(t, p.Apply(context.Background()))
+	require.Equal(t, 1, aRuns, "the succeeded component must not be rerun")
+	require.Equal(t, kanvas.Outputs{"id": "a-1"}, p.WorkflowJobs["a"].Outputs)
+	require.Equal(t, JobSucceeded, p.state.Jobs["a"].Status)
+	require.Equal(t, JobSucceeded, p.state.Jobs["b"].Status)
+
+	id := p.state.ID
+
+	// The files outside the dirs of the succeeded components don't prevent resuming
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("changed"), 0644))
+	p = newInterpreter(kanvas.Options{Parallelism: 1, Resume: id})
+	require.NoError(t, p.Apply(context.Background()))
+	require.Equal(t, 1, aRuns)
+
+	p = newInterpreter(kanvas.Options{Parallelism: 1, Resume: id})
+	p.WorkflowJobs["a"].Needs = []string{"b"}
+	require.ErrorContains(t, p.Apply(context.Background()), `the config or the files of the succeeded component(s) [a] have changed since the run`)
+
+	require.NoError(t, os.WriteFile(filepath.Join(aDir, "main.tf"), []byte("b"), 0644))
+	p = newInterpreter(kanvas.Options{Parallelism: 1, Resume: id})
+	require.ErrorContains(t, p.Apply(context.Background()), `the config or the files of the succeeded component(s) [a] have changed since the run`)
+
+	p = newInterpreter(kanvas.Options{Parallelism: 1, Resume: id, Env: "prod"})
+	require.ErrorContains(t, p.Apply(context.Background()), `it was for environment "", but the current environment is "prod"`)
+
+	p = newInterpreter(kanvas.Options{Parallelism: 1, Resume: "nonexistent"})
+	require.ErrorContains(t, p.Apply(context.Background()), `run "nonexistent" does not exist`)
+}
+
+func TestInterpreterApply_PruneRuns(t *testing.T) {
+	dir := t.TempDir()
+
+	runs := filepath.Join(dir, ".kanvas", "runs")
+	for i := 0; i < runsToKeep+5; i++ {
+		require.NoError(t, os.MkdirAll(filepath.Join(runs, fmt.Sprintf("20230101000%03d", i)), 0755))
+	}
+
+	p := newTestInterpreter(kanvas.Options{Parallelism: 1}, nil, []string{"a"})
+	p.Workflow.Dir = dir
+	p.WorkflowJobs["a"].Driver = &kanvas.Driver{}
+
+	require.NoError(t, p.Apply(context.Background()))
+
+	ids, err := subdirs(runs)
+	require.NoError(t, err)
+	require.Len(t, ids, runsToKeep)
+	require.Equal(t, p.state.ID, ids[len(ids)-1])
+	require.Equal(t, "20230101000006", ids[0], "the oldest runs must be removed")
+}
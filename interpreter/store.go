@@ -0,0 +1,90 @@
+package interpreter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// storePath returns the path under .kanvas next to kanvas.yaml,
+// where kanvas persists what needs to survive across runs, like the run states, the cache, and the approvals.
+func (p *Interpreter) storePath(elem ...string) string {
+	return filepath.Join(append([]string{p.Workflow.Dir, ".kanvas"}, elem...)...)
+}
+
+// storeName returns the file name for the job ID, like `product1-appimage` for `/product1/appimage`.
+func storeName(id string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(id, "/"), "/", "-")
+}
+
+// readJSON decodes the JSON file at path into v.
+// It returns false when the file does not exist.
+func readJSON(path string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return true, nil
+}
+
+// writeJSON encodes v as JSON to the file at path, creating the parent directories as needed.
+//
+// The file is written to a temporary file and renamed,
+// so that it is never left half-written when kanvas is killed.
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling %s: %w", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// removeFile removes the file at path, if any.
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// subdirs returns the names of the directories in dir, in the lexical order.
+// It returns nothing when dir does not exist.
+func subdirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
@@ -20,8 +20,28 @@ type Component struct {
 	Dir string `yaml:"dir,omitempty"`
 	// Components is a map of sub-components
 	Components map[string]Component `yaml:"components"`
-	// Needs is a list of components that this component depends on
+	// Needs is a list of components that this component depends on.
+	// The components whose outputs are referenced via valueFrom and so on are inferred,
+	// so this needs to list only the other ones.
 	Needs []string `yaml:"needs,omitempty"`
+	// Approval is the manual approval gate that needs to be approved before applying this component
+	Approval *Approval `yaml:"approval,omitempty"`
+	// Timeout is the maximum duration of diffing or applying this component, in the Go duration format like 30m.
+	// The commands being run are interrupted when the component times out.
+	// If empty, the component never times out.
+	Timeout string `yaml:"timeout,omitempty"`
+	// Retry is the retry policy for diffing or applying this component.
+	// If empty, the component is never retried.
+	Retry *Retry `yaml:"retry,omitempty"`
+	// AlwaysRun makes kanvas apply this component every time,
+	// even when its dir, config, and inputs have not changed since the last successful apply.
+	// Set this for components that depend on something outside of kanvas, like the current time.
+	AlwaysRun bool `yaml:"alwaysRun,omitempty"`
+	// Watch is a list of extra paths whose changes affect this component, in addition to the dir.
+	// Each path is relative to the dir, or to the base dir when it starts with a slash.
+	// A path can be a file, a directory, or a glob pattern like path.Match, where `**` matches zero or more directories.
+	// This is used by `kanvas affected` to find the components affected by the changes.
+	Watch []string `yaml:"watch,omitempty"`
 
 	// AWS is an AWS-specific configuration
 	// This is currently used to ensure that you have the right AWS credentials
@@ -43,6 +63,9 @@ type Component struct {
 	// GitHubFiles is the configuration for the github-files driver
 	GitHubFiles *GitHubFiles `yaml:"githubFiles,omitempty"`
 
+	// Tests is a map of synthetic tests that are run after the components they need are applied
+	Tests map[string]Test `yaml:"tests,omitempty"`
+
 	// Noop is a noop configuration that does nothing
 	// This is mainly for template components that are only used as dependencies.
 	// You override or replaces this with a real component in the environment.
@@ -51,14 +74,22 @@ type Component struct {
 
 func (c *Component) Validate() error {
 	if c.Docker == nil && c.Terraform == nil && c.Kubernetes == nil && c.AWS == nil && c.Externals == nil &&
-		c.Noop == nil {
-		return errors.New("component does not have any of docker, terraform, kubernetes, aws, externals, or noop fields")
+		c.GitHubFiles == nil && c.Noop == nil {
+		return errors.New("component does not have any of docker, terraform, kubernetes, aws, externals, githubFiles, or noop fields")
 	}
 	return nil
 }
 
 // Environment is a set of sub-components to replace the defaults
 type Environment struct {
+	// Needs is a list of environments that need to be applied before this environment.
+	// When you apply this environment, the environments it needs are applied first
+	// within the same run.
+	Needs []string `yaml:"needs,omitempty"`
+	// After is an alias of Needs
+	After []string `yaml:"after,omitempty"`
+	// Approval is the manual approval gate that needs to be approved before applying this environment
+	Approval *Approval `yaml:"approval,omitempty"`
 	// Defaults is the environment-specific defaults
 	Defaults Component `yaml:"defaults,omitempty"`
 	// Uses is a set of sub-components to replace the defaults
@@ -67,6 +98,20 @@ type Environment struct {
 	Overrides map[string]Component `yaml:"overrides,omitempty"`
 }
 
+// needs returns the union of Needs and After
+func (e Environment) needs() []string {
+	var needs []string
+	seen := map[string]struct{}{}
+	for _, n := range append(append([]string{}, e.Needs...), e.After...) {
+		if _, ok := seen[n]; ok {
+			continue
+		}
+		seen[n] = struct{}{}
+		needs = append(needs, n)
+	}
+	return needs
+}
+
 // Docker is a docker-specific configuration
 type Docker struct {
 	// Image is the name of the image to be built
@@ -84,6 +129,10 @@ type Docker struct {
 	// We don't auto-determine the necessity of pushing to kind, so you need to set this explicitly.
 	// This is to give you freedom to push to a remote registry even when you are using kind.
 	Kind *Kind `yaml:"kind,omitempty"`
+	// DeleteOnDestroy makes `kanvas destroy` delete the tag of the image pushed by `kanvas apply` from the registry.
+	// The tag is deleted via `regctl tag delete`, so regctl needs to be installed and logged in to the registry.
+	// This cannot be used with Kind, because the image is not pushed to any registry.
+	DeleteOnDestroy bool `yaml:"deleteOnDestroy,omitempty"`
 }
 
 // Kind contains settings for pushing the image to a local kind cluster
@@ -99,13 +148,45 @@ type Terraform struct {
 	Target string `yaml:"target"`
 	// Vars is a list of variables to be passed to terraform
 	Vars []Var `yaml:"vars"`
+	// Workspace is the terraform workspace to be selected before running plan and apply.
+	// The workspace is created if it does not exist.
+	// If empty, the current workspace is used as-is.
+	Workspace string `yaml:"workspace,omitempty"`
+	// BackendConfig is a list of backend configurations to be passed to terraform init
+	// a.k.a `-backend-config $name=$value` or `-backend-config $file`
+	BackendConfig []BackendConfig `yaml:"backendConfig,omitempty"`
+	// VarFiles is a list of paths to the var files to be passed to terraform plan and apply
+	// a.k.a `-var-file $file`.
+	// Each path is relative to the dir of the component.
+	VarFiles []string `yaml:"varFiles,omitempty"`
+	// Parallelism is the number of concurrent operations as terraform walks the graph
+	// a.k.a `-parallelism $n`.
+	// If zero, terraform's default is used.
+	Parallelism int `yaml:"parallelism,omitempty"`
+	// Refresh specifies whether terraform refreshes the state before planning and applying
+	// a.k.a `-refresh=$refresh`.
+	// If unset, terraform's default is used.
+	Refresh *bool `yaml:"refresh,omitempty"`
+}
+
+// BackendConfig is a backend configuration to be passed to terraform init
+type BackendConfig struct {
+	// Name is the name of the backend configuration
+	Name string `yaml:"name,omitempty"`
+	// Value is the value of the backend configuration
+	Value string `yaml:"value,omitempty"`
+	// File is the path to the backend configuration file.
+	// This is relative to the dir of the component.
+	// File is mutually exclusive with Name and Value.
+	File string `yaml:"file,omitempty"`
 }
 
 // Var is a variable to be passed to terraform
 type Var struct {
 	// Name is the name of the variable
 	Name string `yaml:"name"`
-	// ValueFrom is the source of the value of the variable
+	// ValueFrom is the source of the value of the variable.
+	// It is an expression like `infra.vpc_id` or `infra.subnet_ids[0] ?? "none"`.
 	ValueFrom string `yaml:"valueFrom"`
 	// Value is the value of the variable
 	Value string `yaml:"value"`
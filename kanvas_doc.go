@@ -9,12 +9,14 @@ import (
 )
 
 var (
-	ComponentDoc   encoder.Doc
-	EnvironmentDoc encoder.Doc
-	DockerDoc      encoder.Doc
-	TerraformDoc   encoder.Doc
-	VarDoc         encoder.Doc
-	KubernetesDoc  encoder.Doc
+	ComponentDoc     encoder.Doc
+	EnvironmentDoc   encoder.Doc
+	DockerDoc        encoder.Doc
+	KindDoc          encoder.Doc
+	TerraformDoc     encoder.Doc
+	BackendConfigDoc encoder.Doc
+	VarDoc           encoder.Doc
+	KubernetesDoc    encoder.Doc
 )
 
 func init() {
@@ -39,7 +41,7 @@ func init() {
 			FieldName: "overrides",
 		},
 	}
-	ComponentDoc.Fields = make([]encoder.Doc, 9)
+	ComponentDoc.Fields = make([]encoder.Doc, 17)
 	ComponentDoc.Fields[0].Name = "dir"
 	ComponentDoc.Fields[0].Type = "string"
 	ComponentDoc.Fields[0].Note = ""
@@ -53,38 +55,78 @@ func init() {
 	ComponentDoc.Fields[2].Name = "needs"
 	ComponentDoc.Fields[2].Type = "[]string"
 	ComponentDoc.Fields[2].Note = ""
-	ComponentDoc.Fields[2].Description = "Needs is a list of components that this component depends on"
-	ComponentDoc.Fields[2].Comments[encoder.LineComment] = "Needs is a list of components that this component depends on"
-	ComponentDoc.Fields[3].Name = "aws"
-	ComponentDoc.Fields[3].Type = "AWS"
+	ComponentDoc.Fields[2].Description = "Needs is a list of components that this component depends on.\nThe components whose outputs are referenced via valueFrom and so on are inferred,\nso this needs to list only the other ones.\n"
+	ComponentDoc.Fields[2].Comments[encoder.LineComment] = "Needs is a list of components that this component depends on."
+	ComponentDoc.Fields[3].Name = "approval"
+	ComponentDoc.Fields[3].Type = "Approval"
 	ComponentDoc.Fields[3].Note = ""
-	ComponentDoc.Fields[3].Description = "AWS is an AWS-specific configuration\nThis is currently used to ensure that you have the right AWS credentials\nthat are required to access resources such as ECR and EKS.\n"
-	ComponentDoc.Fields[3].Comments[encoder.LineComment] = "AWS is an AWS-specific configuration"
-	ComponentDoc.Fields[4].Name = "docker"
-	ComponentDoc.Fields[4].Type = "Docker"
+	ComponentDoc.Fields[3].Description = "Approval is the manual approval gate that needs to be approved before applying this component"
+	ComponentDoc.Fields[3].Comments[encoder.LineComment] = "Approval is the manual approval gate that needs to be approved before applying this component"
+	ComponentDoc.Fields[4].Name = "timeout"
+	ComponentDoc.Fields[4].Type = "string"
 	ComponentDoc.Fields[4].Note = ""
-	ComponentDoc.Fields[4].Description = "Docker is a docker-specific configuration"
-	ComponentDoc.Fields[4].Comments[encoder.LineComment] = "Docker is a docker-specific configuration"
-	ComponentDoc.Fields[5].Name = "terraform"
-	ComponentDoc.Fields[5].Type = "Terraform"
+	ComponentDoc.Fields[4].Description = "Timeout is the maximum duration of diffing or applying this component, in the Go duration format like 30m.\nThe commands being run are interrupted when the component times out.\nIf empty, the component never times out.\n"
+	ComponentDoc.Fields[4].Comments[encoder.LineComment] = "Timeout is the maximum duration of diffing or applying this component, in the Go duration format like 30m."
+	ComponentDoc.Fields[5].Name = "retry"
+	ComponentDoc.Fields[5].Type = "Retry"
 	ComponentDoc.Fields[5].Note = ""
-	ComponentDoc.Fields[5].Description = "Terraform is a terraform-specific configuration"
-	ComponentDoc.Fields[5].Comments[encoder.LineComment] = "Terraform is a terraform-specific configuration"
-	ComponentDoc.Fields[6].Name = "kubernetes"
-	ComponentDoc.Fields[6].Type = "Kubernetes"
+	ComponentDoc.Fields[5].Description = "Retry is the retry policy for diffing or applying this component.\nIf empty, the component is never retried.\n"
+	ComponentDoc.Fields[5].Comments[encoder.LineComment] = "Retry is the retry policy for diffing or applying this component."
+	ComponentDoc.Fields[6].Name = "alwaysRun"
+	ComponentDoc.Fields[6].Type = "bool"
 	ComponentDoc.Fields[6].Note = ""
-	ComponentDoc.Fields[6].Description = "Kubernetes is a kubernetes-specific configuration"
-	ComponentDoc.Fields[6].Comments[encoder.LineComment] = "Kubernetes is a kubernetes-specific configuration"
-	ComponentDoc.Fields[7].Name = "environments"
-	ComponentDoc.Fields[7].Type = "map[string]Environment"
+	ComponentDoc.Fields[6].Description = "AlwaysRun makes kanvas apply this component every time,\neven when its dir, config, and inputs have not changed since the last successful apply.\nSet this for components that depend on something outside of kanvas, like the current time.\n"
+	ComponentDoc.Fields[6].Comments[encoder.LineComment] = "AlwaysRun makes kanvas apply this component every time,"
+	ComponentDoc.Fields[7].Name = "watch"
+	ComponentDoc.Fields[7].Type = "[]string"
 	ComponentDoc.Fields[7].Note = ""
-	ComponentDoc.Fields[7].Description = "Environments is a map of environments"
-	ComponentDoc.Fields[7].Comments[encoder.LineComment] = "Environments is a map of environments"
-	ComponentDoc.Fields[8].Name = "externals"
-	ComponentDoc.Fields[8].Type = "Externals"
+	ComponentDoc.Fields[7].Description = "Watch is a list of extra paths whose changes affect this component, in addition to the dir.\nEach path is relative to the dir, or to the base dir when it starts with a slash.\nA path can be a file, a directory, or a glob pattern like path.Match, where `**` matches zero or more directories.\nThis is used by `kanvas affected` to find the components affected by the changes.\n"
+	ComponentDoc.Fields[7].Comments[encoder.LineComment] = "Watch is a list of extra paths whose changes affect this component, in addition to the dir."
+	ComponentDoc.Fields[8].Name = "aws"
+	ComponentDoc.Fields[8].Type = "AWS"
 	ComponentDoc.Fields[8].Note = ""
-	ComponentDoc.Fields[8].Description = "Externals exposes external parameters and secrets as the component's outputs"
-	ComponentDoc.Fields[8].Comments[encoder.LineComment] = "Externals exposes external parameters and secrets as the component's outputs"
+	ComponentDoc.Fields[8].Description = "AWS is an AWS-specific configuration\nThis is currently used to ensure that you have the right AWS credentials\nthat are required to access resources such as ECR and EKS.\n"
+	ComponentDoc.Fields[8].Comments[encoder.LineComment] = "AWS is an AWS-specific configuration"
+	ComponentDoc.Fields[9].Name = "docker"
+	ComponentDoc.Fields[9].Type = "Docker"
+	ComponentDoc.Fields[9].Note = ""
+	ComponentDoc.Fields[9].Description = "Docker is a docker-specific configuration"
+	ComponentDoc.Fields[9].Comments[encoder.LineComment] = "Docker is a docker-specific configuration"
+	ComponentDoc.Fields[10].Name = "terraform"
+	ComponentDoc.Fields[10].Type = "Terraform"
+	ComponentDoc.Fields[10].Note = ""
+	ComponentDoc.Fields[10].Description = "Terraform is a terraform-specific configuration"
+	ComponentDoc.Fields[10].Comments[encoder.LineComment] = "Terraform is a terraform-specific configuration"
+	ComponentDoc.Fields[11].Name = "kubernetes"
+	ComponentDoc.Fields[11].Type = "Kubernetes"
+	ComponentDoc.Fields[11].Note = ""
+	ComponentDoc.Fields[11].Description = "Kubernetes is a kubernetes-specific configuration"
+	ComponentDoc.Fields[11].Comments[encoder.LineComment] = "Kubernetes is a kubernetes-specific configuration"
+	ComponentDoc.Fields[12].Name = "environments"
+	ComponentDoc.Fields[12].Type = "map[string]Environment"
+	ComponentDoc.Fields[12].Note = ""
+	ComponentDoc.Fields[12].Description = "Environments is a map of environments"
+	ComponentDoc.Fields[12].Comments[encoder.LineComment] = "Environments is a map of environments"
+	ComponentDoc.Fields[13].Name = "externals"
+	ComponentDoc.Fields[13].Type = "Externals"
+	ComponentDoc.Fields[13].Note = ""
+	ComponentDoc.Fields[13].Description = "Externals exposes external parameters and secrets as the component's outputs"
+	ComponentDoc.Fields[13].Comments[encoder.LineComment] = "Externals exposes external parameters and secrets as the component's outputs"
+	ComponentDoc.Fields[14].Name = "githubFiles"
+	ComponentDoc.Fields[14].Type = "GitHubFiles"
+	ComponentDoc.Fields[14].Note = ""
+	ComponentDoc.Fields[14].Description = "GitHubFiles is the configuration for the github-files driver"
+	ComponentDoc.Fields[14].Comments[encoder.LineComment] = "GitHubFiles is the configuration for the github-files driver"
+	ComponentDoc.Fields[15].Name = "tests"
+	ComponentDoc.Fields[15].Type = "map[string]Test"
+	ComponentDoc.Fields[15].Note = ""
+	ComponentDoc.Fields[15].Description = "Tests is a map of synthetic tests that are run after the components they need are applied"
+	ComponentDoc.Fields[15].Comments[encoder.LineComment] = "Tests is a map of synthetic tests that are run after the components they need are applied"
+	ComponentDoc.Fields[16].Name = "noop"
+	ComponentDoc.Fields[16].Type = "Noop"
+	ComponentDoc.Fields[16].Note = ""
+	ComponentDoc.Fields[16].Description = "Noop is a noop configuration that does nothing\nThis is mainly for template components that are only used as dependencies.\nYou override or replaces this with a real component in the environment.\n"
+	ComponentDoc.Fields[16].Comments[encoder.LineComment] = "Noop is a noop configuration that does nothing"
 
 	EnvironmentDoc.Type = "Environment"
 	EnvironmentDoc.Comments[encoder.LineComment] = "Environment is a set of sub-components to replace the defaults"
@@ -95,22 +137,37 @@ func init() {
 			FieldName: "environments",
 		},
 	}
-	EnvironmentDoc.Fields = make([]encoder.Doc, 3)
-	EnvironmentDoc.Fields[0].Name = "defaults"
-	EnvironmentDoc.Fields[0].Type = "Component"
+	EnvironmentDoc.Fields = make([]encoder.Doc, 6)
+	EnvironmentDoc.Fields[0].Name = "needs"
+	EnvironmentDoc.Fields[0].Type = "[]string"
 	EnvironmentDoc.Fields[0].Note = ""
-	EnvironmentDoc.Fields[0].Description = "Defaults is the environment-specific defaults"
-	EnvironmentDoc.Fields[0].Comments[encoder.LineComment] = "Defaults is the environment-specific defaults"
-	EnvironmentDoc.Fields[1].Name = "uses"
-	EnvironmentDoc.Fields[1].Type = "map[string]Component"
+	EnvironmentDoc.Fields[0].Description = "Needs is a list of environments that need to be applied before this environment.\nWhen you apply this environment, the environments it needs are applied first\nwithin the same run.\n"
+	EnvironmentDoc.Fields[0].Comments[encoder.LineComment] = "Needs is a list of environments that need to be applied before this environment."
+	EnvironmentDoc.Fields[1].Name = "after"
+	EnvironmentDoc.Fields[1].Type = "[]string"
 	EnvironmentDoc.Fields[1].Note = ""
-	EnvironmentDoc.Fields[1].Description = "Uses is a set of sub-components to replace the defaults"
-	EnvironmentDoc.Fields[1].Comments[encoder.LineComment] = "Uses is a set of sub-components to replace the defaults"
-	EnvironmentDoc.Fields[2].Name = "overrides"
-	EnvironmentDoc.Fields[2].Type = "map[string]Component"
+	EnvironmentDoc.Fields[1].Description = "After is an alias of Needs"
+	EnvironmentDoc.Fields[1].Comments[encoder.LineComment] = "After is an alias of Needs"
+	EnvironmentDoc.Fields[2].Name = "approval"
+	EnvironmentDoc.Fields[2].Type = "Approval"
 	EnvironmentDoc.Fields[2].Note = ""
-	EnvironmentDoc.Fields[2].Description = "Overrides is a set of sub-components to override the env and component defaults"
-	EnvironmentDoc.Fields[2].Comments[encoder.LineComment] = "Overrides is a set of sub-components to override the env and component defaults"
+	EnvironmentDoc.Fields[2].Description = "Approval is the manual approval gate that needs to be approved before applying this environment"
+	EnvironmentDoc.Fields[2].Comments[encoder.LineComment] = "Approval is the manual approval gate that needs to be approved before applying this environment"
+	EnvironmentDoc.Fields[3].Name = "defaults"
+	EnvironmentDoc.Fields[3].Type = "Component"
+	EnvironmentDoc.Fields[3].Note = ""
+	EnvironmentDoc.Fields[3].Description = "Defaults is the environment-specific defaults"
+	EnvironmentDoc.Fields[3].Comments[encoder.LineComment] = "Defaults is the environment-specific defaults"
+	EnvironmentDoc.Fields[4].Name = "uses"
+	EnvironmentDoc.Fields[4].Type = "map[string]Component"
+	EnvironmentDoc.Fields[4].Note = ""
+	EnvironmentDoc.Fields[4].Description = "Uses is a set of sub-components to replace the defaults"
+	EnvironmentDoc.Fields[4].Comments[encoder.LineComment] = "Uses is a set of sub-components to replace the defaults"
+	EnvironmentDoc.Fields[5].Name = "overrides"
+	EnvironmentDoc.Fields[5].Type = "map[string]Component"
+	EnvironmentDoc.Fields[5].Note = ""
+	EnvironmentDoc.Fields[5].Description = "Overrides is a set of sub-components to override the env and component defaults"
+	EnvironmentDoc.Fields[5].Comments[encoder.LineComment] = "Overrides is a set of sub-components to override the env and component defaults"
 
 	DockerDoc.Type = "Docker"
 	DockerDoc.Comments[encoder.LineComment] = "Docker is a docker-specific configuration"
@@ -121,7 +178,7 @@ func init() {
 			FieldName: "docker",
 		},
 	}
-	DockerDoc.Fields = make([]encoder.Doc, 5)
+	DockerDoc.Fields = make([]encoder.Doc, 7)
 	DockerDoc.Fields[0].Name = "image"
 	DockerDoc.Fields[0].Type = "string"
 	DockerDoc.Fields[0].Note = ""
@@ -147,6 +204,32 @@ func init() {
 	DockerDoc.Fields[4].Note = ""
 	DockerDoc.Fields[4].Description = "TagsFrom is a list of tags to be added to the image, derived from the outputs of other components"
 	DockerDoc.Fields[4].Comments[encoder.LineComment] = "TagsFrom is a list of tags to be added to the image, derived from the outputs of other components"
+	DockerDoc.Fields[5].Name = "kind"
+	DockerDoc.Fields[5].Type = "Kind"
+	DockerDoc.Fields[5].Note = ""
+	DockerDoc.Fields[5].Description = "Kind configures kanvas's behavior when pushing the image to a local kind cluster\nAn non-nil value means that the image will be pushed to a local kind cluster.\nWe don't auto-determine the necessity of pushing to kind, so you need to set this explicitly.\nThis is to give you freedom to push to a remote registry even when you are using kind.\n"
+	DockerDoc.Fields[5].Comments[encoder.LineComment] = "Kind configures kanvas's behavior when pushing the image to a local kind cluster"
+	DockerDoc.Fields[6].Name = "deleteOnDestroy"
+	DockerDoc.Fields[6].Type = "bool"
+	DockerDoc.Fields[6].Note = ""
+	DockerDoc.Fields[6].Description = "DeleteOnDestroy makes `kanvas destroy` delete the tag of the image pushed by `kanvas apply` from the registry.\nThe tag is deleted via `regctl tag delete`, so regctl needs to be installed and logged in to the registry.\nThis cannot be used with Kind, because the image is not pushed to any registry.\n"
+	DockerDoc.Fields[6].Comments[encoder.LineComment] = "DeleteOnDestroy makes `kanvas destroy` delete the tag of the image pushed by `kanvas apply` from the registry."
+
+	KindDoc.Type = "Kind"
+	KindDoc.Comments[encoder.LineComment] = "Kind contains settings for pushing the image to a local kind cluster"
+	KindDoc.Description = "Kind contains settings for pushing the image to a local kind cluster"
+	KindDoc.AppearsIn = []encoder.Appearance{
+		{
+			TypeName:  "Docker",
+			FieldName: "kind",
+		},
+	}
+	KindDoc.Fields = make([]encoder.Doc, 1)
+	KindDoc.Fields[0].Name = "clusterName"
+	KindDoc.Fields[0].Type = "string"
+	KindDoc.Fields[0].Note = ""
+	KindDoc.Fields[0].Description = "ClusterName is the name of the kind cluster\nIf empty, this defaults to \"kind\"\n"
+	KindDoc.Fields[0].Comments[encoder.LineComment] = "ClusterName is the name of the kind cluster"
 
 	TerraformDoc.Type = "Terraform"
 	TerraformDoc.Comments[encoder.LineComment] = "Terraform is a terraform-specific configuration"
@@ -157,7 +240,7 @@ func init() {
 			FieldName: "terraform",
 		},
 	}
-	TerraformDoc.Fields = make([]encoder.Doc, 2)
+	TerraformDoc.Fields = make([]encoder.Doc, 7)
 	TerraformDoc.Fields[0].Name = "target"
 	TerraformDoc.Fields[0].Type = "string"
 	TerraformDoc.Fields[0].Note = ""
@@ -168,6 +251,57 @@ func init() {
 	TerraformDoc.Fields[1].Note = ""
 	TerraformDoc.Fields[1].Description = "Vars is a list of variables to be passed to terraform"
 	TerraformDoc.Fields[1].Comments[encoder.LineComment] = "Vars is a list of variables to be passed to terraform"
+	TerraformDoc.Fields[2].Name = "workspace"
+	TerraformDoc.Fields[2].Type = "string"
+	TerraformDoc.Fields[2].Note = ""
+	TerraformDoc.Fields[2].Description = "Workspace is the terraform workspace to be selected before running plan and apply.\nThe workspace is created if it does not exist.\nIf empty, the current workspace is used as-is.\n"
+	TerraformDoc.Fields[2].Comments[encoder.LineComment] = "Workspace is the terraform workspace to be selected before running plan and apply."
+	TerraformDoc.Fields[3].Name = "backendConfig"
+	TerraformDoc.Fields[3].Type = "[]BackendConfig"
+	TerraformDoc.Fields[3].Note = ""
+	TerraformDoc.Fields[3].Description = "BackendConfig is a list of backend configurations to be passed to terraform init\na.k.a `-backend-config $name=$value` or `-backend-config $file`\n"
+	TerraformDoc.Fields[3].Comments[encoder.LineComment] = "BackendConfig is a list of backend configurations to be passed to terraform init"
+	TerraformDoc.Fields[4].Name = "varFiles"
+	TerraformDoc.Fields[4].Type = "[]string"
+	TerraformDoc.Fields[4].Note = ""
+	TerraformDoc.Fields[4].Description = "VarFiles is a list of paths to the var files to be passed to terraform plan and apply\na.k.a `-var-file $file`.\nEach path is relative to the dir of the component.\n"
+	TerraformDoc.Fields[4].Comments[encoder.LineComment] = "VarFiles is a list of paths to the var files to be passed to terraform plan and apply"
+	TerraformDoc.Fields[5].Name = "parallelism"
+	TerraformDoc.Fields[5].Type = "int"
+	TerraformDoc.Fields[5].Note = ""
+	TerraformDoc.Fields[5].Description = "Parallelism is the number of concurrent operations as terraform walks the graph\na.k.a `-parallelism $n`.\nIf zero, terraform's default is used.\n"
+	TerraformDoc.Fields[5].Comments[encoder.LineComment] = "Parallelism is the number of concurrent operations as terraform walks the graph"
+	TerraformDoc.Fields[6].Name = "refresh"
+	TerraformDoc.Fields[6].Type = "bool"
+	TerraformDoc.Fields[6].Note = ""
+	TerraformDoc.Fields[6].Description = "Refresh specifies whether terraform refreshes the state before planning and applying\na.k.a `-refresh=$refresh`.\nIf unset, terraform's default is used.\n"
+	TerraformDoc.Fields[6].Comments[encoder.LineComment] = "Refresh specifies whether terraform refreshes the state before planning and applying"
+
+	BackendConfigDoc.Type = "BackendConfig"
+	BackendConfigDoc.Comments[encoder.LineComment] = "BackendConfig is a backend configuration to be passed to terraform init"
+	BackendConfigDoc.Description = "BackendConfig is a backend configuration to be passed to terraform init"
+	BackendConfigDoc.AppearsIn = []encoder.Appearance{
+		{
+			TypeName:  "Terraform",
+			FieldName: "backendConfig",
+		},
+	}
+	BackendConfigDoc.Fields = make([]encoder.Doc, 3)
+	BackendConfigDoc.Fields[0].Name = "name"
+	BackendConfigDoc.Fields[0].Type = "string"
+	BackendConfigDoc.Fields[0].Note = ""
+	BackendConfigDoc.Fields[0].Description = "Name is the name of the backend configuration"
+	BackendConfigDoc.Fields[0].Comments[encoder.LineComment] = "Name is the name of the backend configuration"
+	BackendConfigDoc.Fields[1].Name = "value"
+	BackendConfigDoc.Fields[1].Type = "string"
+	BackendConfigDoc.Fields[1].Note = ""
+	BackendConfigDoc.Fields[1].Description = "Value is the value of the backend configuration"
+	BackendConfigDoc.Fields[1].Comments[encoder.LineComment] = "Value is the value of the backend configuration"
+	BackendConfigDoc.Fields[2].Name = "file"
+	BackendConfigDoc.Fields[2].Type = "string"
+	BackendConfigDoc.Fields[2].Note = ""
+	BackendConfigDoc.Fields[2].Description = "File is the path to the backend configuration file.\nThis is relative to the dir of the component.\nFile is mutually exclusive with Name and Value.\n"
+	BackendConfigDoc.Fields[2].Comments[encoder.LineComment] = "File is the path to the backend configuration file."
 
 	VarDoc.Type = "Var"
 	VarDoc.Comments[encoder.LineComment] = "Var is a variable to be passed to terraform"
@@ -187,8 +321,8 @@ func init() {
 	VarDoc.Fields[1].Name = "valueFrom"
 	VarDoc.Fields[1].Type = "string"
 	VarDoc.Fields[1].Note = ""
-	VarDoc.Fields[1].Description = "ValueFrom is the source of the value of the variable"
-	VarDoc.Fields[1].Comments[encoder.LineComment] = "ValueFrom is the source of the value of the variable"
+	VarDoc.Fields[1].Description = "ValueFrom is the source of the value of the variable.\nIt is an expression like `infra.vpc_id` or `infra.subnet_ids[0] ?? \"none\"`.\n"
+	VarDoc.Fields[1].Comments[encoder.LineComment] = "ValueFrom is the source of the value of the variable."
 	VarDoc.Fields[2].Name = "value"
 	VarDoc.Fields[2].Type = "string"
 	VarDoc.Fields[2].Note = ""
@@ -205,28 +339,29 @@ func init() {
 		},
 	}
 	KubernetesDoc.Fields = make([]encoder.Doc, 0)
-}
 
+}
 func (_ Component) Doc() *encoder.Doc {
 	return &ComponentDoc
 }
-
 func (_ Environment) Doc() *encoder.Doc {
 	return &EnvironmentDoc
 }
-
 func (_ Docker) Doc() *encoder.Doc {
 	return &DockerDoc
 }
-
+func (_ Kind) Doc() *encoder.Doc {
+	return &KindDoc
+}
 func (_ Terraform) Doc() *encoder.Doc {
 	return &TerraformDoc
 }
-
+func (_ BackendConfig) Doc() *encoder.Doc {
+	return &BackendConfigDoc
+}
 func (_ Var) Doc() *encoder.Doc {
 	return &VarDoc
 }
-
 func (_ Kubernetes) Doc() *encoder.Doc {
 	return &KubernetesDoc
 }
@@ -240,7 +375,9 @@ func GetComponentDoc() *encoder.FileDoc {
 			&ComponentDoc,
 			&EnvironmentDoc,
 			&DockerDoc,
+			&KindDoc,
 			&TerraformDoc,
+			&BackendConfigDoc,
 			&VarDoc,
 			&KubernetesDoc,
 		},
@@ -0,0 +1,66 @@
+package kanvas
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/davinci-std/kanvas/expr"
+)
+
+// Outputs is the outputs of a job, keyed by the names of the outputs.
+//
+// Each value is typed like the values decoded from JSON,
+// that is, either a string, a float64 number, a bool, a []interface{} list, or a map[string]interface{} map,
+// so that the outputs of the jobs can be persisted and passed around as JSON losslessly.
+type Outputs map[string]interface{}
+
+// bookkeepingOutputPrefix is the prefix of the outputs kanvas records for its own bookkeeping,
+// like OutputChanges and OutputAttempts, rather than the ones produced by the components.
+const bookkeepingOutputPrefix = "kanvas."
+
+// String returns the value of the output formatted by OutputString,
+// or an empty string when the output does not exist.
+func (o Outputs) String(key string) string {
+	return OutputString(o[key])
+}
+
+// Strings returns the outputs formatted by OutputString, keyed by the names of the outputs.
+// This is for the serialization that needs to stay compatible with the consumers
+// written when every output was a string, like the JSON printed by `kanvas apply`.
+func (o Outputs) Strings() map[string]string {
+	m := make(map[string]string, len(o))
+	for k := range o {
+		m[k] = o.String(k)
+	}
+	return m
+}
+
+// OutputString formats the value of an output to be passed to a command,
+// like a terraform var, a helm set, or a docker build arg.
+// See expr.String for how the values are formatted.
+func OutputString(v interface{}) string {
+	return expr.String(v)
+}
+
+// terraformOutputs adds the outputs in the format of `terraform output -json` to o,
+// keeping the types of the values like numbers, lists, and objects.
+func terraformOutputs(data []byte, o Outputs) error {
+	type terraformOutput struct {
+		Sensitive bool `json:"sensitive"`
+		// Type is either a string like "number",
+		// or a list like ["list", "string"] for the collection and structural types.
+		Type  json.RawMessage `json:"type"`
+		Value interface{}     `json:"value"`
+	}
+
+	m := map[string]terraformOutput{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("unable to decode terraform outputs: %w", err)
+	}
+
+	for k, out := range m {
+		o[k] = out.Value
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package kanvas
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputString(t *testing.T) {
+	require.Equal(t, "", OutputString(nil))
+	require.Equal(t, "foo", OutputString("foo"))
+	require.Equal(t, "true", OutputString(true))
+	require.Equal(t, "3", OutputString(float64(3)))
+	require.Equal(t, "0.5", OutputString(0.5))
+	require.Equal(t, "123456789012", OutputString(float64(123456789012)))
+	require.Equal(t, `["a","b"]`, OutputString([]interface{}{"a", "b"}))
+	require.Equal(t, `{"a":1,"b":["c"]}`, OutputString(map[string]interface{}{"b": []interface{}{"c"}, "a": float64(1)}))
+
+	o := Outputs{"replicas": float64(2)}
+	require.Equal(t, "2", o.String("replicas"))
+	require.Equal(t, "", o.String("missing"))
+}
+
+func TestOutputsStrings(t *testing.T) {
+	o := Outputs{"name": "app", "replicas": float64(2), "public": true, "subnets": []interface{}{"a", "b"}}
+	require.Equal(t, map[string]string{"name": "app", "replicas": "2", "public": "true", "subnets": `["a","b"]`}, o.Strings())
+}
+
+func TestTerraformOutputs(t *testing.T) {
+	o := Outputs{}
+	require.NoError(t, terraformOutputs([]byte(`{
+  "name": {"sensitive": false, "type": "string", "value": "foo"},
+  "count": {"sensitive": false, "type": "number", "value": 3},
+  "ratio": {"sensitive": false, "type": "number", "value": 0.5},
+  "enabled": {"sensitive": false, "type": "bool", "value": true},
+  "subnets": {"sensitive": false, "type": ["list", "string"], "value": ["subnet-1", "subnet-2"]},
+  "tags": {"sensitive": false, "type": ["map", "string"], "value": {"team": "platform"}}
+}`), o))
+
+	require.Equal(t, Outputs{
+		"name":    "foo",
+		"count":   float64(3),
+		"ratio":   0.5,
+		"enabled": true,
+		"subnets": []interface{}{"subnet-1", "subnet-2"},
+		"tags":    map[string]interface{}{"team": "platform"},
+	}, o)
+
+	require.Equal(t, "3", o.String("count"))
+	require.Equal(t, `["subnet-1","subnet-2"]`, o.String("subnets"))
+}
@@ -0,0 +1,114 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// affectedJob is the name of the CI job that runs `kanvas affected`,
+	// whose outputs tell which jobs are affected by the changes.
+	affectedJob = "kanvas-affected"
+	// affectedStepID is the ID of the step that runs `kanvas affected`
+	affectedStepID = "affected"
+)
+
+// OutputAffected writes whether each job is affected, in the format.
+// Each output is named after the CI job of the kanvas job, and its value is either true or false.
+func (e *Plugin) OutputAffected(format string, affected []string) error {
+	if format != FormatGitHubActions {
+		return fmt.Errorf("unsupported format %q", format)
+	}
+
+	isAffected := map[string]bool{}
+	for _, id := range affected {
+		isAffected[id] = true
+	}
+
+	var ids []string
+	for id, job := range e.wf.WorkflowJobs {
+		if job.Driver != nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	// See https://docs.github.com/en/actions/using-jobs/defining-outputs-for-jobs
+	f, err := os.OpenFile(os.Getenv("GITHUB_OUTPUT"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open GITHUB_OUTPUT: %w", err)
+	}
+
+	for _, id := range ids {
+		if _, err := f.WriteString(fmt.Sprintf("%s=%t\n", jobName(id), isAffected[id])); err != nil {
+			f.Close()
+			return fmt.Errorf("unable to write a kv to GITHUB_OUTPUT: %w", err)
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to close GITHUB_OUTPUT: %w", err)
+	}
+
+	return nil
+}
+
+// affectedCommand returns the command to run `kanvas affected` in the CI job,
+// which compares HEAD against the base branch of the pull request, or the previous commit on push.
+func (e *Plugin) affectedCommand() string {
+	c := []string{
+		"kanvas", "affected",
+		"--base", `"${{ github.event.pull_request.base.sha || github.event.before }}"`,
+		"-o", FormatGitHubActions,
+	}
+
+	if env := e.wf.Options.Env; env != "" {
+		c = append(c, "-e", env)
+	}
+
+	return strings.Join(c, " ")
+}
+
+// actionsAffectedJob returns the job that runs `kanvas affected`
+// and outputs whether each job is affected.
+func (e *Plugin) actionsAffectedJob(kanvasContainerImage string) actionsJob {
+	outputs := map[string]string{}
+	for id, job := range e.wf.WorkflowJobs {
+		if job.Driver == nil {
+			continue
+		}
+		name := jobName(id)
+		outputs[name] = fmt.Sprintf("${{ steps.%s.outputs.%s }}", affectedStepID, name)
+	}
+
+	return actionsJob{
+		RunsOn: "ubuntu-latest",
+		Container: container{
+			Image: kanvasContainerImage,
+		},
+		Outputs: outputs,
+		Steps: []actionsStep{
+			stepCheckout(),
+			{
+				ID:  affectedStepID,
+				Run: e.affectedCommand(),
+			},
+		},
+	}
+}
+
+// ifAffected returns the condition of the steps that run only when the job is affected.
+func ifAffected(job string) string {
+	return fmt.Sprintf("needs.%s.outputs.%s == 'true'", affectedJob, job)
+}
+
+// checkAffectedOnly returns an error when the format does not support Options.AffectedOnly.
+func (e *Plugin) checkAffectedOnly(format string) error {
+	if e.wf.Options.AffectedOnly && format != FormatGitHubActions {
+		return fmt.Errorf("the %s format does not support exporting the workflows for the affected components only yet", format)
+	}
+
+	return nil
+}
@@ -0,0 +1,280 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/davinci-std/kanvas"
+
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	FormatCodeBuild = "codebuild"
+
+	// CodeBuildOutputsFile is the shell script written by `kanvas output -f codebuild`.
+	// The exported buildspec sources it so that the outputs become the environment variables of the build.
+	CodeBuildOutputsFile = "kanvas-outputs.sh"
+	// CodeBuildOutputsURIVar is the environment variable that tells the builds the S3 prefix like `s3://bucket/run-1`,
+	// where each build uploads its outputs and the dependent builds download them.
+	// It must be unique to the batch, and you set it via `aws codebuild start-build-batch`.
+	CodeBuildOutputsURIVar = "KANVAS_OUTPUTS_URI"
+
+	// CodeBuildJobVar is the environment variable that tells the build which kanvas job to run.
+	// It is set by each build of the batch build graph.
+	CodeBuildJobVar = "KANVAS_JOB"
+	// CodeBuildOpVar is the environment variable that tells the build whether to run diff or apply.
+	// It defaults to diff, and you can override it for the whole batch via `aws codebuild start-build-batch`.
+	CodeBuildOpVar = "KANVAS_OP"
+)
+
+func (e *Plugin) outputCodeBuild(op kanvas.Op, target string, args []string) error {
+	outputs, err := e.outputs(op, target, args)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(CodeBuildOutputsFile)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", CodeBuildOutputsFile, err)
+	}
+
+	job := jobName(target)
+
+	for _, k := range outputKeys(outputs) {
+		if _, err := f.WriteString(fmt.Sprintf("%s=%s\n", outputVar(job, k), shellQuote(outputs.String(k)))); err != nil {
+			return fmt.Errorf("unable to write a kv to %s: %w", CodeBuildOutputsFile, err)
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to close %s: %w", CodeBuildOutputsFile, err)
+	}
+
+	return nil
+}
+
+func (e *Plugin) exportCodeBuild(dir, kanvasContainerImage string) error {
+	if e.hasArtifacts() {
+		return fmt.Errorf("the %s format does not support passing artifacts like terraform plan files from diff to apply yet", FormatCodeBuild)
+	}
+
+	var ids []string
+	for id, job := range e.wf.WorkflowJobs {
+		if job.Driver == nil {
+			continue
+		}
+
+		// CodeBuild has no equivalent of GitHub Actions environments or GitLab protected environments.
+		// We'd rather fail than silently bypass the approval gates.
+		if len(job.Approvals) > 0 {
+			return fmt.Errorf("the %s format does not support approval gates, but job %q has approval gate %q", FormatCodeBuild, id, job.Approvals[0].ID)
+		}
+
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return jobName(ids[i]) < jobName(ids[j])
+	})
+
+	type buildCase struct {
+		op, job  string
+		commands []string
+	}
+
+	var (
+		graph []codebuildBuild
+		cases []buildCase
+		// uploaded is the set of the jobs whose outputs are referenced by other jobs.
+		uploaded = map[string]struct{}{}
+	)
+
+	for _, id := range ids {
+		job := e.wf.WorkflowJobs[id]
+		name := jobName(id)
+
+		var dependOn []string
+		for _, n := range job.Needs {
+			dependOn = append(dependOn, codebuildIdentifier(jobName(n)))
+		}
+
+		graph = append(graph, codebuildBuild{
+			Identifier: codebuildIdentifier(name),
+			DependOn:   dependOn,
+			Env: codebuildBuildEnv{
+				Image: kanvasContainerImage,
+				Variables: map[string]string{
+					CodeBuildJobVar: name,
+				},
+			},
+		})
+
+		for _, op := range []kanvas.Op{kanvas.Diff, kanvas.Apply} {
+			opName, tasks := "diff", job.Driver.Diff
+			if op == kanvas.Apply {
+				opName, tasks = "apply", job.Driver.Apply
+			}
+
+			var (
+				commands  []string
+				usesOwn   bool
+				downloads = map[string]struct{}{}
+			)
+
+			get := shellGetter(FormatCodeBuild, name, true, func(j, output string) string {
+				if j == name {
+					usesOwn = true
+				} else {
+					downloads[j] = struct{}{}
+				}
+				return outputVar(j, output)
+			})
+
+			for _, s := range tasks {
+				for _, c := range s.Run {
+					cmd, err := shellCommand(c, get)
+					if err != nil {
+						return fmt.Errorf("job %q: %w", name, err)
+					}
+					commands = append(commands, cmd)
+				}
+			}
+
+			outputCmd, err := outputCommand(job.Driver, FormatCodeBuild, opName, get)
+			if err != nil {
+				return fmt.Errorf("job %q: %w", name, err)
+			}
+
+			// The outputs are loaded into the shell so that
+			// the subsequent commands can refer to the outputs of the job itself.
+			output := fmt.Sprintf("%s && set -a && . ./%s && set +a", outputCmd, CodeBuildOutputsFile)
+
+			if usesOwn {
+				commands = append([]string{output}, commands...)
+			}
+
+			// The builds in a batch do not share the exported variables,
+			// so the outputs of the upstream jobs are downloaded from the S3 prefix the upstream builds uploaded them to.
+			var jobs []string
+			for j := range downloads {
+				jobs = append(jobs, j)
+				uploaded[j] = struct{}{}
+			}
+			sort.Strings(jobs)
+
+			var loads []string
+			for _, j := range jobs {
+				file := fmt.Sprintf("kanvas-outputs-%s.sh", j)
+				loads = append(loads, fmt.Sprintf("aws s3 cp %s ./%s && set -a && . ./%s && set +a", codebuildOutputsObject(j), file, file))
+			}
+
+			commands = append(append(loads, commands...), output)
+
+			cases = append(cases, buildCase{op: opName, job: name, commands: commands})
+		}
+	}
+
+	var script []string
+	for _, c := range cases {
+		commands := c.commands
+		if _, ok := uploaded[c.job]; ok {
+			commands = append(commands, fmt.Sprintf("aws s3 cp ./%s %s", CodeBuildOutputsFile, codebuildOutputsObject(c.job)))
+		}
+
+		script = append(script, fmt.Sprintf("%s:%s)\n  %s\n  ;;", c.op, c.job, strings.Join(commands, "\n  ")))
+	}
+
+	spec := codebuildBuildspec{
+		Version: "0.2",
+		Batch: codebuildBatch{
+			FastFail:   true,
+			BuildGraph: graph,
+		},
+		Env: codebuildEnv{
+			Shell: "bash",
+		},
+		Phases: codebuildPhases{
+			Build: codebuildPhase{
+				Commands: []string{fmt.Sprintf("case \"${%s:-diff}:${%s}\" in\n%s\n*)\n  echo \"unknown op and job ${%s:-diff}:${%s}\" >&2\n  exit 1\n  ;;\nesac",
+					CodeBuildOpVar, CodeBuildJobVar,
+					strings.Join(script, "\n"),
+					CodeBuildOpVar, CodeBuildJobVar,
+				)},
+			},
+		},
+	}
+
+	data, err := yaml.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("unable to marshal buildspec: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create directory %q: %w", dir, err)
+	}
+
+	file := "buildspec.yml"
+	if env := e.wf.Options.Env; env != "" {
+		file = fmt.Sprintf("buildspec-%s.yml", env)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, file), data, 0644); err != nil {
+		return fmt.Errorf("unable to write the buildspec: %w", err)
+	}
+
+	return nil
+}
+
+// codebuildOutputsObject returns the S3 URI of the outputs of the job in the current batch.
+// The prefix is required, so that the build fails instead of running with the outputs missing.
+func codebuildOutputsObject(job string) string {
+	return fmt.Sprintf(`"${%s:?}/%s.sh"`, CodeBuildOutputsURIVar, job)
+}
+
+// codebuildIdentifier returns the identifier of the build in the batch build graph,
+// which can contain only alphanumerics and underscores.
+func codebuildIdentifier(job string) string {
+	return strings.ReplaceAll(job, "-", "_")
+}
+
+// codebuildBuildspec is the CodeBuild buildspec.
+// See https://docs.aws.amazon.com/codebuild/latest/userguide/build-spec-ref.html
+type codebuildBuildspec struct {
+	Version string          `yaml:"version"`
+	Batch   codebuildBatch  `yaml:"batch"`
+	Env     codebuildEnv    `yaml:"env"`
+	Phases  codebuildPhases `yaml:"phases"`
+}
+
+// codebuildBatch is the batch build configuration.
+// See https://docs.aws.amazon.com/codebuild/latest/userguide/batch-build-buildspec.html
+type codebuildBatch struct {
+	FastFail   bool             `yaml:"fast-fail"`
+	BuildGraph []codebuildBuild `yaml:"build-graph"`
+}
+
+type codebuildBuild struct {
+	Identifier string            `yaml:"identifier"`
+	DependOn   []string          `yaml:"depend-on,omitempty"`
+	Env        codebuildBuildEnv `yaml:"env"`
+}
+
+type codebuildBuildEnv struct {
+	Image     string            `yaml:"image,omitempty"`
+	Variables map[string]string `yaml:"variables,omitempty"`
+}
+
+type codebuildEnv struct {
+	Shell string `yaml:"shell,omitempty"`
+}
+
+type codebuildPhases struct {
+	Build codebuildPhase `yaml:"build"`
+}
+
+type codebuildPhase struct {
+	Commands []string `yaml:"commands"`
+}
@@ -0,0 +1,297 @@
+package plugin
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/davinci-std/kanvas/expr"
+)
+
+// outputRefFunc returns the reference to the output of the CI job in the target CI,
+// like `needs.infra.outputs.vpc_id` for GitHub Actions or `KANVAS_INFRA_VPC_ID` for the shell-based CIs.
+type outputRefFunc func(job, output string) string
+
+// refJob returns the name of the CI job for the component referenced by the CI job named caller,
+// like `product1-appimage` for `appimage` referenced by `product1-base`.
+func refJob(caller, component string) string {
+	if component[0] == '/' {
+		return jobName(component)
+	}
+
+	return jobName(filepath.Join(strings.ReplaceAll(caller, "-", "/"), "..", component))
+}
+
+// jobOutput is the output of the CI job referenced by an expression.
+type jobOutput struct {
+	job, output string
+}
+
+// refJobs returns the outputs of the CI jobs referenced by the expression made by the CI job named caller.
+func refJobs(caller, s string) ([]jobOutput, error) {
+	refs, err := expr.ParseRefs(s)
+	if err != nil {
+		return nil, err
+	}
+
+	outs := make([]jobOutput, len(refs))
+	for i, r := range refs {
+		outs[i] = jobOutput{job: refJob(caller, r.Component), output: r.Output}
+	}
+
+	return outs, nil
+}
+
+var envVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func checkEnv(e expr.Env) error {
+	if !envVarName.MatchString(e.Name) {
+		return fmt.Errorf("%q is not a valid environment variable name", e.Name)
+	}
+	return nil
+}
+
+// actionsGetter returns the func that translates the expressions made by the CI job named caller
+// into GitHub Actions, to be passed to kargo.Args.Collect.
+func actionsGetter(caller string, ref outputRefFunc) func(string) (string, error) {
+	return func(s string) (string, error) {
+		n, err := expr.Parse(s)
+		if err != nil {
+			return "", err
+		}
+
+		// The environment variables are read by the shell rather than GitHub Actions,
+		// because the env context of GitHub Actions has only the ones defined within the workflow.
+		if sh, err := shellEnv(n); err == nil {
+			return sh, nil
+		}
+
+		x, err := actionsExpr(caller, n, ref)
+		if err != nil {
+			return "", fmt.Errorf("expression %q cannot be expressed in %s: %w", s, FormatGitHubActions, err)
+		}
+
+		return "${{ " + x + " }}", nil
+	}
+}
+
+// shellEnv translates the reference to an environment variable optionally followed by defaults,
+// like `env.REGION ?? "us-east-1"`, into the shell parameter expansion like `${REGION:-us-east-1}`.
+func shellEnv(n expr.Node) (string, error) {
+	switch n := n.(type) {
+	case expr.Env:
+		if err := checkEnv(n); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("${%s}", n.Name), nil
+	case expr.Coalesce:
+		e, ok := n.X.(expr.Env)
+		if !ok {
+			break
+		}
+		if err := checkEnv(e); err != nil {
+			return "", err
+		}
+		var d string
+		if l, ok := n.Default.(expr.Literal); ok {
+			d = shellLiteral(l)
+		} else {
+			var err error
+			d, err = shellEnv(n.Default)
+			if err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("${%s:-%s}", e.Name, d), nil
+	}
+
+	return "", fmt.Errorf("not an environment variable reference")
+}
+
+// actionsExpr translates the node into the GitHub Actions expression to be enclosed within `${{ }}`.
+func actionsExpr(caller string, n expr.Node, ref outputRefFunc) (string, error) {
+	switch n := n.(type) {
+	case expr.Literal:
+		switch v := n.Value.(type) {
+		case string:
+			return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+		case nil:
+			return "null", nil
+		default:
+			return expr.String(v), nil
+		}
+	case expr.Ref:
+		return ref(refJob(caller, n.Component), n.Output), nil
+	case expr.Env:
+		return "", fmt.Errorf("env.%s can be used only on its own or followed by defaults", n.Name)
+	case expr.Index:
+		x, err := actionsJSON(caller, n.X, ref)
+		if err != nil {
+			return "", err
+		}
+		i, err := actionsExpr(caller, n.Index, ref)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s[%s]", x, i), nil
+	case expr.Field:
+		x, err := actionsJSON(caller, n.X, ref)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s['%s']", x, strings.ReplaceAll(n.Name, "'", "''")), nil
+	case expr.Call:
+		var args []string
+		for _, a := range n.Args {
+			x, err := actionsExpr(caller, a, ref)
+			if err != nil {
+				return "", err
+			}
+			args = append(args, x)
+		}
+		switch n.Func {
+		case expr.FuncFormat:
+			return fmt.Sprintf("format(%s)", strings.Join(args, ", ")), nil
+		case expr.FuncJSON:
+			return fmt.Sprintf("fromJSON(%s)", args[0]), nil
+		}
+		return "", fmt.Errorf("unknown function %q", n.Func)
+	case expr.Coalesce:
+		// `||` of GitHub Actions falls back on any falsy value like 0 and false,
+		// which is the same as `??` only when the left-hand side is a string,
+		// like the outputs of the jobs.
+		if _, ok := n.X.(expr.Ref); !ok {
+			return "", fmt.Errorf("the left-hand side of ?? must be an output reference like component.output")
+		}
+		x, err := actionsExpr(caller, n.X, ref)
+		if err != nil {
+			return "", err
+		}
+		d, err := actionsExpr(caller, n.Default, ref)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(%s || %s)", x, d), nil
+	}
+
+	return "", fmt.Errorf("unexpected node %T", n)
+}
+
+// actionsJSON translates the node to be indexed,
+// parsing it as JSON unless it is already parsed,
+// because the outputs of the jobs are always strings in GitHub Actions.
+func actionsJSON(caller string, n expr.Node, ref outputRefFunc) (string, error) {
+	x, err := actionsExpr(caller, n, ref)
+	if err != nil {
+		return "", err
+	}
+
+	switch n := n.(type) {
+	case expr.Index, expr.Field:
+		return x, nil
+	case expr.Call:
+		if n.Func == expr.FuncJSON {
+			return x, nil
+		}
+	}
+
+	return fmt.Sprintf("fromJSON(%s)", x), nil
+}
+
+// shellGetter returns the func that translates the expressions made by the CI job named caller
+// into the shell words for the CI systems that pass outputs across jobs as environment variables,
+// to be passed to kargo.Args.Collect.
+// ref returns the name of the environment variable for the output.
+// When required is true, the shell word fails the command when the variable is unset or empty,
+// so that the output not passed to the job never silently becomes an empty string.
+func shellGetter(format, caller string, required bool, ref outputRefFunc) func(string) (string, error) {
+	return func(s string) (string, error) {
+		n, err := expr.Parse(s)
+		if err != nil {
+			return "", err
+		}
+
+		x, err := shellExpr(caller, n, required, ref)
+		if err != nil {
+			return "", fmt.Errorf("expression %q cannot be expressed in %s: %w", s, format, err)
+		}
+
+		return x, nil
+	}
+}
+
+func shellLiteral(l expr.Literal) string {
+	s := expr.String(l.Value)
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return shellQuote(s)
+}
+
+// shellExpr translates the node into the shell word.
+func shellExpr(caller string, n expr.Node, required bool, ref outputRefFunc) (string, error) {
+	switch n := n.(type) {
+	case expr.Literal:
+		return shellLiteral(n), nil
+	case expr.Ref:
+		if required {
+			return fmt.Sprintf("${%s:?}", ref(refJob(caller, n.Component), n.Output)), nil
+		}
+		return fmt.Sprintf("${%s}", ref(refJob(caller, n.Component), n.Output)), nil
+	case expr.Env:
+		return shellEnv(n)
+	case expr.Index, expr.Field:
+		return "", fmt.Errorf("indexing is not supported, because the outputs are passed as plain environment variables")
+	case expr.Call:
+		if n.Func != expr.FuncFormat {
+			return "", fmt.Errorf("%s() is not supported, because the outputs are passed as plain environment variables", n.Func)
+		}
+		l, ok := n.Args[0].(expr.Literal)
+		if !ok {
+			return "", fmt.Errorf("the format must be a string literal")
+		}
+		f, ok := l.Value.(string)
+		if !ok {
+			return "", fmt.Errorf("the format must be a string literal")
+		}
+		// The literal part of the format is embedded into the shell word as is.
+		blanks := make([]string, len(n.Args)-1)
+		lit, err := expr.Format(f, blanks...)
+		if err != nil {
+			return "", err
+		}
+		if lit != "" && !shellSafe.MatchString(lit) {
+			return "", fmt.Errorf("the format %q contains characters that need quoting in shell", f)
+		}
+		args := make([]string, len(n.Args)-1)
+		for i, a := range n.Args[1:] {
+			x, err := shellExpr(caller, a, required, ref)
+			if err != nil {
+				return "", err
+			}
+			args[i] = x
+		}
+		return expr.Format(f, args...)
+	case expr.Coalesce:
+		var name string
+		switch x := n.X.(type) {
+		case expr.Ref:
+			name = ref(refJob(caller, x.Component), x.Output)
+		case expr.Env:
+			if err := checkEnv(x); err != nil {
+				return "", err
+			}
+			name = x.Name
+		default:
+			return "", fmt.Errorf("the left-hand side of ?? must be an output reference like component.output or an environment variable")
+		}
+		d, err := shellExpr(caller, n.Default, required, ref)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("${%s:-%s}", name, d), nil
+	}
+
+	return "", fmt.Errorf("unexpected node %T", n)
+}
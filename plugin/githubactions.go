@@ -19,10 +19,10 @@ const (
 	FormatDefault = FormatGitHubActions
 )
 
-func (e *Plugin) outputActionsWorkflows(op kanvas.Op, target string) error {
-	outputs := map[string]string{}
-	if err := e.wf.WorkflowJobs[target].Driver.OutputFunc(e.r, op, outputs); err != nil {
-		return fmt.Errorf("unable to process outputs for target %q: %w", target, err)
+func (e *Plugin) outputActionsWorkflows(op kanvas.Op, target string, args []string) error {
+	outputs, err := e.outputs(op, target, args)
+	if err != nil {
+		return err
 	}
 
 	// See https://docs.github.com/en/actions/using-jobs/defining-outputs-for-jobs
@@ -33,8 +33,8 @@ func (e *Plugin) outputActionsWorkflows(op kanvas.Op, target string) error {
 		log.Fatal(err)
 	}
 
-	for k, v := range outputs {
-		if _, err := f.WriteString(fmt.Sprintf("%s=%s\n", k, v)); err != nil {
+	for _, k := range outputKeys(outputs) {
+		if _, err := f.WriteString(fmt.Sprintf("%s=%s\n", k, outputs.String(k))); err != nil {
 			return fmt.Errorf("unable to write a kv to GITHUB_OUTPUT: %w", err)
 		}
 	}
@@ -46,29 +46,154 @@ func (e *Plugin) outputActionsWorkflows(op kanvas.Op, target string) error {
 	return nil
 }
 
+const (
+	// PlanRunIDInput is the input of the apply workflow to specify the run of the plan workflow
+	// whose artifacts like terraform plan files are applied.
+	PlanRunIDInput = "plan-run-id"
+)
+
 func (e *Plugin) exportActionsWorkflows(dir, kanvasContainerImage string) error {
-	w := &actionsWorkflow{
-		Name: "Plan deployment",
-		On: map[string]interface{}{
-			"pull_request": map[string][]string{
+	plan, err := e.newActionsWorkflow("Plan deployment", kanvas.Diff, kanvasContainerImage)
+	if err != nil {
+		return fmt.Errorf("unable to generate the plan workflow definition: %w", err)
+	}
+	plan.On = map[string]interface{}{
+		"pull_request": map[string][]string{
+			"branches":     {"main"},
+			"paths-ignore": {"**.md", "**/docs/**"},
+		},
+	}
+
+	applyName, applyFile := "Apply deployment", "apply_deployment.yaml"
+	if env := e.wf.Options.Env; env != "" {
+		applyName = fmt.Sprintf("Apply %s deployment", env)
+		applyFile = fmt.Sprintf("apply_deployment_%s.yaml", env)
+	}
+
+	apply, err := e.newActionsWorkflow(applyName, kanvas.Apply, kanvasContainerImage)
+	if err != nil {
+		return fmt.Errorf("unable to generate the apply workflow definition: %w", err)
+	}
+	if e.hasArtifacts() {
+		// The apply workflow needs to know the plan workflow run to download the artifacts from.
+		// It is impossible to tell which run to use on push, so we support only workflow_dispatch here.
+		apply.On = map[string]interface{}{
+			"workflow_dispatch": map[string]interface{}{
+				"inputs": map[string]interface{}{
+					PlanRunIDInput: map[string]interface{}{
+						"description": "The ID of the plan workflow run whose plans are applied",
+						"required":    true,
+						"type":        "string",
+					},
+				},
+			},
+		}
+	} else {
+		apply.On = map[string]interface{}{
+			"push": map[string][]string{
 				"branches":     {"main"},
 				"paths-ignore": {"**.md", "**/docs/**"},
 			},
+			"workflow_dispatch": map[string]interface{}{},
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create directory %q: %w", dir, err)
+	}
+
+	planYamlData, err := yaml.Marshal(plan)
+	if err != nil {
+		return fmt.Errorf("unable to marshal plan workflow definition: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "plan_deployment.yaml"), planYamlData, 0644); err != nil {
+		return fmt.Errorf("unable to write the plan workflow definition: %w", err)
+	}
+
+	applyYamlData, err := yaml.Marshal(apply)
+	if err != nil {
+		return fmt.Errorf("unable to marshal apply workflow definition: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, applyFile), applyYamlData, 0644); err != nil {
+		return fmt.Errorf("unable to write the apply workflow definition: %w", err)
+	}
+
+	if !e.wf.Options.Teardown {
+		return nil
+	}
+
+	teardownName, teardownFile := "Tear down deployment", "teardown_deployment.yaml"
+	if env := e.wf.Options.Env; env != "" {
+		teardownName = fmt.Sprintf("Tear down %s deployment", env)
+		teardownFile = fmt.Sprintf("teardown_deployment_%s.yaml", env)
+	}
+
+	teardown, err := e.newActionsTeardownWorkflow(teardownName, kanvasContainerImage)
+	if err != nil {
+		return fmt.Errorf("unable to generate the teardown workflow definition: %w", err)
+	}
+	teardown.On = map[string]interface{}{
+		"pull_request": map[string]interface{}{
+			"types":    []string{"closed"},
+			"branches": []string{"main"},
 		},
-		Jobs: make(map[string]actionsJob, len(e.wf.WorkflowJobs)),
+		"workflow_dispatch": map[string]interface{}{},
 	}
 
-	outputs := map[string]map[string]string{}
+	teardownYamlData, err := yaml.Marshal(teardown)
+	if err != nil {
+		return fmt.Errorf("unable to marshal teardown workflow definition: %w", err)
+	}
 
-	id := func(raw string) string {
-		if raw[0] == '/' {
-			raw = raw[1:]
+	if err := os.WriteFile(filepath.Join(dir, teardownFile), teardownYamlData, 0644); err != nil {
+		return fmt.Errorf("unable to write the teardown workflow definition: %w", err)
+	}
+
+	return nil
+}
+
+func (e *Plugin) hasArtifacts() bool {
+	for _, job := range e.wf.WorkflowJobs {
+		if job.Driver != nil && len(job.Driver.Artifacts) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// newActionsWorkflow returns the workflow that runs the diff or the apply tasks of the jobs,
+// depending on op.
+// The caller is responsible for setting the triggers of the workflow.
+func (e *Plugin) newActionsWorkflow(name string, op kanvas.Op, kanvasContainerImage string) (*actionsWorkflow, error) {
+	w := &actionsWorkflow{
+		Name: name,
+		Jobs: make(map[string]actionsJob, len(e.wf.WorkflowJobs)),
+	}
+
+	tasks := func(job *kanvas.WorkflowJob) []kanvas.Task {
+		if op == kanvas.Apply {
+			return job.Driver.Apply
 		}
-		return strings.ReplaceAll(raw, "/", "-")
+		return job.Driver.Diff
+	}
+
+	opName := "diff"
+	if op == kanvas.Apply {
+		opName = "apply"
 	}
 
+	outputs := map[string]map[string]string{}
+
+	id := jobName
+
 	const (
 		OutputStepID = "out"
+		// InputStepID is the ID of the step that computes the outputs of the job
+		// referenced by the job itself, like the content-addressed tag of the image
+		// to be built by the job.
+		InputStepID = "in"
 	)
 
 	// Traverse the DAG of jobs
@@ -79,28 +204,30 @@ func (e *Plugin) exportActionsWorkflows(dir, kanvasContainerImage string) error
 
 		name = id(name)
 
-		for _, step := range job.Driver.Diff {
+		for _, step := range tasks(job) {
 			for _, c := range step.Run {
+				var err error
 				c.Args.Visit(func(str string) {
 				}, func(a kargo.DynArg) {
-					jobAndOutput := strings.SplitN(a.FromOutput, ".", 2)
-					if len(jobAndOutput) != 2 {
-						// TODO make this error instead
-						panic(fmt.Errorf("Could not find dot(.) within %q", a.FromOutput))
-					}
-					var jobName string
-					if j := jobAndOutput[0]; j[0] == '/' {
-						jobName = id(j)
-					} else {
-						fqn := filepath.Join(strings.ReplaceAll(name, "-", "/"), "..", j)
-						jobName = id(fqn)
+					refs, refErr := refJobs(name, a.FromOutput)
+					if refErr != nil {
+						err = refErr
+						return
 					}
-					if _, ok := outputs[jobName]; !ok {
-						outputs[jobName] = map[string]string{}
+					for _, r := range refs {
+						if r.job == name {
+							continue
+						}
+						if _, ok := outputs[r.job]; !ok {
+							outputs[r.job] = map[string]string{}
+						}
+						outputs[r.job][r.output] = fmt.Sprintf("${{ steps.%s.outputs.%s }}", OutputStepID, r.output)
 					}
-					outputs[jobName][jobAndOutput[1]] = fmt.Sprintf("${{ steps.%s.outputs.%s }}", OutputStepID, jobAndOutput[1])
 				}, func(in kargo.KargoValueProvider) {
 				})
+				if err != nil {
+					return nil, fmt.Errorf("job %q: %w", name, err)
+				}
 			}
 		}
 	}
@@ -117,75 +244,113 @@ func (e *Plugin) exportActionsWorkflows(dir, kanvasContainerImage string) error
 			needs = append(needs, id(n))
 		}
 
-		steps := []actionsStep{
-			stepCheckout(),
-		}
+		var (
+			steps   []actionsStep
+			usesOwn bool
+		)
+
+		get := actionsGetter(name, func(jobName, output string) string {
+			if jobName == name {
+				usesOwn = true
+				return fmt.Sprintf("steps.%s.outputs.%s", InputStepID, output)
+			}
+			return fmt.Sprintf("needs.%s.outputs.%s", jobName, output)
+		})
+
+		ts := tasks(job)
+		for i, s := range ts {
+			var cond string
+			if e.wf.Options.AffectedOnly && !s.Setup {
+				// The job skips its commands when it is not affected by the changes,
+				// but still reads its outputs for the affected jobs that need them.
+				cond = ifAffected(name)
+			}
 
-		for i, s := range job.Driver.Diff {
 			for j, cmd := range s.Run {
 				stepID := cmd.ID
 				if stepID == "" {
-					if len(job.Driver.Diff) == 1 {
+					if len(ts) == 1 {
 						stepID = "run"
 					} else {
 						stepID = fmt.Sprintf("run%d%d", i, j)
 					}
 				}
-				steps = append(steps, stepRun(
-					stepID,
-					cmd,
-					func(out string) (string, error) {
-						jobAndOutput := strings.SplitN(out, ".", 2)
-						if len(jobAndOutput) != 2 {
-							// TODO make this error instead
-							panic(fmt.Errorf("could not find dot(.) within %q", out))
-						}
-						var jobName string
-						if j := jobAndOutput[0]; j[0] == '/' {
-							jobName = id(j)
-						} else {
-							fqn := filepath.Join(strings.ReplaceAll(name, "-", "/"), "..", j)
-							jobName = id(fqn)
-						}
-						return fmt.Sprintf("${{ needs.%s.outputs.%s }}", jobName, jobAndOutput[1]), nil
-					},
-				))
+				step, err := stepRun(stepID, cmd, job.Retry, get)
+				if err != nil {
+					return nil, fmt.Errorf("job %q: %w", name, err)
+				}
+				step.If = cond
+				steps = append(steps, step)
 			}
 		}
 
+		output, err := outputCommand(job.Driver, FormatGitHubActions, opName, get)
+		if err != nil {
+			return nil, fmt.Errorf("job %q: %w", name, err)
+		}
+
+		if usesOwn {
+			steps = append([]actionsStep{{
+				ID:  InputStepID,
+				Run: output,
+			}}, steps...)
+		}
+
+		if len(job.Driver.Artifacts) > 0 {
+			var cond string
+			if e.wf.Options.AffectedOnly {
+				cond = ifAffected(name)
+			}
+
+			if op == kanvas.Apply {
+				download := stepDownloadArtifact(artifactName(name), job.Driver.Artifacts)
+				download.If = cond
+				steps = append([]actionsStep{download}, steps...)
+			} else {
+				upload := stepUploadArtifact(artifactName(name), job.Driver.Artifacts)
+				upload.If = cond
+				steps = append(steps, upload)
+			}
+		}
+
+		if e.wf.Options.AffectedOnly {
+			needs = append(needs, affectedJob)
+		}
+
+		steps = append([]actionsStep{stepCheckout()}, steps...)
+
 		steps = append(steps, actionsStep{
 			ID:  OutputStepID,
-			Run: strings.Join(job.Driver.Output(FormatGitHubActions), " "),
+			Run: output,
 		})
 
+		// The plan workflow only diffs the jobs, so it must not wait for the approval,
+		// nor hold the deployment of the environment.
+		var env string
+		if op == kanvas.Apply {
+			env = actionsEnvironment(job, id)
+		}
+
 		o := outputs[name]
 		j := &actionsJob{
 			RunsOn: "ubuntu-latest",
 			Container: container{
 				Image: kanvasContainerImage,
 			},
-			Outputs: o,
-			Needs:   needs,
-			Steps:   steps,
+			Environment: env,
+			Outputs:     o,
+			Needs:       needs,
+			Steps:       steps,
 		}
 
 		w.AddJob(name, *j)
 	}
 
-	planYamlData, err := yaml.Marshal(w)
-	if err != nil {
-		return fmt.Errorf("unable to marshal plan workflow definition: %w", err)
-	}
-
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("unable to create directory %q: %w", dir, err)
-	}
-
-	if err := os.WriteFile(filepath.Join(dir, "plan_deployment.yaml"), planYamlData, 0644); err != nil {
-		return fmt.Errorf("unable to write the plan workflow definition: %w", err)
+	if e.wf.Options.AffectedOnly {
+		w.AddJob(affectedJob, e.actionsAffectedJob(kanvasContainerImage))
 	}
 
-	return nil
+	return w, nil
 }
 
 type actionsWorkflow struct {
@@ -199,11 +364,32 @@ func (w *actionsWorkflow) AddJob(name string, def actionsJob) {
 }
 
 type actionsJob struct {
-	Needs     []string          `yaml:"needs,omitempty"`
-	RunsOn    string            `yaml:"runs_on"`
-	Container container         `yaml:"container"`
-	Outputs   map[string]string `yaml:"outputs,omitempty"`
-	Steps     []actionsStep     `yaml:"steps"`
+	Needs     []string  `yaml:"needs,omitempty"`
+	RunsOn    string    `yaml:"runs_on"`
+	Container container `yaml:"container"`
+	// Environment is the GitHub Actions environment the job is bound to.
+	// We use it to map kanvas approval gates to the environment protection rules.
+	// See https://docs.github.com/en/actions/deployment/targeting-different-environments/using-environments-for-deployment
+	Environment string            `yaml:"environment,omitempty"`
+	Outputs     map[string]string `yaml:"outputs,omitempty"`
+	Steps       []actionsStep     `yaml:"steps"`
+}
+
+// actionsEnvironment returns the GitHub Actions environment for the job in the apply and teardown workflows,
+// so that the approval gate of the job is enforced by the environment protection rules.
+// GitHub Actions allows only one environment per job,
+// so the first gate wins when the job has two or more gates.
+func actionsEnvironment(job *kanvas.WorkflowJob, id func(string) string) string {
+	if len(job.Approvals) == 0 {
+		return ""
+	}
+
+	g := job.Approvals[0]
+	if g.Environment != "" {
+		return g.Environment
+	}
+
+	return id(g.ID)
 }
 
 type container struct {
@@ -211,7 +397,10 @@ type container struct {
 }
 
 type actionsStep struct {
-	ID   string                 `yaml:"id,omitempty"`
+	ID string `yaml:"id,omitempty"`
+	// If is the condition to run the step.
+	// See https://docs.github.com/en/actions/using-workflows/workflow-syntax-for-github-actions#jobsjob_idstepsif
+	If   string                 `yaml:"if,omitempty"`
 	Run  string                 `yaml:"run,omitempty"`
 	Uses string                 `yaml:"uses,omitempty"`
 	With map[string]interface{} `yaml:"with,omitempty"`
@@ -228,12 +417,65 @@ func stepCheckout() actionsStep {
 	}
 }
 
-func stepRun(id string, cmd kargo.Cmd, get func(string) (string, error)) actionsStep {
-	run := fmt.Sprintf("%s %s", cmd.Name, strings.Join(cmd.Args.MustCollect(get), " "))
+// artifactName returns the name of the artifact that passes the files
+// produced by the job in the plan workflow to the apply workflow.
+func artifactName(job string) string {
+	return "kanvas-" + job
+}
+
+func stepUploadArtifact(name string, paths []string) actionsStep {
+	return actionsStep{
+		Uses: "actions/upload-artifact@v4",
+		With: map[string]interface{}{
+			"name": name,
+			"path": strings.Join(paths, "\n"),
+			// The artifacts are usually under the .kanvas directory,
+			// which is excluded by default for being a hidden directory.
+			"include-hidden-files": true,
+			"if-no-files-found":    "error",
+		},
+	}
+}
+
+func stepDownloadArtifact(name string, paths []string) actionsStep {
+	return actionsStep{
+		Uses: "actions/download-artifact@v4",
+		With: map[string]interface{}{
+			"name": name,
+			// upload-artifact strips the least common ancestor of the paths,
+			// so we need to download the files into the ancestor.
+			"path":         commonDir(paths),
+			"run-id":       fmt.Sprintf("${{ inputs.%s }}", PlanRunIDInput),
+			"github-token": "${{ github.token }}",
+		},
+	}
+}
+
+func commonDir(paths []string) string {
+	d := filepath.Dir(paths[0])
+	for _, p := range paths[1:] {
+		for d != "." && d != "/" && !strings.HasPrefix(p, d+string(filepath.Separator)) {
+			d = filepath.Dir(d)
+		}
+	}
+	return d
+}
+
+// stepRun returns the step that runs the command.
+// The command is wrapped with `kanvas tools retry` when the job has a retry policy.
+// Unlike kanvas that retries the whole job, each command is retried on its own,
+// because GitHub Actions has no way to rerun a job within the same workflow run.
+func stepRun(id string, cmd kargo.Cmd, retry *kanvas.RetryPolicy, get func(string) (string, error)) (actionsStep, error) {
+	args, err := cmd.Args.Collect(get)
+	if err != nil {
+		return actionsStep{}, fmt.Errorf("command %q: %w", cmd.Name, err)
+	}
+
+	run := fmt.Sprintf("%s%s %s", retryCommand(retry), cmd.Name, strings.Join(args, " "))
 
 	return actionsStep{
 		ID:               id,
 		Run:              run,
 		WorkingDirectory: cmd.Dir,
-	}
+	}, nil
 }
@@ -0,0 +1,223 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/davinci-std/kanvas"
+
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	FormatGitLabCI = "gitlabci"
+
+	// GitLabDotenvFile is the dotenv report file written by `kanvas output -f gitlabci`.
+	// Each job of the exported pipeline declares it as the `artifacts:reports:dotenv`,
+	// so that the outputs are available to the downstream jobs as variables.
+	// See https://docs.gitlab.com/ee/ci/yaml/artifacts_reports.html#artifactsreportsdotenv
+	GitLabDotenvFile = "kanvas.env"
+)
+
+func (e *Plugin) outputGitLabCI(op kanvas.Op, target string, args []string) error {
+	outputs, err := e.outputs(op, target, args)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(GitLabDotenvFile)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", GitLabDotenvFile, err)
+	}
+
+	job := jobName(target)
+
+	for _, k := range outputKeys(outputs) {
+		v := outputs.String(k)
+
+		// GitLab rejects the whole dotenv report when any value spans multiple lines
+		if strings.ContainsAny(v, "\r\n") {
+			fmt.Fprintf(os.Stderr, "Skipping output %q of %q because dotenv reports do not support multi-line values\n", k, target)
+			continue
+		}
+
+		if _, err := f.WriteString(fmt.Sprintf("%s=%s\n", outputVar(job, k), v)); err != nil {
+			return fmt.Errorf("unable to write a kv to %s: %w", GitLabDotenvFile, err)
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to close %s: %w", GitLabDotenvFile, err)
+	}
+
+	return nil
+}
+
+func (e *Plugin) exportGitLabCI(dir, kanvasContainerImage string) error {
+	if e.hasArtifacts() {
+		return fmt.Errorf("the %s format does not support passing artifacts like terraform plan files from diff to apply yet", FormatGitLabCI)
+	}
+
+	p := gitlabPipeline{}
+
+	// Plan jobs run on merge requests, while apply jobs run on the default branch,
+	// like the plan and the apply workflows for GitHub Actions.
+	if err := e.addGitLabJobs(p, "plan", kanvas.Diff, kanvasContainerImage, `$CI_PIPELINE_SOURCE == "merge_request_event"`); err != nil {
+		return err
+	}
+	if err := e.addGitLabJobs(p, "apply", kanvas.Apply, kanvasContainerImage, `$CI_COMMIT_BRANCH == $CI_DEFAULT_BRANCH`); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("unable to marshal gitlab ci pipeline definition: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create directory %q: %w", dir, err)
+	}
+
+	file := "kanvas.gitlab-ci.yml"
+	if env := e.wf.Options.Env; env != "" {
+		file = fmt.Sprintf("kanvas-%s.gitlab-ci.yml", env)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, file), data, 0644); err != nil {
+		return fmt.Errorf("unable to write the gitlab ci pipeline definition: %w", err)
+	}
+
+	return nil
+}
+
+// addGitLabJobs adds a GitLab job per kanvas job to p.
+// Each job is named like `$prefix:$job` so that the plan and apply jobs can coexist in the same pipeline.
+func (e *Plugin) addGitLabJobs(p gitlabPipeline, prefix string, op kanvas.Op, kanvasContainerImage, rule string) error {
+	opName := "diff"
+	tasks := func(job *kanvas.WorkflowJob) []kanvas.Task {
+		return job.Driver.Diff
+	}
+	if op == kanvas.Apply {
+		opName = "apply"
+		tasks = func(job *kanvas.WorkflowJob) []kanvas.Task {
+			return job.Driver.Apply
+		}
+	}
+
+	for id, job := range e.wf.WorkflowJobs {
+		if job.Driver == nil {
+			continue
+		}
+
+		name := jobName(id)
+
+		var needs []string
+		for _, n := range job.Needs {
+			needs = append(needs, prefix+":"+jobName(n))
+		}
+
+		var (
+			script  []string
+			usesOwn bool
+		)
+
+		get := shellGetter(FormatGitLabCI, name, false, func(j, output string) string {
+			if j == name {
+				usesOwn = true
+			}
+			return outputVar(j, output)
+		})
+
+		for _, s := range tasks(job) {
+			for _, c := range s.Run {
+				cmd, err := shellCommand(c, get)
+				if err != nil {
+					return fmt.Errorf("job %q: %w", name, err)
+				}
+				script = append(script, cmd)
+			}
+		}
+
+		output, err := outputCommand(job.Driver, FormatGitLabCI, opName, get)
+		if err != nil {
+			return fmt.Errorf("job %q: %w", name, err)
+		}
+
+		if usesOwn {
+			// Unlike the outputs of the upstream jobs,
+			// the outputs of the job itself needs to be loaded from the dotenv file by the job.
+			script = append([]string{
+				output,
+				fmt.Sprintf("set -a && . ./%s && set +a", GitLabDotenvFile),
+			}, script...)
+		}
+
+		script = append(script, output)
+
+		j := gitlabJob{
+			Image: kanvasContainerImage,
+			Needs: needs,
+			Rules: []gitlabRule{
+				{If: rule},
+			},
+			Script: script,
+			Artifacts: gitlabArtifacts{
+				Reports: gitlabReports{
+					Dotenv: GitLabDotenvFile,
+				},
+			},
+		}
+
+		if len(job.Approvals) > 0 {
+			g := job.Approvals[0]
+			env := g.Environment
+			if env == "" {
+				env = jobName(g.ID)
+			}
+
+			j.Environment = &gitlabEnvironment{Name: env}
+			if op == kanvas.Diff {
+				// Plan jobs only prepare the deployment, so that they don't wait for approvals.
+				j.Environment.Action = "prepare"
+			}
+		}
+
+		p[prefix+":"+name] = j
+	}
+
+	return nil
+}
+
+// gitlabPipeline is the GitLab CI pipeline definition, which is a map of jobs keyed by the job names.
+type gitlabPipeline map[string]gitlabJob
+
+type gitlabJob struct {
+	Image string   `yaml:"image"`
+	Needs []string `yaml:"needs,omitempty"`
+	// Environment is the GitLab environment the job is bound to.
+	// We use it to map kanvas approval gates to the protected environments.
+	// See https://docs.gitlab.com/ee/ci/environments/deployment_approvals.html
+	Environment *gitlabEnvironment `yaml:"environment,omitempty"`
+	Rules       []gitlabRule       `yaml:"rules,omitempty"`
+	Script      []string           `yaml:"script"`
+	Artifacts   gitlabArtifacts    `yaml:"artifacts"`
+}
+
+type gitlabEnvironment struct {
+	Name   string `yaml:"name"`
+	Action string `yaml:"action,omitempty"`
+}
+
+type gitlabRule struct {
+	If string `yaml:"if"`
+}
+
+type gitlabArtifacts struct {
+	Reports gitlabReports `yaml:"reports"`
+}
+
+type gitlabReports struct {
+	Dotenv string `yaml:"dotenv"`
+}
@@ -2,8 +2,13 @@ package plugin
 
 import (
 	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/davinci-std/kanvas"
+	"github.com/mumoshu/kargo"
 )
 
 type Plugin struct {
@@ -18,18 +23,196 @@ func New(wf *kanvas.Workflow, r *kanvas.Runtime) *Plugin {
 	}
 }
 
+// Formats is the list of the supported export and output formats
+var Formats = []string{FormatGitHubActions, FormatGitLabCI, FormatCodeBuild}
+
 func (e *Plugin) Export(format string, dir, kanvasContainerImage string) error {
-	if format != FormatGitHubActions {
+	if err := e.checkAffectedOnly(format); err != nil {
+		return err
+	}
+
+	if err := e.checkTeardown(format); err != nil {
+		return err
+	}
+
+	e.skipTests(format)
+
+	switch format {
+	case FormatGitHubActions:
+		return e.exportActionsWorkflows(dir, kanvasContainerImage)
+	case FormatGitLabCI:
+		return e.exportGitLabCI(dir, kanvasContainerImage)
+	case FormatCodeBuild:
+		return e.exportCodeBuild(dir, kanvasContainerImage)
+	default:
 		return fmt.Errorf("unsupported format %q", format)
 	}
+}
+
+// skipTests removes the synthetic tests from the workflow to export with a warning.
+// The tests are run in-process by kanvas, so there is no command to export for them yet.
+func (e *Plugin) skipTests(format string) {
+	var (
+		tests []string
+		jobs  = map[string]*kanvas.WorkflowJob{}
+	)
+	for id, job := range e.wf.WorkflowJobs {
+		if job.IsTest() {
+			tests = append(tests, id)
+			continue
+		}
+		jobs[id] = job
+	}
+
+	if len(tests) == 0 {
+		return
+	}
+
+	sort.Strings(tests)
+
+	fmt.Fprintf(os.Stderr, "Warning: skipping the synthetic tests %s, because the %s format does not support exporting them yet\n", strings.Join(tests, ", "), format)
+
+	var plan [][]string
+	for _, level := range e.wf.Plan {
+		var ids []string
+		for _, id := range level {
+			if _, ok := jobs[id]; ok {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) > 0 {
+			plan = append(plan, ids)
+		}
+	}
 
-	return e.exportActionsWorkflows(dir, kanvasContainerImage)
+	wf := *e.wf
+	wf.WorkflowJobs = jobs
+	wf.Plan = plan
+	e.wf = &wf
 }
 
-func (e *Plugin) Output(op kanvas.Op, format, target string) error {
-	if format != FormatGitHubActions {
+// Output writes the outputs of the target job in the format.
+// args is the OutputArgs of the driver of the job, resolved by the exported workflow.
+func (e *Plugin) Output(op kanvas.Op, format, target string, args []string) error {
+	switch format {
+	case FormatGitHubActions:
+		return e.outputActionsWorkflows(op, target, args)
+	case FormatGitLabCI:
+		return e.outputGitLabCI(op, target, args)
+	case FormatCodeBuild:
+		return e.outputCodeBuild(op, target, args)
+	default:
 		return fmt.Errorf("unsupported format %q", format)
 	}
+}
+
+// outputs returns the outputs of the target job, given the resolved OutputArgs of its driver.
+func (e *Plugin) outputs(op kanvas.Op, target string, args []string) (kanvas.Outputs, error) {
+	d := e.wf.WorkflowJobs[target].Driver
+
+	outputs := kanvas.Outputs{}
+	if len(args) > 0 {
+		if d.OutputArgsFunc == nil {
+			return nil, fmt.Errorf("target %q takes no output args, but got %v", target, args)
+		}
+		if err := d.OutputArgsFunc(args, outputs); err != nil {
+			return nil, fmt.Errorf("unable to process output args for target %q: %w", target, err)
+		}
+	}
+
+	if err := d.OutputFunc(e.r, op, outputs); err != nil {
+		return nil, fmt.Errorf("unable to process outputs for target %q: %w", target, err)
+	}
+
+	return outputs, nil
+}
+
+// outputCommand returns the `kanvas output` command line that writes the outputs of the job in the format,
+// followed by the OutputArgs of the driver translated via get, if any.
+func outputCommand(d *kanvas.Driver, format, op string, get func(string) (string, error)) (string, error) {
+	c := append(d.Output(format), "-o", op)
+
+	if d.OutputArgs != nil {
+		args, err := d.OutputArgs.Collect(get)
+		if err != nil {
+			return "", fmt.Errorf("output args: %w", err)
+		}
+		c = append(append(c, "--"), args...)
+	}
+
+	return strings.Join(c, " "), nil
+}
+
+// jobName returns the name of the CI job for the kanvas job ID,
+// like `product1-appimage` for `/product1/appimage`.
+func jobName(id string) string {
+	if id[0] == '/' {
+		id = id[1:]
+	}
+	return strings.ReplaceAll(id, "/", "-")
+}
+
+// outputKeys returns the names of the outputs, sorted
+// so that the outputs are written in the same order across runs.
+func outputKeys(o kanvas.Outputs) []string {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var outputVarInvalidChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// outputVar returns the name of the environment variable for the output of the CI job,
+// for the CI systems that pass outputs across jobs as environment variables.
+// The variable is prefixed with the job name,
+// because the outputs of all the needed jobs end up in the same set of variables.
+func outputVar(job, output string) string {
+	return outputVarInvalidChars.ReplaceAllString(strings.ToUpper("KANVAS_"+job+"_"+output), "_")
+}
+
+// shellCommand returns the shell command line that runs the command,
+// for the CI systems that have no per-command working directory.
+// A subshell is used to run the command in the dir, so that it doesn't affect the subsequent commands.
+func shellCommand(cmd kargo.Cmd, get func(string) (string, error)) (string, error) {
+	args, err := cmd.Args.Collect(get)
+	if err != nil {
+		return "", fmt.Errorf("command %q: %w", cmd.Name, err)
+	}
+
+	run := fmt.Sprintf("%s %s", cmd.Name, strings.Join(args, " "))
+
+	if cmd.Dir != "" {
+		return fmt.Sprintf("(cd %s && %s)", cmd.Dir, run), nil
+	}
+
+	return run, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./:=@%+-]+$`)
+
+// retryCommand returns the command line prefix that makes the command
+// retried with the policy via `kanvas tools retry`,
+// so that the exported workflows retry the commands like kanvas does.
+// It returns an empty string when the policy is nil.
+func retryCommand(p *kanvas.RetryPolicy) string {
+	if p == nil {
+		return ""
+	}
+
+	args := []string{"kanvas", "tools", kanvas.CommandRetry}
+	for _, f := range p.Flags() {
+		if !shellSafe.MatchString(f) {
+			f = shellQuote(f)
+		}
+		args = append(args, f)
+	}
 
-	return e.outputActionsWorkflows(op, target)
+	return strings.Join(append(args, "--"), " ") + " "
 }
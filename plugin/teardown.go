@@ -0,0 +1,233 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/davinci-std/kanvas"
+)
+
+// checkTeardown returns an error when the format does not support Options.Teardown.
+func (e *Plugin) checkTeardown(format string) error {
+	if e.wf.Options.Teardown && format != FormatGitHubActions {
+		return fmt.Errorf("the %s format does not support exporting the teardown workflow yet", format)
+	}
+
+	return nil
+}
+
+// newActionsTeardownWorkflow returns the workflow that destroys the components
+// in the reverse order of apply.
+// The caller is responsible for setting the triggers of the workflow.
+//
+// Each job destroys a component after all the jobs destroying the components that need it.
+// The jobs of the components needed by the component are not done yet at that point,
+// so the job reads their outputs on its own via `kanvas output`,
+// after running their setup steps like terraform init.
+func (e *Plugin) newActionsTeardownWorkflow(name, kanvasContainerImage string) (*actionsWorkflow, error) {
+	w := &actionsWorkflow{
+		Name: name,
+		Jobs: map[string]actionsJob{},
+	}
+
+	const (
+		// InputStepID is the ID of the step that reads the outputs of the job
+		// referenced by the job itself, like the content-addressed tag of the image
+		// to be deleted by the job.
+		InputStepID = "in"
+	)
+
+	id := jobName
+
+	jobs := map[string]*kanvas.WorkflowJob{}
+	dependents := map[string][]string{}
+	for i, job := range e.wf.WorkflowJobs {
+		if job.Driver == nil {
+			continue
+		}
+
+		jobs[id(i)] = job
+
+		for _, n := range job.Needs {
+			dependents[id(n)] = append(dependents[id(n)], id(i))
+		}
+	}
+
+	for name, job := range jobs {
+		if len(job.Driver.Destroy) == 0 {
+			continue
+		}
+
+		var (
+			steps   []actionsStep
+			usesOwn bool
+			deps    = map[string]struct{}{}
+		)
+
+		collectDeps := func(out string) (string, error) {
+			refs, err := refJobs(name, out)
+			if err != nil {
+				return "", err
+			}
+			for _, r := range refs {
+				if r.job != name {
+					deps[r.job] = struct{}{}
+				}
+			}
+			return "", nil
+		}
+
+		for _, t := range job.Driver.Destroy {
+			// The tasks that only compute the outputs of the job itself are covered by the input step.
+			if t.Func != nil || (t.Exec != nil && !t.ExecOutputsOnly) {
+				return nil, fmt.Errorf("job %q cannot be destroyed in the exported workflow, because it has no command to destroy it", name)
+			}
+
+			// The references are collected rather than visited,
+			// so that the ones within the scripts like the one for argocd are found too.
+			for _, c := range t.Run {
+				if _, err := c.Args.Collect(collectDeps); err != nil {
+					return nil, fmt.Errorf("job %q: %w", name, err)
+				}
+			}
+		}
+
+		// The outputs of the job itself may depend on the outputs of the other jobs, like the docker image tag.
+		if a := job.Driver.OutputArgs; a != nil {
+			if _, err := a.Collect(collectDeps); err != nil {
+				return nil, fmt.Errorf("job %q: %w", name, err)
+			}
+		}
+
+		var setup []actionsStep
+		for _, dep := range sortedKeys(deps) {
+			d, ok := jobs[dep]
+			if !ok {
+				return nil, fmt.Errorf("job %q refers to the outputs of %q, which is not defined", name, dep)
+			}
+
+			for _, t := range d.Driver.Apply {
+				if !t.Setup {
+					continue
+				}
+
+				for _, c := range t.Run {
+					step, err := stepRun(dep+"-"+c.ID, c, d.Retry, stepOutput(dep))
+					if err != nil {
+						return nil, fmt.Errorf("job %q: %w", dep, err)
+					}
+					setup = append(setup, step)
+				}
+			}
+
+			// The teardown workflow reads the outputs of the dependencies only, not of their dependencies.
+			if d.Driver.OutputArgs != nil {
+				return nil, fmt.Errorf("job %q refers to the outputs of %q, which depend on the outputs of the other jobs", name, dep)
+			}
+
+			setup = append(setup, actionsStep{
+				ID:  dep,
+				Run: strings.Join(append(d.Driver.Output(FormatGitHubActions), "-o", "apply"), " "),
+			})
+		}
+
+		for i, t := range job.Driver.Destroy {
+			for j, c := range t.Run {
+				stepID := c.ID
+				if stepID == "" {
+					stepID = fmt.Sprintf("run%d%d", i, j)
+				}
+				step, err := stepRun(
+					stepID,
+					c,
+					job.Retry,
+					actionsGetter(name, func(jobName, output string) string {
+						if jobName == name {
+							usesOwn = true
+							return fmt.Sprintf("steps.%s.outputs.%s", InputStepID, output)
+						}
+						return fmt.Sprintf("steps.%s.outputs.%s", jobName, output)
+					}),
+				)
+				if err != nil {
+					return nil, fmt.Errorf("job %q: %w", name, err)
+				}
+				steps = append(steps, step)
+			}
+		}
+
+		if usesOwn {
+			output, err := outputCommand(job.Driver, FormatGitHubActions, "apply", stepOutput(name))
+			if err != nil {
+				return nil, fmt.Errorf("job %q: %w", name, err)
+			}
+
+			setup = append(setup, actionsStep{
+				ID:  InputStepID,
+				Run: output,
+			})
+		}
+
+		steps = append(append([]actionsStep{stepCheckout()}, setup...), steps...)
+
+		w.AddJob(name, actionsJob{
+			RunsOn: "ubuntu-latest",
+			Container: container{
+				Image: kanvasContainerImage,
+			},
+			Environment: actionsEnvironment(job, id),
+			Needs:       destroyedBefore(name, jobs, dependents),
+			Steps:       steps,
+		})
+	}
+
+	return w, nil
+}
+
+// stepOutput returns the func that resolves the output references made by the CI job named caller
+// to the outputs of the steps that read the outputs of the referenced jobs.
+func stepOutput(caller string) func(string) (string, error) {
+	return actionsGetter(caller, func(jobName, output string) string {
+		return fmt.Sprintf("steps.%s.outputs.%s", jobName, output)
+	})
+}
+
+// destroyedBefore returns the CI jobs that need to be done before destroying the component of the CI job,
+// that is, the jobs that destroy the components needing the component, transitively.
+// The components that have nothing to destroy are skipped over,
+// because there is no CI job for them in the teardown workflow.
+func destroyedBefore(name string, jobs map[string]*kanvas.WorkflowJob, dependents map[string][]string) []string {
+	before := map[string]struct{}{}
+
+	var visit func(n string)
+	visit = func(n string) {
+		for _, d := range dependents[n] {
+			if _, ok := before[d]; ok {
+				continue
+			}
+			before[d] = struct{}{}
+			visit(d)
+		}
+	}
+
+	visit(name)
+
+	var needs []string
+	for _, n := range sortedKeys(before) {
+		if len(jobs[n].Driver.Destroy) > 0 {
+			needs = append(needs, n)
+		}
+	}
+
+	return needs
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,323 @@
+package kanvas
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// probe runs the prober of the test against the target,
+// and writes the result to o.
+// The probe is aborted when either ctx is done, like when the job timed out or kanvas is interrupted,
+// or the timeout elapsed.
+//
+// The outputs always contain "prober", "target", "result", and "duration".
+// Each prober may add its own outputs, like "statusCode" for http.
+func probe(ctx context.Context, t Test, target string, timeout time.Duration, o Outputs) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	o["prober"] = t.Prober
+	o["target"] = target
+
+	start := time.Now()
+
+	var err error
+	switch t.Prober {
+	case ProberHTTP:
+		err = probeHTTP(ctx, t.HTTP, target, o)
+	case ProberTCP:
+		err = probeTCP(ctx, target)
+	case ProberICMP:
+		err = probeICMP(ctx, target, o)
+	case ProberDNS:
+		err = probeDNS(ctx, t.DNS, target, o)
+	default:
+		err = fmt.Errorf("unsupported prober %q", t.Prober)
+	}
+
+	o["duration"] = time.Since(start).String()
+
+	if err != nil {
+		o["result"] = "failure"
+		return fmt.Errorf("%s probe against %q failed: %w", t.Prober, target, err)
+	}
+
+	o["result"] = "success"
+
+	return nil
+}
+
+func probeHTTP(ctx context.Context, conf *HTTPProbe, target string, o Outputs) error {
+	if conf == nil {
+		conf = &HTTPProbe{}
+	}
+
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+
+	method := conf.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	o["statusCode"] = strconv.Itoa(res.StatusCode)
+
+	var valid bool
+	if len(conf.ValidStatusCodes) == 0 {
+		valid = res.StatusCode >= 200 && res.StatusCode < 300
+	} else {
+		for _, c := range conf.ValidStatusCodes {
+			if c == res.StatusCode {
+				valid = true
+				break
+			}
+		}
+	}
+
+	if !valid {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	if conf.BodyRegexp != "" {
+		re, err := regexp.Compile(conf.BodyRegexp)
+		if err != nil {
+			return fmt.Errorf("invalid bodyRegexp %q: %w", conf.BodyRegexp, err)
+		}
+
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("reading response body: %w", err)
+		}
+
+		if !re.Match(body) {
+			return fmt.Errorf("response body does not match %q", conf.BodyRegexp)
+		}
+	}
+
+	return nil
+}
+
+func probeTCP(ctx context.Context, target string) error {
+	var d net.Dialer
+
+	c, err := d.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return err
+	}
+
+	return c.Close()
+}
+
+func probeICMP(ctx context.Context, target string, o Outputs) error {
+	ip, err := net.DefaultResolver.LookupIPAddr(ctx, target)
+	if err != nil {
+		return fmt.Errorf("resolving %q: %w", target, err)
+	}
+
+	var dst *net.IPAddr
+	for _, a := range ip {
+		if a.IP.To4() != nil {
+			dst = &a
+			break
+		}
+	}
+	if dst == nil {
+		return fmt.Errorf("no IPv4 address found for %q", target)
+	}
+
+	// We prefer the unprivileged ICMP socket so that kanvas doesn't need to run as root.
+	// It falls back to the raw socket in case the unprivileged one is not permitted.
+	var (
+		peer net.Addr = &net.UDPAddr{IP: dst.IP}
+		raw  bool
+	)
+	c, err := icmp.ListenPacket("udp4", "0.0.0.0")
+	if err != nil {
+		c, err = icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+		if err != nil {
+			return fmt.Errorf("opening icmp socket: %w", err)
+		}
+		peer = dst
+		raw = true
+	}
+	defer c.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := c.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	// The read is unblocked when ctx is cancelled before the deadline.
+	stop := context.AfterFunc(ctx, func() {
+		_ = c.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	echo := &icmp.Echo{
+		ID:   os.Getpid() & 0xffff,
+		Seq:  rand.Intn(0xffff),
+		Data: []byte("kanvas"),
+	}
+
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: echo,
+	}
+
+	wb, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	if _, err := c.WriteTo(wb, peer); err != nil {
+		return fmt.Errorf("sending echo request: %w", err)
+	}
+
+	rb := make([]byte, 1500)
+	for {
+		n, from, err := c.ReadFrom(rb)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("receiving echo reply: %w", context.Cause(ctx))
+			}
+			return fmt.Errorf("receiving echo reply: %w", err)
+		}
+
+		const protocolICMP = 1
+		rm, err := icmp.ParseMessage(protocolICMP, rb[:n])
+		if err != nil {
+			return fmt.Errorf("parsing icmp message: %w", err)
+		}
+
+		if rm.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+
+		reply, ok := rm.Body.(*icmp.Echo)
+		if !ok || reply.Seq != echo.Seq {
+			continue
+		}
+
+		// The raw socket receives the replies to any process on the host.
+		// The unprivileged socket receives only the replies to itself,
+		// but the kernel rewrites the ID, so it cannot be checked.
+		if raw {
+			if reply.ID != echo.ID {
+				continue
+			}
+			if a, ok := from.(*net.IPAddr); !ok || !a.IP.Equal(dst.IP) {
+				continue
+			}
+		}
+
+		break
+	}
+
+	o["rtt"] = time.Since(start).String()
+
+	return nil
+}
+
+func probeDNS(ctx context.Context, conf *DNSProbe, target string, o Outputs) error {
+	if conf == nil {
+		conf = &DNSProbe{}
+	}
+
+	r := net.DefaultResolver
+	if conf.Server != "" {
+		server := conf.Server
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+
+		r = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	var (
+		answers []string
+		err     error
+	)
+
+	switch t := strings.ToUpper(conf.QueryType); t {
+	case "":
+		answers, err = r.LookupHost(ctx, target)
+	case "A", "AAAA":
+		var ips []net.IPAddr
+		ips, err = r.LookupIPAddr(ctx, target)
+		for _, ip := range ips {
+			if (ip.IP.To4() != nil) == (t == "A") {
+				answers = append(answers, ip.IP.String())
+			}
+		}
+	case "CNAME":
+		var cname string
+		cname, err = r.LookupCNAME(ctx, target)
+		if cname != "" {
+			answers = append(answers, cname)
+		}
+	case "MX":
+		var mxs []*net.MX
+		mxs, err = r.LookupMX(ctx, target)
+		for _, mx := range mxs {
+			answers = append(answers, mx.Host)
+		}
+	case "NS":
+		var nss []*net.NS
+		nss, err = r.LookupNS(ctx, target)
+		for _, ns := range nss {
+			answers = append(answers, ns.Host)
+		}
+	case "TXT":
+		answers, err = r.LookupTXT(ctx, target)
+	default:
+		return fmt.Errorf("unsupported queryType %q", conf.QueryType)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(answers) == 0 {
+		return fmt.Errorf("no answers")
+	}
+
+	sort.Strings(answers)
+
+	o["answers"] = strings.Join(answers, ",")
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package kanvas
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			w.Write([]byte("ok"))
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	t.Run("success", func(t *testing.T) {
+		o := Outputs{}
+		err := probe(context.Background(), Test{Prober: ProberHTTP, HTTP: &HTTPProbe{BodyRegexp: "^ok$"}}, srv.URL+"/healthz", time.Second, o)
+		require.NoError(t, err)
+		require.Equal(t, "success", o["result"])
+		require.Equal(t, "200", o["statusCode"])
+	})
+
+	t.Run("unexpected status code", func(t *testing.T) {
+		o := Outputs{}
+		err := probe(context.Background(), Test{Prober: ProberHTTP}, srv.URL, time.Second, o)
+		require.EqualError(t, err, `http probe against "`+srv.URL+`" failed: unexpected status code 503`)
+		require.Equal(t, "failure", o["result"])
+	})
+
+	t.Run("valid status codes", func(t *testing.T) {
+		o := Outputs{}
+		err := probe(context.Background(), Test{Prober: ProberHTTP, HTTP: &HTTPProbe{ValidStatusCodes: []int{503}}}, srv.URL, time.Second, o)
+		require.NoError(t, err)
+	})
+}
+
+func TestProbe_Cancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(10*time.Millisecond, func() { cancel(errors.New("interrupted")) })
+
+	start := time.Now()
+	err := probe(ctx, Test{Prober: ProberHTTP}, srv.URL, time.Minute, Outputs{})
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 10*time.Second, "the probe must be aborted when the job is cancelled")
+}
+
+func TestProbeTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := l.Addr().String()
+
+	o := Outputs{}
+	require.NoError(t, probe(context.Background(), Test{Prober: ProberTCP}, addr, time.Second, o))
+	require.Equal(t, "success", o["result"])
+	require.Equal(t, addr, o["target"])
+
+	require.NoError(t, l.Close())
+
+	require.Error(t, probe(context.Background(), Test{Prober: ProberTCP}, addr, time.Second, Outputs{}))
+}
@@ -0,0 +1,187 @@
+package kanvas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	// CommandRetry is the name of the `kanvas tools` command
+	// that runs a command with the retry policy.
+	// The exported CI workflows use it to retry the commands
+	// in the same way as kanvas retries the components.
+	CommandRetry = "retry"
+	// FlagRetryAttempts is the flag to specify Retry.Attempts
+	FlagRetryAttempts = "attempts"
+	// FlagRetryBackoff is the flag to specify Retry.Backoff
+	FlagRetryBackoff = "backoff"
+	// FlagRetryJitter is the flag to specify Retry.Jitter
+	FlagRetryJitter = "jitter"
+	// FlagRetryOnStderr is the flag to specify Retry.OnStderr
+	FlagRetryOnStderr = "on-stderr"
+
+	// OutputAttempts is the output of the component with a retry policy,
+	// which is the number of attempts it took to succeed.
+	OutputAttempts = "kanvas.attempts"
+
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 10 * time.Second
+)
+
+// Retry is the retry policy of a component.
+// When diffing or applying the component failed,
+// kanvas reruns the whole component after the backoff.
+type Retry struct {
+	// Attempts is the maximum number of attempts, including the first one.
+	// If zero, this defaults to 3.
+	Attempts int `yaml:"attempts,omitempty"`
+	// Backoff is the duration to wait before the second attempt, in the Go duration format like 10s.
+	// The duration doubles for each subsequent attempt.
+	// If empty, this defaults to 10s.
+	Backoff string `yaml:"backoff,omitempty"`
+	// Jitter is the maximum fraction of the backoff added at random, between 0 and 1.
+	// For example, 0.5 makes the wait before the second attempt somewhere between 10s and 15s
+	// for the default backoff.
+	Jitter float64 `yaml:"jitter,omitempty"`
+	// OnStderr is the list of regular expressions to match against the standard error of the failed command.
+	// If set, the component is retried only when any of them matched,
+	// so that e.g. only the failures due to rate limits are retried.
+	OnStderr []string `yaml:"onStderr,omitempty"`
+}
+
+// RetryPolicy is the validated Retry.
+type RetryPolicy struct {
+	Attempts int
+	Backoff  time.Duration
+	Jitter   float64
+	OnStderr []*regexp.Regexp
+}
+
+// Policy validates the retry config and returns the policy.
+func (r Retry) Policy() (*RetryPolicy, error) {
+	p := &RetryPolicy{
+		Attempts: r.Attempts,
+		Backoff:  defaultRetryBackoff,
+		Jitter:   r.Jitter,
+	}
+
+	if p.Attempts == 0 {
+		p.Attempts = defaultRetryAttempts
+	} else if p.Attempts < 0 {
+		return nil, fmt.Errorf("attempts must be positive, but got %d", r.Attempts)
+	}
+
+	if r.Backoff != "" {
+		d, err := time.ParseDuration(r.Backoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backoff %q: %w", r.Backoff, err)
+		}
+		p.Backoff = d
+	}
+
+	if r.Jitter < 0 || r.Jitter > 1 {
+		return nil, fmt.Errorf("jitter must be between 0 and 1, but got %v", r.Jitter)
+	}
+
+	for _, s := range r.OnStderr {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid onStderr pattern %q: %w", s, err)
+		}
+		p.OnStderr = append(p.OnStderr, re)
+	}
+
+	return p, nil
+}
+
+// Flags returns the flags for the `kanvas tools retry` command
+// that retries a command with the same policy.
+func (p *RetryPolicy) Flags() []string {
+	flags := []string{
+		"--" + FlagRetryAttempts, strconv.Itoa(p.Attempts),
+		"--" + FlagRetryBackoff, p.Backoff.String(),
+	}
+
+	if p.Jitter > 0 {
+		flags = append(flags, "--"+FlagRetryJitter, strconv.FormatFloat(p.Jitter, 'f', -1, 64))
+	}
+
+	for _, re := range p.OnStderr {
+		flags = append(flags, "--"+FlagRetryOnStderr, re.String())
+	}
+
+	return flags
+}
+
+// Retryable returns true when the failure is worth retrying.
+// The standard error of the failed command is matched against the OnStderr patterns, if any.
+// For other errors, the whole error message is matched instead.
+func (p *RetryPolicy) Retryable(err error) bool {
+	if len(p.OnStderr) == 0 {
+		return true
+	}
+
+	text := err.Error()
+
+	var ee *ExecError
+	if errors.As(err, &ee) {
+		text = ee.Stderr
+	}
+
+	for _, re := range p.OnStderr {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delay returns the duration to wait before the attempt, which starts from 1.
+func (p *RetryPolicy) Delay(attempt int) time.Duration {
+	if attempt <= 1 {
+		return 0
+	}
+
+	d := p.Backoff << (attempt - 2)
+	if p.Jitter > 0 {
+		d += time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+
+	return d
+}
+
+// Do calls f until it succeeds, the error is not retryable, or the attempts are exhausted.
+// onRetry is called before waiting for the next attempt.
+// It returns the number of attempts made, and the error of the last attempt.
+func (p *RetryPolicy) Do(ctx context.Context, f func(attempt int) error, onRetry func(attempt int, err error, delay time.Duration)) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := f(attempt)
+		if err == nil {
+			return attempt, nil
+		}
+
+		if attempt >= p.Attempts || !p.Retryable(err) || ctx.Err() != nil {
+			return attempt, err
+		}
+
+		delay := p.Delay(attempt + 1)
+
+		if onRetry != nil {
+			onRetry(attempt, err, delay)
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return attempt, fmt.Errorf("%w: %w", context.Cause(ctx), err)
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package kanvas
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	p, err := Retry{}.Policy()
+	require.NoError(t, err)
+	require.Equal(t, &RetryPolicy{Attempts: 3, Backoff: 10 * time.Second}, p)
+
+	_, err = Retry{Attempts: -1}.Policy()
+	require.EqualError(t, err, "attempts must be positive, but got -1")
+
+	_, err = Retry{Backoff: "10"}.Policy()
+	require.ErrorContains(t, err, `invalid backoff "10"`)
+
+	_, err = Retry{Jitter: 1.5}.Policy()
+	require.EqualError(t, err, "jitter must be between 0 and 1, but got 1.5")
+
+	_, err = Retry{OnStderr: []string{"("}}.Policy()
+	require.ErrorContains(t, err, `invalid onStderr pattern "("`)
+}
+
+func TestRetryPolicy_Flags(t *testing.T) {
+	p, err := Retry{Attempts: 5, Backoff: "1m", Jitter: 0.25, OnStderr: []string{"rate limit"}}.Policy()
+	require.NoError(t, err)
+	require.Equal(t, []string{"--attempts", "5", "--backoff", "1m0s", "--jitter", "0.25", "--on-stderr", "rate limit"}, p.Flags())
+}
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	p, err := Retry{OnStderr: []string{"Rate exceeded"}}.Policy()
+	require.NoError(t, err)
+
+	require.True(t, p.Retryable(&ExecError{Cmd: []string{"terraform"}, Stderr: "Error: Rate exceeded", Err: errors.New("exit status 1")}))
+	require.False(t, p.Retryable(&ExecError{Cmd: []string{"terraform"}, Stderr: "Error: Invalid reference", Err: errors.New("exit status 1")}))
+	// The command line is not matched against the patterns
+	require.False(t, p.Retryable(&ExecError{Cmd: []string{"echo", "Rate exceeded"}, Err: errors.New("exit status 1")}))
+	require.True(t, p.Retryable(errors.New("Rate exceeded")))
+
+	p, err = Retry{}.Policy()
+	require.NoError(t, err)
+	require.True(t, p.Retryable(errors.New("anything")))
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := &RetryPolicy{Attempts: 4, Backoff: time.Second}
+	require.Equal(t, time.Duration(0), p.Delay(1))
+	require.Equal(t, time.Second, p.Delay(2))
+	require.Equal(t, 2*time.Second, p.Delay(3))
+	require.Equal(t, 4*time.Second, p.Delay(4))
+
+	p.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		d := p.Delay(3)
+		require.GreaterOrEqual(t, d, 2*time.Second)
+		require.LessOrEqual(t, d, 3*time.Second)
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	p := &RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+
+	var retried []int
+	attempts, err := p.Do(context.Background(), func(attempt int) error {
+		if attempt < 2 {
+			return errors.New("flaky")
+		}
+		return nil
+	}, func(attempt int, err error, delay time.Duration) {
+		retried = append(retried, attempt)
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+	require.Equal(t, []int{1}, retried)
+
+	attempts, err = p.Do(context.Background(), func(attempt int) error {
+		return errors.New("broken")
+	}, nil)
+	require.EqualError(t, err, "broken")
+	require.Equal(t, 3, attempts)
+
+	p.OnStderr = []*regexp.Regexp{regexp.MustCompile("flaky")}
+	attempts, err = p.Do(context.Background(), func(attempt int) error {
+		return errors.New("broken")
+	}, nil)
+	require.EqualError(t, err, "broken")
+	require.Equal(t, 1, attempts)
+}
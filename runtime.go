@@ -3,17 +3,79 @@ package kanvas
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
 )
 
+// DefaultGracePeriod is the default time to wait for the command to exit
+// after forwarding the interrupt, before killing it.
+const DefaultGracePeriod = 30 * time.Second
+
 type Runtime struct {
+	// GracePeriod is the time to wait for the command to exit after the context is done,
+	// before killing the whole process group of the command.
+	// Commands like terraform need the time to release the state lock and exit cleanly.
+	GracePeriod time.Duration
+
+	ctx context.Context
 }
 
 func NewRuntime() *Runtime {
-	return &Runtime{}
+	return &Runtime{
+		GracePeriod: DefaultGracePeriod,
+	}
+}
+
+// WithContext returns a shallow copy of the runtime whose Exec
+// interrupts the commands when ctx is done.
+func (r *Runtime) WithContext(ctx context.Context) *Runtime {
+	r2 := *r
+	r2.ctx = ctx
+	return &r2
+}
+
+// Context returns the context of the runtime set via WithContext,
+// or the background context when it is not set.
+func (r *Runtime) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// InterruptError is the cause of the cancellation of the context
+// when kanvas received a signal like SIGINT or SIGTERM.
+// The same signal is forwarded to the commands being run.
+type InterruptError struct {
+	Signal os.Signal
+}
+
+func (e *InterruptError) Error() string {
+	return fmt.Sprintf("interrupted by %s", e.Signal)
+}
+
+// ExecError is the error returned by Exec when the command failed.
+type ExecError struct {
+	Cmd []string
+	Dir string
+	// Stderr is the standard error of the command.
+	Stderr string
+	Err    error
+}
+
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("executing %q in %q: %v: %s", e.Cmd, e.Dir, e.Err, e.Stderr)
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
 }
 
 type ExecOption func(*exec.Cmd)
@@ -24,6 +86,14 @@ func ExecStdout(w io.Writer) ExecOption {
 	}
 }
 
+// ExecOutput writes the combined output of the command to w,
+// instead of the standard error of kanvas.
+func ExecOutput(w io.Writer) ExecOption {
+	return func(c *exec.Cmd) {
+		c.Stderr = w
+	}
+}
+
 func ExecAddEnv(env map[string]string) ExecOption {
 	return func(c *exec.Cmd) {
 		c.Env = os.Environ()
@@ -33,28 +103,113 @@ func ExecAddEnv(env map[string]string) ExecOption {
 	}
 }
 
+// Exec runs the command in dir.
+// The command is interrupted when the context of the runtime is done.
+// See WithContext and ExecContext.
 func (r *Runtime) Exec(dir string, cmd []string, opts ...ExecOption) error {
-	c := exec.CommandContext(context.TODO(), cmd[0], cmd[1:]...)
+	return r.ExecContext(r.Context(), dir, cmd, opts...)
+}
+
+// ExecContext is like Exec but interrupts the command when ctx is done.
+//
+// The command is run in its own process group, so that
+// the interrupt reaches all the processes started by the command, like terraform providers.
+// The signal sent is the one kanvas received when ctx is cancelled with an InterruptError,
+// or SIGTERM otherwise, like when the job timed out.
+// The whole process group is killed when it does not exit within the grace period.
+//
+// When the standard input is a terminal, the command is run in the process group of kanvas instead,
+// because a command in a background process group is stopped when it reads from or configures the terminal,
+// like when it prompts for a password.
+// The interrupt from the terminal reaches the whole foreground process group in that case,
+// so it is not forwarded again, and the command is only killed when it does not exit within the grace period.
+func (r *Runtime) ExecContext(ctx context.Context, dir string, cmd []string, opts ...ExecOption) error {
+	c := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
 	c.Dir = dir
+
+	setpgid := !term.IsTerminal(int(os.Stdin.Fd()))
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: setpgid}
+
+	// signal sends sig to the process group of the command when it has its own,
+	// or to the command only otherwise, not to kanvas itself.
+	signal := func(sig syscall.Signal) error {
+		if setpgid {
+			return syscall.Kill(-c.Process.Pid, sig)
+		}
+		return c.Process.Signal(sig)
+	}
+
+	var kill *time.Timer
+	c.Cancel = func() error {
+		sig := syscall.SIGTERM
+		var ie *InterruptError
+		if errors.As(context.Cause(ctx), &ie) {
+			if s, ok := ie.Signal.(syscall.Signal); ok {
+				sig = s
+			}
+		}
+
+		kill = time.AfterFunc(r.GracePeriod, func() {
+			_ = signal(syscall.SIGKILL)
+		})
+
+		// The command in the foreground process group has already received the signal from the terminal.
+		// Forwarding it again makes commands like terraform exit immediately,
+		// leaving the state locked.
+		if !setpgid && ie != nil && fromTerminal(sig) {
+			return nil
+		}
+
+		return signal(sig)
+	}
+	// WaitDelay makes Wait return even when the killed process group left
+	// some processes that hold the stdout or stderr open.
+	c.WaitDelay = r.GracePeriod + time.Second
+
 	for _, o := range opts {
 		o(c)
 	}
+
+	err := r.run(c, dir, cmd)
+
+	if kill != nil {
+		kill.Stop()
+	}
+
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("%w: %w", context.Cause(ctx), err)
+	}
+
+	return err
+}
+
+// fromTerminal returns true when sig is the one the terminal sends to the whole foreground process group,
+// like SIGINT on Ctrl-C.
+func fromTerminal(sig syscall.Signal) bool {
+	return sig == syscall.SIGINT || sig == syscall.SIGQUIT
+}
+
+func (r *Runtime) run(c *exec.Cmd, dir string, cmd []string) error {
 	if c.Stdout != nil {
 		var stderr bytes.Buffer
 
 		c.Stderr = &stderr
 		if err := c.Run(); err != nil {
-			return fmt.Errorf("executing %q in %q: %w: %s", cmd, dir, err, stderr.String())
+			return &ExecError{Cmd: cmd, Dir: dir, Stderr: stderr.String(), Err: err}
 		}
 	} else {
 		var (
 			stdout, stderr bytes.Buffer
+			out            io.Writer = os.Stderr
 		)
-		c.Stdout = io.MultiWriter(&stdout, os.Stderr)
-		c.Stderr = io.MultiWriter(&stderr, os.Stderr)
+		if c.Stderr != nil {
+			out = c.Stderr
+		}
+		c.Stdout = io.MultiWriter(&stdout, out)
+		c.Stderr = io.MultiWriter(&stderr, out)
 		err := c.Run()
 		if err != nil {
-			return fmt.Errorf("executing %q in %q: %w: %s", cmd, dir, err, stderr.String())
+			return &ExecError{Cmd: cmd, Dir: dir, Stderr: stderr.String(), Err: err}
 		}
 	}
 
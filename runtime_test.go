@@ -0,0 +1,43 @@
+package kanvas
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeExecContext_ForwardsSignal(t *testing.T) {
+	r := NewRuntime()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	time.AfterFunc(500*time.Millisecond, func() {
+		cancel(&InterruptError{Signal: os.Interrupt})
+	})
+
+	var out bytes.Buffer
+
+	err := r.ExecContext(ctx, "", []string{"sh", "-c", `trap 'echo interrupted; exit 3' INT; sleep 10 >/dev/null 2>&1 & wait`}, ExecOutput(&out))
+	require.Error(t, err)
+	require.ErrorContains(t, err, "interrupted by interrupt")
+	require.Equal(t, "interrupted\n", out.String())
+}
+
+func TestRuntimeExecContext_KillsAfterGracePeriod(t *testing.T) {
+	r := NewRuntime()
+	r.GracePeriod = 100 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	err := r.ExecContext(ctx, "", []string{"sh", "-c", `trap '' TERM; sleep 10 & wait`})
+	require.Error(t, err)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
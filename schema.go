@@ -0,0 +1,248 @@
+//go:generate go run ./internal/schemadoc schema_doc.go
+
+package kanvas
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SchemaVersion is the version of JSON Schema the generated schema conforms to.
+const SchemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is a JSON Schema of the kanvas config, or of a part of it.
+//
+// It contains only the subset of JSON Schema needed to describe the config,
+// so that the config can be checked against it via Validate without any external validator.
+type JSONSchema struct {
+	Schema      string `json:"$schema,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Type is one of object, array, string, integer, number, and boolean.
+	// It is empty when any value is allowed.
+	Type       string                 `json:"type,omitempty"`
+	Properties map[string]*JSONSchema `json:"properties,omitempty"`
+	// AdditionalProperties is either false, which disallows unknown properties,
+	// or the schema of the values of the object used as a map.
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Defs                 map[string]*JSONSchema `json:"$defs,omitempty"`
+}
+
+// Schema returns the JSON Schema of the config file, like kanvas.yaml,
+// and the JSON rendered from kanvas.jsonnet.
+//
+// Each struct type in the config is defined under $defs and referenced via $ref,
+// with the descriptions taken from the doc comments of the types and their fields.
+func Schema() *JSONSchema {
+	defs := map[string]*JSONSchema{}
+
+	s := schemaOf(reflect.TypeOf(Component{}), defs)
+	s.Schema = SchemaVersion
+	s.Defs = defs
+
+	return s
+}
+
+// schemaOf returns the schema of the type t.
+// The struct types are added to defs and referenced from the returned schema.
+func schemaOf(t reflect.Type, defs map[string]*JSONSchema) *JSONSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		name := schemaDefName(t)
+		if _, ok := defs[name]; !ok {
+			s := &JSONSchema{
+				Type:                 "object",
+				Description:          schemaDescriptions[t.String()],
+				Properties:           map[string]*JSONSchema{},
+				AdditionalProperties: false,
+			}
+			// The def is added before the properties so that recursive types like Component
+			// refer to the def being built instead of building it infinitely.
+			defs[name] = s
+			addSchemaProperties(s, t, defs)
+		}
+		return &JSONSchema{Ref: "#/$defs/" + name}
+	case reflect.Map:
+		return &JSONSchema{
+			Type:                 "object",
+			AdditionalProperties: schemaOf(t.Elem(), defs),
+		}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{
+			Type:  "array",
+			Items: schemaOf(t.Elem(), defs),
+		}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	}
+
+	return &JSONSchema{}
+}
+
+// addSchemaProperties adds the fields of the struct type t to the properties of s,
+// including the ones of the inlined structs like kargo.Config in Kubernetes.
+func addSchemaProperties(s *JSONSchema, t reflect.Type, defs map[string]*JSONSchema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		tag := f.Tag.Get("yaml")
+		if tag == "" {
+			tag = f.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if strings.Contains(opts, "inline") {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			addSchemaProperties(s, ft, defs)
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		p := schemaOf(f.Type, defs)
+		p.Description = schemaDescriptions[t.String()+"."+f.Name]
+		s.Properties[name] = p
+	}
+}
+
+// schemaDefName returns the name of the def of the struct type t,
+// which is qualified by the package name unless it is a kanvas type, like Component and kargo.Helm.
+func schemaDefName(t reflect.Type) string {
+	if t.PkgPath() == reflect.TypeOf(Component{}).PkgPath() {
+		return t.Name()
+	}
+	return t.String()
+}
+
+// Validate checks the value v decoded from JSON or YAML against the schema,
+// and returns the problems found, like `components.app: unknown field "dockr"`.
+// The problems are sorted by the paths to the values.
+func (s *JSONSchema) Validate(v interface{}) []string {
+	var problems []string
+	s.validate(v, "", s.Defs, &problems)
+	sort.Strings(problems)
+	return problems
+}
+
+func (s *JSONSchema) validate(v interface{}, path string, defs map[string]*JSONSchema, problems *[]string) {
+	if s.Ref != "" {
+		def, ok := defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: unresolvable reference %q", schemaPath(path), s.Ref))
+			return
+		}
+		s = def
+	}
+
+	// A null is allowed anywhere, as it is decoded to the zero value.
+	if v == nil || s.Type == "" {
+		return
+	}
+
+	mismatch := func() {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s but got %s", schemaPath(path), s.Type, jsonType(v)))
+	}
+
+	switch s.Type {
+	case "object":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		for k, e := range m {
+			p := joinPath(path, k)
+			if prop, ok := s.Properties[k]; ok {
+				prop.validate(e, p, defs, problems)
+				continue
+			}
+			switch ap := s.AdditionalProperties.(type) {
+			case *JSONSchema:
+				ap.validate(e, p, defs, problems)
+			case bool:
+				if !ap {
+					*problems = append(*problems, fmt.Sprintf("%s: unknown field %q", schemaPath(path), k))
+				}
+			}
+		}
+	case "array":
+		a, ok := v.([]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		if s.Items != nil {
+			for i, e := range a {
+				s.Items.validate(e, fmt.Sprintf("%s[%d]", path, i), defs, problems)
+			}
+		}
+	case "number":
+		if t := jsonType(v); t != "number" && t != "integer" {
+			mismatch()
+		}
+	default:
+		if jsonType(v) != s.Type {
+			mismatch()
+		}
+	}
+}
+
+// jsonType returns the JSON type of the value decoded from JSON or YAML.
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+func schemaPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
@@ -0,0 +1,138 @@
+// Code generated by schemadoc. DO NOT EDIT.
+
+package kanvas
+
+// schemaDescriptions maps the types in the config and their fields to their doc comments,
+// like "kanvas.Component" and "kanvas.Component.Dir".
+var schemaDescriptions = map[string]string{
+	"kanvas.AWS":                             "AWS is an AWS-specific configuration\nThis is currently used to ensure that you have the right AWS credentials\nthat are required to access resources such as ECR and EKS.",
+	"kanvas.AWS.Account":                     "Account is the AWS account to use / associated with\nthe AWS credentials you are using",
+	"kanvas.AWSParam":                        "AWSParam is a reference to an AWS parameter store parameter",
+	"kanvas.AWSParam.Mode":                   "Mode is optional and maps to the `mode` parameter of the `vals` library.\nSee https://github.com/helmfile/vals#aws-ssm-parameter-store for more information.",
+	"kanvas.AWSParam.Path":                   "Path is the path of the parameter",
+	"kanvas.AWSParam.Profile":                "Profile is the AWS profile to be used to access the parameter",
+	"kanvas.AWSParam.Recursive":              "Recursive is optional and maps to the `recursive` parameter of the `vals` library.\nThis is effective only when Mode=singleparam.\nSee https://github.com/helmfile/vals#aws-ssm-parameter-store for more information.",
+	"kanvas.AWSParam.Region":                 "Region is the AWS region to be used to access the parameter",
+	"kanvas.AWSParam.RoleARN":                "RoleARN is the ARN of the role to be assumed to access the parameter",
+	"kanvas.AWSParam.SubPath":                "SubPath is the subpath of the parameter",
+	"kanvas.AWSSecret":                       "AWSSecret is a reference to an AWS secret manager secret\nSee https://github.com/helmfile/vals#aws-secrets-manager\nfor more details.",
+	"kanvas.AWSSecret.ARN":                   "ARN is the ARN of the secret\nExamples:\n- arn:aws:secretsmanager:<REGION>:<ACCOUNT_ID>:secret:/myteam/mydoc",
+	"kanvas.AWSSecret.ID":                    "ID is the ID of the secret\nExamples:\n- myteam/mydoc",
+	"kanvas.AWSSecret.Path":                  "Path is the key path to the value within the json-decoded secret.\nIf empty, the whole content of the secret is returned as-is.\nIf non-empty, the content of the secret denoted by ID or ARN is decoded as json,\nand the value at the path is returned.\nWe don't currently call this \"json path\" because it is not well-known JSONPath.",
+	"kanvas.AWSSecret.Profile":               "Profile is the AWS profile to be used to access the secret",
+	"kanvas.AWSSecret.Region":                "Region is the AWS region to be used to access the secret",
+	"kanvas.AWSSecret.RoleARN":               "RoleARN is the ARN of the role to be assumed to access the secret",
+	"kanvas.AWSSecret.VersionID":             "VersionID is the version ID of the secret",
+	"kanvas.Approval":                        "Approval is a manual approval gate.\nWhen a component or an environment has an approval,\nkanvas pauses the apply before the gated job(s),\nshows the diff, and waits for the approval.",
+	"kanvas.Approval.Environment":            "Environment is the name of the GitHub Actions environment\nthat the gated jobs are bound to when exported to GitHub Actions.\nThe protection rules of the environment, like required reviewers,\nact as the approval gate.\nIf empty, this defaults to the environment name for an environment approval,\nand to the component ID for a component approval.",
+	"kanvas.BackendConfig":                   "BackendConfig is a backend configuration to be passed to terraform init",
+	"kanvas.BackendConfig.File":              "File is the path to the backend configuration file.\nThis is relative to the dir of the component.\nFile is mutually exclusive with Name and Value.",
+	"kanvas.BackendConfig.Name":              "Name is the name of the backend configuration",
+	"kanvas.BackendConfig.Value":             "Value is the value of the backend configuration",
+	"kanvas.Component":                       "Component is a component of the application",
+	"kanvas.Component.AWS":                   "AWS is an AWS-specific configuration\nThis is currently used to ensure that you have the right AWS credentials\nthat are required to access resources such as ECR and EKS.",
+	"kanvas.Component.AlwaysRun":             "AlwaysRun makes kanvas apply this component every time,\neven when its dir, config, and inputs have not changed since the last successful apply.\nSet this for components that depend on something outside of kanvas, like the current time.",
+	"kanvas.Component.Approval":              "Approval is the manual approval gate that needs to be approved before applying this component",
+	"kanvas.Component.Components":            "Components is a map of sub-components",
+	"kanvas.Component.Dir":                   "Dir is the directory to be chdir'ed before running the commands\nIf empty, this defaults to the base dir, which is where kanvas.yaml is located.",
+	"kanvas.Component.Docker":                "Docker is a docker-specific configuration",
+	"kanvas.Component.Environments":          "Environments is a map of environments",
+	"kanvas.Component.Externals":             "Externals exposes external parameters and secrets as the component's outputs",
+	"kanvas.Component.GitHubFiles":           "GitHubFiles is the configuration for the github-files driver",
+	"kanvas.Component.Kubernetes":            "Kubernetes is a kubernetes-specific configuration",
+	"kanvas.Component.Needs":                 "Needs is a list of components that this component depends on.\nThe components whose outputs are referenced via valueFrom and so on are inferred,\nso this needs to list only the other ones.",
+	"kanvas.Component.Noop":                  "Noop is a noop configuration that does nothing\nThis is mainly for template components that are only used as dependencies.\nYou override or replaces this with a real component in the environment.",
+	"kanvas.Component.Retry":                 "Retry is the retry policy for diffing or applying this component.\nIf empty, the component is never retried.",
+	"kanvas.Component.Terraform":             "Terraform is a terraform-specific configuration",
+	"kanvas.Component.Tests":                 "Tests is a map of synthetic tests that are run after the components they need are applied",
+	"kanvas.Component.Timeout":               "Timeout is the maximum duration of diffing or applying this component, in the Go duration format like 30m.\nThe commands being run are interrupted when the component times out.\nIf empty, the component never times out.",
+	"kanvas.Component.Watch":                 "Watch is a list of extra paths whose changes affect this component, in addition to the dir.\nEach path is relative to the dir, or to the base dir when it starts with a slash.\nA path can be a file, a directory, or a glob pattern like path.Match, where `**` matches zero or more directories.\nThis is used by `kanvas affected` to find the components affected by the changes.",
+	"kanvas.DNSProbe":                        "DNSProbe contains the settings specific to the dns prober",
+	"kanvas.DNSProbe.QueryType":              "QueryType is the type of the DNS query.\nThe supported values are A, AAAA, CNAME, MX, NS, and TXT.\nIf empty, the name is resolved to any addresses.",
+	"kanvas.DNSProbe.Server":                 "Server is the DNS server to query, in the form of host or host:port.\nIf empty, the system resolver is used.",
+	"kanvas.Docker":                          "Docker is a docker-specific configuration",
+	"kanvas.Docker.Args":                     "Args is a map of build args",
+	"kanvas.Docker.ArgsFrom":                 "ArgsFrom is a map of dynamic build args from the outputs of other components",
+	"kanvas.Docker.DeleteOnDestroy":          "DeleteOnDestroy makes `kanvas destroy` delete the tag of the image pushed by `kanvas apply` from the registry.\nThe tag is deleted via `regctl tag delete`, so regctl needs to be installed and logged in to the registry.\nThis cannot be used with Kind, because the image is not pushed to any registry.",
+	"kanvas.Docker.File":                     "File is the path to the Dockerfile",
+	"kanvas.Docker.Image":                    "Image is the name of the image to be built",
+	"kanvas.Docker.Kind":                     "Kind configures kanvas's behavior when pushing the image to a local kind cluster\nAn non-nil value means that the image will be pushed to a local kind cluster.\nWe don't auto-determine the necessity of pushing to kind, so you need to set this explicitly.\nThis is to give you freedom to push to a remote registry even when you are using kind.",
+	"kanvas.Docker.TagsFrom":                 "TagsFrom is a list of tags to be added to the image, derived from the outputs of other components",
+	"kanvas.Environment":                     "Environment is a set of sub-components to replace the defaults",
+	"kanvas.Environment.After":               "After is an alias of Needs",
+	"kanvas.Environment.Approval":            "Approval is the manual approval gate that needs to be approved before applying this environment",
+	"kanvas.Environment.Defaults":            "Defaults is the environment-specific defaults",
+	"kanvas.Environment.Needs":               "Needs is a list of environments that need to be applied before this environment.\nWhen you apply this environment, the environments it needs are applied first\nwithin the same run.",
+	"kanvas.Environment.Overrides":           "Overrides is a set of sub-components to override the env and component defaults",
+	"kanvas.Environment.Uses":                "Uses is a set of sub-components to replace the defaults",
+	"kanvas.Externals":                       "Externals exposes external parameters and secrets as the component's outputs.\nInternally, externals invoke the `vals` library to fetch the values from external sources\nlike AWS parameter store, AWS secret manager, etc.",
+	"kanvas.Externals.Outputs":               "Outputs defines the mapping from output names to the vals refs",
+	"kanvas.GitHubFiles":                     "GitHubFiles driver is a driver that pushes files and optionally run kustomize-build to/on the target repository/branch.\n\nThe driver requires the following environment variables to be set:\n- GITHUB_TOKEN: a GitHub token with write access to the target repository\n- GITHUB_REPOSITORY: the target repository in the OWNER/REPO_NAME format\n\nNote that the changes are always pushed to a new branch and a pull request is created to merge the changes to the target branch,\ninstead of pushing the changes directly to the target branch.",
+	"kanvas.GitHubFiles.Branch":              "Branch is the branch to push the changes to",
+	"kanvas.GitHubFiles.Path":                "Path to the gitimpart jsonnet file to use",
+	"kanvas.GitHubFiles.Repo":                "Repo is the repository to push the changes to",
+	"kanvas.GoogleSheetCell":                 "GoogleSheetCell is a reference to a Google Sheet cell",
+	"kanvas.GoogleSheetCell.CredentialsFile": "CredentialsFile is the path to the credentials.json file",
+	"kanvas.GoogleSheetCell.Key":             "Key is the key of the value to be fetched from the Google Sheet\nWe assume the first column is the key, and the second column is the value",
+	"kanvas.GoogleSheetCell.SheetID":         "SheetID is the SheetID of the Google Sheet",
+	"kanvas.HTTPProbe":                       "HTTPProbe contains the settings specific to the http prober",
+	"kanvas.HTTPProbe.BodyRegexp":            "BodyRegexp is the regular expression that the response body must match.",
+	"kanvas.HTTPProbe.Method":                "Method is the HTTP method to use.\nIf empty, this defaults to GET.",
+	"kanvas.HTTPProbe.ValidStatusCodes":      "ValidStatusCodes is the list of status codes that are considered successful.\nIf empty, this defaults to any 2xx status code.",
+	"kanvas.Kind":                            "Kind contains settings for pushing the image to a local kind cluster",
+	"kanvas.Kind.ClusterName":                "ClusterName is the name of the kind cluster\nIf empty, this defaults to \"kind\"",
+	"kanvas.Kubernetes":                      "Kubernetes is a kubernetes-specific configuration",
+	"kanvas.Noop":                            "Noop is a noop configuration that does nothing\nThis is mainly for template components that are only used as dependencies.\nYou override or replaces this with a real component in the environment.",
+	"kanvas.OutputFrom":                      "Var is a variable to be passed to terraform",
+	"kanvas.OutputFrom.AWSParam":             "AWSParam is a reference to an AWS parameter store parameter",
+	"kanvas.OutputFrom.AWSSecret":            "AWSSecret is a reference to an AWS secret manager secret",
+	"kanvas.OutputFrom.GoogleSheetCell":      "GoogleSheetCell is a reference to a Google Sheet cell",
+	"kanvas.OutputFrom.TerraformState":       "TerraformState is a reference to a terraform state",
+	"kanvas.Retry":                           "Retry is the retry policy of a component.\nWhen diffing or applying the component failed,\nkanvas reruns the whole component after the backoff.",
+	"kanvas.Retry.Attempts":                  "Attempts is the maximum number of attempts, including the first one.\nIf zero, this defaults to 3.",
+	"kanvas.Retry.Backoff":                   "Backoff is the duration to wait before the second attempt, in the Go duration format like 10s.\nThe duration doubles for each subsequent attempt.\nIf empty, this defaults to 10s.",
+	"kanvas.Retry.Jitter":                    "Jitter is the maximum fraction of the backoff added at random, between 0 and 1.\nFor example, 0.5 makes the wait before the second attempt somewhere between 10s and 15s\nfor the default backoff.",
+	"kanvas.Retry.OnStderr":                  "OnStderr is the list of regular expressions to match against the standard error of the failed command.\nIf set, the component is retried only when any of them matched,\nso that e.g. only the failures due to rate limits are retried.",
+	"kanvas.Terraform":                       "Terraform is a terraform-specific configuration",
+	"kanvas.Terraform.BackendConfig":         "BackendConfig is a list of backend configurations to be passed to terraform init\na.k.a `-backend-config $name=$value` or `-backend-config $file`",
+	"kanvas.Terraform.Parallelism":           "Parallelism is the number of concurrent operations as terraform walks the graph\na.k.a `-parallelism $n`.\nIf zero, terraform's default is used.",
+	"kanvas.Terraform.Refresh":               "Refresh specifies whether terraform refreshes the state before planning and applying\na.k.a `-refresh=$refresh`.\nIf unset, terraform's default is used.",
+	"kanvas.Terraform.Target":                "Target is the target resource to be deployed",
+	"kanvas.Terraform.VarFiles":              "VarFiles is a list of paths to the var files to be passed to terraform plan and apply\na.k.a `-var-file $file`.\nEach path is relative to the dir of the component.",
+	"kanvas.Terraform.Vars":                  "Vars is a list of variables to be passed to terraform",
+	"kanvas.Terraform.Workspace":             "Workspace is the terraform workspace to be selected before running plan and apply.\nThe workspace is created if it does not exist.\nIf empty, the current workspace is used as-is.",
+	"kanvas.TerraformState.Expr":             "Expr is the expression to be evaluated against the terraform state to fetch the value\nOnly dot-notation is supported.\nExample: output.OUTPUT_NAME",
+	"kanvas.TerraformState.Path":             "Path is the path to the terraform state file\nThis is relative to the base dir, which is where kanvas.yaml is located.\nExample: terraform/terraform.tfstate",
+	"kanvas.TerraformState.URL":              "URL is the URL to the terraform state file\nEither s3:// or gs:// is supported.\nExample: s3://mybucket/terraform.tfstate",
+	"kanvas.Test":                            "Test is a synthetic test that probes the deployed application or infrastructure.\nEach test is run after the components it needs are applied,\nand the whole apply fails when any of the tests failed.",
+	"kanvas.Test.DNS":                        "DNS contains the settings specific to the dns prober",
+	"kanvas.Test.HTTP":                       "HTTP contains the settings specific to the http prober",
+	"kanvas.Test.Needs":                      "Needs is a list of components that need to be applied before running the test.\nThe component referenced by TargetFrom is implicitly added to this list.",
+	"kanvas.Test.Prober":                     "Prober is the kind of the probe to run.\nThe supported values are http, tcp, icmp, and dns.",
+	"kanvas.Test.Target":                     "Target is the target of the probe.\nIt is a URL for http, a host:port for tcp, a host for icmp,\nand a name to resolve for dns.",
+	"kanvas.Test.TargetFrom":                 "TargetFrom is the source of the target of the probe, in the form of component.output.\nIt can be an expression like `format(\"https://{0}/healthz\", app.host)`.",
+	"kanvas.Test.Timeout":                    "Timeout is the timeout of the probe in the Go duration format like 5s.\nIf empty, this defaults to 10s.",
+	"kanvas.Var":                             "Var is a variable to be passed to terraform",
+	"kanvas.Var.Name":                        "Name is the name of the variable",
+	"kanvas.Var.Value":                       "Value is the value of the variable",
+	"kanvas.Var.ValueFrom":                   "ValueFrom is the source of the value of the variable.\nIt is an expression like `infra.vpc_id` or `infra.subnet_ids[0] ?? \"none\"`.",
+	"kargo.ArgoCD.Branch":                    "Branch is the branch to be used for the deployment.\nThis isn't part of the arguments for argocd-repo-add because\nit doesn't support branch.\nHowever, we use it when you want to push manifests to a branch\nand trigger a deployment.",
+	"kargo.ArgoCD.ConfigManagementPlugin":    "ConfigManagementPlugin is the config management plugin to be used.",
+	"kargo.ArgoCD.DestName":                  "DestName is the name of the K8s cluster where the deployment is to be done.",
+	"kargo.ArgoCD.DestNameFrom":              "DestNameFrom is the key to be used to get the target K8s cluster name from the environment.",
+	"kargo.ArgoCD.DestNamespace":             "DestNamespace is the namespace to be used for the deployment.",
+	"kargo.ArgoCD.DestServer":                "DestServer is the Kubernetes API endpoint of the cluster where the deployment is to be done.",
+	"kargo.ArgoCD.DestServerFrom":            "DestServerFrom is the key to be used to get the target Kubernetes API endpoint from the environment.",
+	"kargo.ArgoCD.Insecure":                  "Insecure is set to true if the user wants to skip TLS verification.",
+	"kargo.ArgoCD.InsecureFrom":              "InsecureFrom is the key to be used to get the insecure flag from the environment.",
+	"kargo.ArgoCD.Password":                  "Password is the password to be used for the deployment.",
+	"kargo.ArgoCD.PasswordFrom":              "PasswordFrom is the key to be used to get the password from the environment.",
+	"kargo.ArgoCD.Project":                   "Project is the ArgoCD project to be used for the deployment.",
+	"kargo.ArgoCD.Push":                      "Push is set to true if the user wants kargo to automatically\n- git-clone the repo\n- git-add the files in the config.Path\n- git-commit\n- git-push\nso that it triggers the deployment.",
+	"kargo.ArgoCD.Server":                    "Server is the ArgoCD server to be used for the deployment.",
+	"kargo.ArgoCD.ServerFrom":                "ServerFrom is the key to be used to get the ArgoCD server from the environment.",
+	"kargo.ArgoCD.Username":                  "Username is the username to be used for the deployment.",
+	"kargo.ArgoCD.UsernameFrom":              "UsernameFrom is the key to be used to get the username from the environment.",
+	"kargo.Config.Name":                      "Name is the application name.\nIt defaults to the basename of the path if\nkargo is run as a command.",
+	"kargo.Kustomize.Strategy":               "Strategy is the strategy to be used for the deployment.\n\nThe supported values are:\n- BuildAndKubectlApply\n- SetImageAndCreatePullRequest\n\nBuildAndKubectlApply is the default strategy.\nIt runs kustomize build and kubectl apply to deploy the application.\n\nSetImageAndCreatePullRequest runs kustomize edit set image and creates a pull request.\nIt's useful to trigger a deployment workflow in CI/CD.",
+}
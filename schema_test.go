@@ -0,0 +1,111 @@
+package kanvas_test
+
+import (
+	"testing"
+
+	"github.com/davinci-std/kanvas"
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	s := kanvas.Schema()
+
+	require.Equal(t, kanvas.SchemaVersion, s.Schema)
+	require.Equal(t, "#/$defs/Component", s.Ref)
+
+	for _, name := range []string{"Component", "Environment", "Docker", "Terraform", "Externals", "GitHubFiles", "kargo.Kustomize", "kargo.ArgoCD"} {
+		require.Contains(t, s.Defs, name)
+		require.Equal(t, "object", s.Defs[name].Type, name)
+		require.Equal(t, false, s.Defs[name].AdditionalProperties, name)
+	}
+
+	component := s.Defs["Component"]
+	require.Equal(t, "Component is a component of the application", component.Description)
+	require.Equal(t, &kanvas.JSONSchema{
+		Ref:         "#/$defs/Terraform",
+		Description: "Terraform is a terraform-specific configuration",
+	}, component.Properties["terraform"])
+	require.Equal(t, &kanvas.JSONSchema{
+		Type:                 "object",
+		Description:          "Components is a map of sub-components",
+		AdditionalProperties: &kanvas.JSONSchema{Ref: "#/$defs/Component"},
+	}, component.Properties["components"])
+
+	// The fields of kargo.Config are inlined into kubernetes
+	kubernetes := s.Defs["Kubernetes"]
+	require.Equal(t, "#/$defs/kargo.Kustomize", kubernetes.Properties["kustomize"].Ref)
+	require.Equal(t, "string", kubernetes.Properties["name"].Type)
+	require.Contains(t, kubernetes.Properties["name"].Description, "Name is the application name.")
+}
+
+func TestSchema_Validate(t *testing.T) {
+	testcases := []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{
+			name: "valid",
+			config: `
+components:
+  infra:
+    dir: infra
+    terraform:
+      target: aws_vpc.main
+      parallelism: 10
+      vars:
+      - name: region
+        value: us-east-1
+  app:
+    needs:
+    - infra
+    kubernetes:
+      kustomize:
+        images:
+        - name: example/app
+          newTagFrom: image.tag
+environments:
+  production:
+    overrides:
+      infra:
+        terraform:
+          workspace: production
+`,
+		},
+		{
+			name: "invalid",
+			config: `
+components:
+  infra:
+    workspace: production
+    terraform:
+      parallelism: high
+      vars:
+      - name: region
+        valueFrom: [infra.region]
+  app:
+    needs: infra
+    kubernetes:
+      argocd:
+        imageFrom: image.id
+`,
+			want: []string{
+				`components.app.kubernetes.argocd: unknown field "imageFrom"`,
+				`components.app.needs: expected array but got string`,
+				`components.infra.terraform.parallelism: expected integer but got string`,
+				`components.infra.terraform.vars[0].valueFrom: expected string but got array`,
+				`components.infra: unknown field "workspace"`,
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var config interface{}
+			require.NoError(t, yaml.Unmarshal([]byte(tc.config), &config))
+
+			require.Equal(t, tc.want, kanvas.Schema().Validate(config))
+		})
+	}
+}
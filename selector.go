@@ -0,0 +1,161 @@
+package kanvas
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// selectJobs narrows the plan down to the jobs selected via Options.Only.
+//
+// The jobs needed by the selected jobs but not selected themselves stay in the plan as OutputOnly,
+// so that the selected jobs can still read their outputs.
+// The other jobs are removed from the plan.
+func (wf *Workflow) selectJobs() error {
+	if len(wf.Options.Only) == 0 {
+		return nil
+	}
+
+	inPlan := map[string]struct{}{}
+	for _, phase := range wf.Plan {
+		for _, id := range phase {
+			inPlan[id] = struct{}{}
+		}
+	}
+
+	selected := map[string]struct{}{}
+	for _, pattern := range wf.Options.Only {
+		var matched bool
+		for id := range inPlan {
+			ok, err := MatchID(pattern, id)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				selected[id] = struct{}{}
+				matched = true
+			}
+		}
+
+		if !matched {
+			return fmt.Errorf("no component matches %q", pattern)
+		}
+	}
+
+	if wf.Options.WithDeps {
+		var visit func(id string)
+		visit = func(id string) {
+			for _, n := range wf.WorkflowJobs[id].Needs {
+				if _, ok := selected[n]; ok {
+					continue
+				}
+				selected[n] = struct{}{}
+				visit(n)
+			}
+		}
+
+		for _, id := range sortedKeys(selected) {
+			visit(id)
+		}
+	}
+
+	if wf.Options.WithDependents {
+		dependents := map[string][]string{}
+		for id := range inPlan {
+			for _, n := range wf.WorkflowJobs[id].Needs {
+				dependents[n] = append(dependents[n], id)
+			}
+		}
+
+		var visit func(id string)
+		visit = func(id string) {
+			for _, d := range dependents[id] {
+				if _, ok := selected[d]; ok {
+					continue
+				}
+				selected[d] = struct{}{}
+				visit(d)
+			}
+		}
+
+		for _, id := range sortedKeys(selected) {
+			visit(id)
+		}
+	}
+
+	for id := range selected {
+		for _, n := range wf.WorkflowJobs[id].Needs {
+			if _, ok := selected[n]; ok {
+				continue
+			}
+
+			if _, ok := inPlan[n]; ok {
+				wf.WorkflowJobs[n].OutputOnly = true
+			}
+		}
+	}
+
+	var plan [][]string
+	for _, phase := range wf.Plan {
+		var ids []string
+		for _, id := range phase {
+			if _, ok := selected[id]; ok || wf.WorkflowJobs[id].OutputOnly {
+				ids = append(ids, id)
+			}
+		}
+
+		if len(ids) > 0 {
+			plan = append(plan, ids)
+		}
+	}
+
+	wf.Plan = plan
+
+	return nil
+}
+
+// MatchID reports whether the job ID matches the selector pattern.
+//
+// The pattern is a glob like path.Match, matched against the segments of the ID.
+// An absolute pattern like /product1/* is matched from the root,
+// whereas a relative pattern like argocd matches the trailing segments of any ID, like /product1/argocd.
+// A pattern matching a component also matches all its sub-components.
+func MatchID(pattern, id string) (bool, error) {
+	abs := strings.HasPrefix(pattern, "/")
+
+	p := normalize(strings.Trim(pattern, "/"))
+	if p == "" {
+		return false, fmt.Errorf("invalid selector %q: empty pattern", pattern)
+	}
+
+	n := strings.Count(p, "/") + 1
+	segs := strings.Split(strings.TrimPrefix(id, "/"), "/")
+
+	for i := 0; i+n <= len(segs); i++ {
+		if abs && i > 0 {
+			break
+		}
+
+		ok, err := path.Match(p, strings.Join(segs[i:i+n], "/"))
+		if err != nil {
+			return false, fmt.Errorf("invalid selector %q: %w", pattern, err)
+		}
+
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,90 @@
+package kanvas_test
+
+import (
+	"testing"
+
+	"github.com/davinci-std/kanvas"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchID(t *testing.T) {
+	testcases := []struct {
+		pattern string
+		id      string
+		want    bool
+	}{
+		{pattern: "app", id: "app", want: true},
+		{pattern: "/app", id: "app", want: true},
+		{pattern: "argocd", id: "/product1/argocd", want: true},
+		{pattern: "/argocd", id: "/product1/argocd", want: false},
+		{pattern: "product1", id: "/product1/argocd", want: true},
+		{pattern: "/product1/*", id: "/product1/argocd", want: true},
+		{pattern: "prod*/argo*", id: "/product1/argocd", want: true},
+		{pattern: "Product 1", id: "/product-1/argocd", want: true},
+		{pattern: "argocd_resources", id: "/product1/argocd", want: false},
+	}
+
+	for _, tc := range testcases {
+		got, err := kanvas.MatchID(tc.pattern, tc.id)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, got, "MatchID(%q, %q)", tc.pattern, tc.id)
+	}
+
+	_, err := kanvas.MatchID("[", "app")
+	require.ErrorContains(t, err, `invalid selector "["`)
+}
+
+func TestWorkflowLoad_Only(t *testing.T) {
+	testcases := []struct {
+		name       string
+		opts       kanvas.Options
+		plan       [][]string
+		outputOnly []string
+	}{
+		{
+			name:       "only",
+			opts:       kanvas.Options{Only: []string{"deploy"}},
+			plan:       [][]string{{"image"}, {"deploy"}},
+			outputOnly: []string{"image"},
+		},
+		{
+			name: "with deps",
+			opts: kanvas.Options{Only: []string{"deploy"}, WithDeps: true},
+			plan: [][]string{{"git", "prereq"}, {"image"}, {"deploy"}},
+		},
+		{
+			name:       "with dependents",
+			opts:       kanvas.Options{Only: []string{"image"}, WithDependents: true},
+			plan:       [][]string{{"git", "prereq"}, {"image"}, {"deploy"}},
+			outputOnly: []string{"git", "prereq"},
+		},
+		{
+			name: "glob",
+			opts: kanvas.Options{Only: []string{"pre*", "git"}},
+			plan: [][]string{{"git", "prereq"}},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.TempDir = t.TempDir()
+
+			w, err := kanvas.NewWorkflow(newComponent(), tc.opts)
+			require.NoError(t, err)
+			require.Equal(t, tc.plan, w.Plan)
+
+			var outputOnly []string
+			for _, phase := range w.Plan {
+				for _, id := range phase {
+					if w.WorkflowJobs[id].OutputOnly {
+						outputOnly = append(outputOnly, id)
+					}
+				}
+			}
+			require.Equal(t, tc.outputOnly, outputOnly)
+		})
+	}
+
+	_, err := kanvas.NewWorkflow(newComponent(), kanvas.Options{TempDir: t.TempDir(), Only: []string{"nonexistent"}})
+	require.ErrorContains(t, err, `no component matches "nonexistent"`)
+}
@@ -0,0 +1,149 @@
+package kanvas
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/patternmatcher"
+)
+
+const (
+	// CommandTerraformPlanFingerprint is the name of the `kanvas tools` command
+	// that writes or verifies the fingerprint of a terraform plan file.
+	// It is run as a part of the terraform driver so that
+	// it works the same both locally and in the exported CI workflows.
+	CommandTerraformPlanFingerprint = "terraform-plan-fingerprint"
+	// FlagTerraformPlanFingerprintPlan is the flag to specify the path to the plan file
+	FlagTerraformPlanFingerprintPlan = "plan"
+	// FlagTerraformPlanFingerprintVerify is the flag to verify the fingerprint instead of writing it
+	FlagTerraformPlanFingerprintVerify = "verify"
+
+	terraformPlanFileExt        = ".tfplan"
+	terraformPlanFingerprintExt = ".fingerprint"
+
+	// computedOutput is the placeholder of the terraform output that is not known until the component is applied.
+	// It is the value of the output when diffing a component that has never been applied.
+	computedOutput = "<computed>"
+)
+
+// terraformPlanIgnores is the list of patterns for files that don't affect terraform plans.
+// Those files are excluded from the fingerprint,
+// so that e.g. terraform init and the plan file itself don't make the plan stale.
+var terraformPlanIgnores = []string{
+	".terraform",
+	".kanvas",
+	"**/*" + terraformPlanFileExt,
+	"**/*" + terraformPlanFileExt + terraformPlanFingerprintExt,
+	"**/*.tfstate",
+	"**/*.tfstate.backup",
+}
+
+// terraformPlanFile returns the path to the plan file of the job within planDir.
+func terraformPlanFile(planDir, id string) string {
+	name := strings.ReplaceAll(strings.TrimPrefix(id, "/"), "/", "-")
+	return filepath.Join(planDir, name+terraformPlanFileExt)
+}
+
+// terraformPlanFingerprint returns the fingerprint of the terraform project in dir
+// planned with args.
+// The fingerprint changes whenever the terraform config or the args including the variables change.
+func terraformPlanFingerprint(dir string, args []string) (string, error) {
+	pm, err := patternmatcher.New(terraformPlanIgnores)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+
+	if err := hashDir(h, dir, pm); err != nil {
+		return "", fmt.Errorf("hashing terraform project %q: %w", dir, err)
+	}
+
+	for _, a := range args {
+		fmt.Fprintf(h, "arg\x00%s\x00", a)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WriteTerraformPlanFingerprint writes the fingerprint of the terraform project in dir
+// next to the plan file, so that VerifyTerraformPlanFingerprint can tell if the plan is stale.
+// args is the list of args passed to terraform plan, excluding -out.
+func WriteTerraformPlanFingerprint(dir, plan string, args []string) error {
+	fp, err := terraformPlanFingerprint(dir, args)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(plan), 0755); err != nil {
+		return fmt.Errorf("creating the dir for terraform plan %q: %w", plan, err)
+	}
+
+	// The previous plan file is removed so that it is never applied
+	// with the new fingerprint when the new plan fails.
+	if err := os.Remove(plan); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing the previous terraform plan %q: %w", plan, err)
+	}
+
+	// A plan for the placeholder values would always be stale when applied with the actual values,
+	// so it is refused here rather than when applying.
+	if computed := terraformComputedArgs(args); len(computed) > 0 {
+		if err := os.Remove(plan + terraformPlanFingerprintExt); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing the fingerprint of the previous terraform plan %q: %w", plan, err)
+		}
+		return fmt.Errorf("terraform plan %q cannot be saved, because %s refer to the outputs of the components not applied yet. "+
+			"Apply the components first, or apply without the plan dir", plan, strings.Join(computed, ", "))
+	}
+
+	if err := os.WriteFile(plan+terraformPlanFingerprintExt, []byte(fp+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing the fingerprint of terraform plan %q: %w", plan, err)
+	}
+
+	return nil
+}
+
+// VerifyTerraformPlanFingerprint returns an error when the plan file is missing,
+// or it is stale because the terraform project in dir or args have changed since the plan was created.
+func VerifyTerraformPlanFingerprint(dir, plan string, args []string) error {
+	if _, err := os.Stat(plan); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("terraform plan %q does not exist: run diff with the same plan dir before applying", plan)
+	} else if err != nil {
+		return err
+	}
+
+	want, err := os.ReadFile(plan + terraformPlanFingerprintExt)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("terraform plan %q has no fingerprint: run diff with the same plan dir before applying", plan)
+	} else if err != nil {
+		return err
+	}
+
+	got, err := terraformPlanFingerprint(dir, args)
+	if err != nil {
+		return err
+	}
+
+	if string(bytes.TrimSpace(want)) != got {
+		return fmt.Errorf("terraform plan %q is stale: the terraform config or variables have changed since the plan was created. Rerun diff to create a new plan", plan)
+	}
+
+	return nil
+}
+
+// terraformComputedArgs returns the args that contain the placeholders of the outputs not known yet,
+// like `-var vpc_id=<computed>`.
+func terraformComputedArgs(args []string) []string {
+	var computed []string
+	for _, a := range args {
+		if strings.Contains(a, computedOutput) {
+			computed = append(computed, a)
+		}
+	}
+	return computed
+}
@@ -0,0 +1,62 @@
+package kanvas
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerraformPlanFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	plan := filepath.Join(dir, ".kanvas", "plans", "infra.tfplan")
+	args := []string{"-target", "null_resource.infra", "-var", "endpoint=a"}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`resource "null_resource" "infra" {}`), 0644))
+
+	require.EqualError(t,
+		VerifyTerraformPlanFingerprint(dir, plan, args),
+		`terraform plan "`+plan+`" does not exist: run diff with the same plan dir before applying`,
+	)
+
+	require.NoError(t, WriteTerraformPlanFingerprint(dir, plan, args))
+	require.NoError(t, os.WriteFile(plan, []byte("plan"), 0644))
+
+	// terraform init and the plan itself don't make the plan stale
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".terraform", "providers"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".terraform", "providers", "null"), []byte("bin"), 0644))
+	require.NoError(t, VerifyTerraformPlanFingerprint(dir, plan, args))
+
+	stale := `terraform plan "` + plan + `" is stale: the terraform config or variables have changed since the plan was created. Rerun diff to create a new plan`
+
+	require.EqualError(t,
+		VerifyTerraformPlanFingerprint(dir, plan, []string{"-target", "null_resource.infra", "-var", "endpoint=b"}),
+		stale,
+	)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`resource "null_resource" "infra2" {}`), 0644))
+	require.EqualError(t, VerifyTerraformPlanFingerprint(dir, plan, args), stale)
+
+	// Re-planning removes the previous plan so that it is never applied with the new fingerprint
+	require.NoError(t, WriteTerraformPlanFingerprint(dir, plan, args))
+	require.NoFileExists(t, plan)
+}
+
+func TestTerraformPlanFingerprint_Computed(t *testing.T) {
+	dir := t.TempDir()
+	plan := filepath.Join(dir, ".kanvas", "plans", "app.tfplan")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`resource "null_resource" "app" {}`), 0644))
+
+	require.NoError(t, WriteTerraformPlanFingerprint(dir, plan, []string{"-var", "endpoint=a"}))
+	require.FileExists(t, plan+terraformPlanFingerprintExt)
+
+	// The placeholders of the outputs of the components not applied yet never match the actual values,
+	// so the plan for them is refused instead of being applied as stale later.
+	require.EqualError(t,
+		WriteTerraformPlanFingerprint(dir, plan, []string{"-var", "endpoint=<computed>"}),
+		`terraform plan "`+plan+`" cannot be saved, because endpoint=<computed> refer to the outputs of the components not applied yet. Apply the components first, or apply without the plan dir`,
+	)
+	require.NoFileExists(t, plan+terraformPlanFingerprintExt)
+}
@@ -15,8 +15,13 @@ import (
 )
 
 type Config struct {
-	Env   string
-	Error string
+	Env          string
+	PlanDir      string
+	Format       string
+	Error        string
+	AffectedOnly bool
+	Teardown     bool
+	Validate     bool
 }
 
 type Option func(*Config)
@@ -27,6 +32,36 @@ func Error(err string) Option {
 	}
 }
 
+func Format(format string) Option {
+	return func(c *Config) {
+		c.Format = format
+	}
+}
+
+func Validate() Option {
+	return func(c *Config) {
+		c.Validate = true
+	}
+}
+
+func PlanDir(dir string) Option {
+	return func(c *Config) {
+		c.PlanDir = dir
+	}
+}
+
+func AffectedOnly() Option {
+	return func(c *Config) {
+		c.AffectedOnly = true
+	}
+}
+
+func Teardown() Option {
+	return func(c *Config) {
+		c.Teardown = true
+	}
+}
+
 func Env(env string) Option {
 	return func(c *Config) {
 		c.Env = env
@@ -41,6 +76,27 @@ func TestExport(t *testing.T) {
 	testExport(t, "reference")
 	testExport(t, "jsonnet")
 	testExport(t, "unusedenv", Env("dev"), Error(`environment "dev" uses "missing" but it is not defined`))
+	testExport(t, "envneeds", Env("production"))
+	testExport(t, "planfiles", PlanDir(".kanvas/plans"))
+	testExport(t, "reference", Format("gitlabci"))
+	testExport(t, "envneeds", Env("production"), Format("gitlabci"))
+	testExport(t, "planfiles", PlanDir(".kanvas/plans"), Format("gitlabci"), Error("the gitlabci format does not support passing artifacts like terraform plan files from diff to apply yet"))
+	testExport(t, "reference", Format("codebuild"))
+	testExport(t, "retry")
+	testExport(t, "envneeds", Env("production"), Format("codebuild"), Error(`the codebuild format does not support approval gates, but job "/production/infra" has approval gate "env:production"`))
+	testExport(t, "affected", AffectedOnly())
+	testExport(t, "affected", AffectedOnly(), Format("gitlabci"), Error("the gitlabci format does not support exporting the workflows for the affected components only yet"))
+	testExport(t, "teardown", Teardown())
+	testExport(t, "teardown", Teardown(), Format("codebuild"), Error("the codebuild format does not support exporting the teardown workflow yet"))
+	testExport(t, "tests")
+	testExport(t, "tests", Format("gitlabci"))
+	testExport(t, "argsfrom")
+	testExport(t, "argsfrom", Format("gitlabci"))
+	testExport(t, "argsfrom", Format("codebuild"))
+	testExport(t, "expressions")
+	testExport(t, "expressions", Format("gitlabci"), Error("job \"app\": command \"terraform\": 2 errors occurred:\n"+
+		"\t* after -var: expression \"infra.subnet_ids[0]\" cannot be expressed in gitlabci: indexing is not supported, because the outputs are passed as plain environment variables\n"+
+		"\t* after -var: expression \"json(infra._raw).vpc_id.value\" cannot be expressed in gitlabci: indexing is not supported, because the outputs are passed as plain environment variables\n\n"))
 }
 
 func TestRender(t *testing.T) {
@@ -54,6 +110,8 @@ func TestRender(t *testing.T) {
 	os.Setenv("GITHUB_REPOSITORY", "myowner/myrepo")
 
 	testRender(t, "jsonnet_env_github_repository")
+	testRender(t, "jsonnet_invalid_schema", Validate(), Error("the rendered config does not conform to the schema:\n"+
+		"\tcomponents.product1: unknown field \"appimage\""))
 }
 
 func testExport(t *testing.T, sub string, opts ...Option) {
@@ -77,6 +135,13 @@ func testExport(t *testing.T, sub string, opts ...Option) {
 		name = fmt.Sprintf("%s-%s", sub, env)
 	}
 
+	format := config.Format
+	if format == "" {
+		format = "githubactions"
+	} else {
+		name = fmt.Sprintf("%s-%s", name, format)
+	}
+
 	t.Run(name, func(t *testing.T) {
 		var (
 			exportsDir = filepath.Join(name, "exports")
@@ -101,12 +166,15 @@ func testExport(t *testing.T, sub string, opts ...Option) {
 		require.NoError(t, err)
 		require.NoError(t, os.Chdir(sub))
 		a, err := app.New(kanvas.Options{
-			Env: env,
+			Env:          env,
+			PlanDir:      config.PlanDir,
+			AffectedOnly: config.AffectedOnly,
+			Teardown:     config.Teardown,
 		})
 		require.NoError(t, os.Chdir(wd))
 		require.NoError(t, err)
 
-		gotErr := a.Export("githubactions", destDir, "kanvas:example")
+		gotErr := a.Export(format, destDir, "kanvas:example")
 		if wantErr != "" {
 			require.EqualError(t, gotErr, wantErr)
 		} else {
@@ -164,6 +232,13 @@ func testRender(t *testing.T, sub string, opts ...Option) {
 		name = fmt.Sprintf("%s-%s", sub, env)
 	}
 
+	format := config.Format
+	if format == "" {
+		format = "githubactions"
+	} else {
+		name = fmt.Sprintf("%s-%s", name, format)
+	}
+
 	t.Run(name, func(t *testing.T) {
 		var (
 			rendersDir = filepath.Join(name, "renders")
@@ -188,12 +263,17 @@ func testRender(t *testing.T, sub string, opts ...Option) {
 		require.NoError(t, err)
 		require.NoError(t, os.Chdir(sub))
 		a, err := app.New(kanvas.Options{
-			Env: env,
+			Env:          env,
+			PlanDir:      config.PlanDir,
+			AffectedOnly: config.AffectedOnly,
+			Teardown:     config.Teardown,
 		})
 		require.NoError(t, os.Chdir(wd))
 		require.NoError(t, err)
 
-		gotErr := a.Render(destDir)
+		gotErr := a.Render(destDir, func(c *app.RenderConfig) {
+			c.Validate = config.Validate
+		})
 		if wantErr != "" {
 			require.EqualError(t, gotErr, wantErr)
 		} else {
@@ -0,0 +1,7 @@
+output "vpc_id" {
+  value = "vpc-1"
+}
+
+output "subnet_ids" {
+  value = ["subnet-1", "subnet-2"]
+}
@@ -0,0 +1,186 @@
+package kanvas
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/davinci-std/kanvas/expr"
+	"github.com/mumoshu/kargo"
+)
+
+const (
+	ProberHTTP = "http"
+	ProberTCP  = "tcp"
+	ProberICMP = "icmp"
+	ProberDNS  = "dns"
+
+	defaultTestTimeout = 10 * time.Second
+)
+
+// Test is a synthetic test that probes the deployed application or infrastructure.
+// Each test is run after the components it needs are applied,
+// and the whole apply fails when any of the tests failed.
+type Test struct {
+	// Needs is a list of components that need to be applied before running the test.
+	// The component referenced by TargetFrom is implicitly added to this list.
+	Needs []string `yaml:"needs,omitempty"`
+	// Prober is the kind of the probe to run.
+	// The supported values are http, tcp, icmp, and dns.
+	Prober string `yaml:"prober"`
+	// Target is the target of the probe.
+	// It is a URL for http, a host:port for tcp, a host for icmp,
+	// and a name to resolve for dns.
+	Target string `yaml:"target,omitempty"`
+	// TargetFrom is the source of the target of the probe, in the form of component.output.
+	// It can be an expression like `format("https://{0}/healthz", app.host)`.
+	TargetFrom string `yaml:"targetFrom,omitempty"`
+	// Timeout is the timeout of the probe in the Go duration format like 5s.
+	// If empty, this defaults to 10s.
+	Timeout string `yaml:"timeout,omitempty"`
+	// HTTP contains the settings specific to the http prober
+	HTTP *HTTPProbe `yaml:"http,omitempty"`
+	// DNS contains the settings specific to the dns prober
+	DNS *DNSProbe `yaml:"dns,omitempty"`
+}
+
+// HTTPProbe contains the settings specific to the http prober
+type HTTPProbe struct {
+	// Method is the HTTP method to use.
+	// If empty, this defaults to GET.
+	Method string `yaml:"method,omitempty"`
+	// ValidStatusCodes is the list of status codes that are considered successful.
+	// If empty, this defaults to any 2xx status code.
+	ValidStatusCodes []int `yaml:"validStatusCodes,omitempty"`
+	// BodyRegexp is the regular expression that the response body must match.
+	BodyRegexp string `yaml:"bodyRegexp,omitempty"`
+}
+
+// DNSProbe contains the settings specific to the dns prober
+type DNSProbe struct {
+	// QueryType is the type of the DNS query.
+	// The supported values are A, AAAA, CNAME, MX, NS, and TXT.
+	// If empty, the name is resolved to any addresses.
+	QueryType string `yaml:"queryType,omitempty"`
+	// Server is the DNS server to query, in the form of host or host:port.
+	// If empty, the system resolver is used.
+	Server string `yaml:"server,omitempty"`
+}
+
+func (t Test) Validate() error {
+	switch t.Prober {
+	case ProberHTTP, ProberTCP, ProberICMP, ProberDNS:
+	case "":
+		return fmt.Errorf("prober must be set")
+	default:
+		return fmt.Errorf("unsupported prober %q: it must be one of %s, %s, %s, or %s", t.Prober, ProberHTTP, ProberTCP, ProberICMP, ProberDNS)
+	}
+
+	if t.Target == "" && t.TargetFrom == "" {
+		return fmt.Errorf("either target or targetFrom must be set")
+	}
+
+	if t.Target != "" && t.TargetFrom != "" {
+		return fmt.Errorf("target and targetFrom are mutually exclusive")
+	}
+
+	if t.TargetFrom != "" {
+		if _, err := expr.Parse(t.TargetFrom); err != nil {
+			return fmt.Errorf("targetFrom: %w", err)
+		}
+	}
+
+	if len(t.Needs) == 0 && t.TargetFrom == "" {
+		return fmt.Errorf("either needs or targetFrom must be set so that the test runs after the components are applied")
+	}
+
+	if _, err := t.timeout(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t Test) timeout() (time.Duration, error) {
+	if t.Timeout == "" {
+		return defaultTestTimeout, nil
+	}
+
+	d, err := time.ParseDuration(t.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q: %w", t.Timeout, err)
+	}
+
+	return d, nil
+}
+
+// IsTest returns true when the job runs the synthetic test instead of a component.
+// The tests are run in-process by kanvas, so they are not exported to CI-specific workflows.
+func (j *WorkflowJob) IsTest() bool {
+	_, ok := j.config.(Test)
+	return ok
+}
+
+// needs returns the list of components that the test depends on,
+// including the ones referenced by TargetFrom.
+func (t Test) needs() []string {
+	needs := append([]string{}, t.Needs...)
+
+	// The invalid expression is reported by Validate.
+	refs, _ := expr.ParseRefs(t.TargetFrom)
+
+	for _, r := range refs {
+		c := r.Component
+
+		var found bool
+		for _, n := range needs {
+			if n == c {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			needs = append(needs, c)
+		}
+	}
+
+	return needs
+}
+
+func newTestDriver(id string, t Test, opts Options) (*Driver, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	timeout, err := t.timeout()
+	if err != nil {
+		return nil, err
+	}
+
+	target := &kargo.Args{}
+	if t.TargetFrom != "" {
+		target = target.AppendValueFromOutput(t.TargetFrom)
+	} else {
+		target = target.Append(t.Target)
+	}
+
+	return &Driver{
+		Diff: nil,
+		Apply: []Task{
+			{
+				Args: target,
+				Exec: func(r *Runtime, args []string, o Outputs) error {
+					if len(args) != 1 {
+						return fmt.Errorf("BUG: expected exactly one target but got %v", args)
+					}
+
+					return probe(r.Context(), t, args[0], timeout, o)
+				},
+			},
+		},
+		Output: kanvasOutputCommandForID(id, opts),
+		OutputFunc: func(r *Runtime, op Op, o Outputs) error {
+			return nil
+		},
+	}, nil
+}
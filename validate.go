@@ -0,0 +1,788 @@
+package kanvas
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/davinci-std/kanvas/expr"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/hashicorp/hcl/v2"
+)
+
+// Problem is a problem in the config found by Validate.
+type Problem struct {
+	// File is the path to the config file.
+	File string `json:"file"`
+	// Line and Column are the position of the problem in the config file, starting from 1.
+	// They are zero when the problem cannot be attributed to any position,
+	// like the ones found only after loading the whole workflow,
+	// or when the config is a jsonnet file, whose positions in the rendered JSON would be misleading.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	// Envs is the list of the environments the problem is found in.
+	// It is empty when the problem is not specific to any environment, like an unknown field.
+	Envs []string `json:"envs,omitempty"`
+	// Message describes the problem.
+	Message string `json:"message"`
+}
+
+// String returns the problem in the form of `file:line:column: message (environments: env1, env2)`.
+func (p Problem) String() string {
+	var b strings.Builder
+
+	b.WriteString(p.File)
+	if p.Line > 0 {
+		fmt.Fprintf(&b, ":%d:%d", p.Line, p.Column)
+	}
+	fmt.Fprintf(&b, ": %s", p.Message)
+	if len(p.Envs) > 0 {
+		fmt.Fprintf(&b, " (environments: %s)", strings.Join(p.Envs, ", "))
+	}
+
+	return b.String()
+}
+
+// Validate statically checks the config at path, whose content is file,
+// and returns all the problems found in it, without running any command.
+//
+// The config is loaded for every environment, so that the problems in any environment are found at once.
+// The checks include unknown fields, components without drivers, needs and expressions referring to
+// missing components or outputs, invalid externals, missing dirs, and components whose IDs collide.
+//
+// It returns an error only when the config cannot be parsed at all.
+func Validate(path string, file []byte, opts Options) ([]Problem, error) {
+	f, err := parser.ParseBytes(file, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := LoadConfig(path, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.TempDir == "" {
+		tempDir, err := os.MkdirTemp("", "kanvas_validate_*")
+		if err != nil {
+			return nil, fmt.Errorf("unable to create temp dir: %w", err)
+		}
+		defer os.RemoveAll(tempDir)
+		opts.TempDir = tempDir
+	}
+
+	v := &validator{
+		file:      path,
+		dir:       config.Dir,
+		opts:      opts,
+		seen:      map[string]int{},
+		positions: filepath.Ext(path) != ".jsonnet",
+	}
+	for _, d := range f.Docs {
+		if d.Body != nil {
+			v.root = d.Body
+			break
+		}
+	}
+
+	v.checkFields(v.root, reflect.TypeOf(Component{}), "")
+
+	envs := []string{""}
+	if len(config.Environments) > 0 {
+		envs = envs[:0]
+		for name := range config.Environments {
+			envs = append(envs, name)
+		}
+		sort.Strings(envs)
+	}
+
+	for _, env := range envs {
+		v.checkEnvironment(*config, env)
+	}
+
+	// The problems without positions are sorted last,
+	// because they are usually the consequences of the ones with positions.
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		a, b := v.problems[i], v.problems[j]
+		if (a.Line == 0) != (b.Line == 0) {
+			return b.Line == 0
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	return v.problems, nil
+}
+
+// validator accumulates the problems found in a config.
+type validator struct {
+	file string
+	// dir is the base dir of the config, which the absolute dirs of the components are relative to
+	dir  string
+	opts Options
+	// root is the root node of the YAML document of the config
+	root ast.Node
+
+	problems []Problem
+	// seen maps the position and the message of each problem to its index in problems,
+	// so that the same problem found in multiple environments is reported once.
+	seen map[string]int
+	// count is the number of problems added so far, including the duplicates
+	count int
+	// positions is true when the positions in the parsed YAML are the ones in the config file
+	positions bool
+}
+
+// add adds the problem found in the environment at the position of the node.
+// The node can be nil when the position is unknown.
+func (v *validator) add(env string, n ast.Node, format string, args ...interface{}) {
+	v.count++
+
+	p := Problem{
+		File:    v.file,
+		Message: fmt.Sprintf(format, args...),
+	}
+	if n != nil && v.positions {
+		if tk := n.GetToken(); tk != nil && tk.Position != nil {
+			p.Line, p.Column = tk.Position.Line, tk.Position.Column
+		}
+	}
+
+	key := fmt.Sprintf("%d:%d:%s", p.Line, p.Column, p.Message)
+	if i, ok := v.seen[key]; ok {
+		envs := v.problems[i].Envs
+		if env != "" && (len(envs) == 0 || envs[len(envs)-1] != env) {
+			v.problems[i].Envs = append(envs, env)
+		}
+		return
+	}
+
+	if env != "" {
+		p.Envs = []string{env}
+	}
+	v.seen[key] = len(v.problems)
+	v.problems = append(v.problems, p)
+}
+
+// checkFields reports the fields in the node that are unknown to the type t,
+// which are otherwise silently ignored when the config is loaded.
+// path is the path to the node like components.app, used in the messages.
+func (v *validator) checkFields(n ast.Node, t reflect.Type, path string) {
+	n = unwrapNode(n)
+	if n == nil {
+		return
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := yamlFields(t)
+		for _, mv := range mappingValues(n) {
+			k := keyString(mv.Key)
+			if _, ok := mv.Key.(*ast.MergeKeyNode); ok {
+				continue
+			}
+
+			ft, ok := fields[k]
+			if !ok {
+				if path == "" {
+					v.add("", mv.Key, "unknown field %q", k)
+				} else {
+					v.add("", mv.Key, "unknown field %q in %s", k, path)
+				}
+				continue
+			}
+
+			v.checkFields(mv.Value, ft, joinPath(path, k))
+		}
+	case reflect.Map:
+		for _, mv := range mappingValues(n) {
+			v.checkFields(mv.Value, t.Elem(), joinPath(path, keyString(mv.Key)))
+		}
+	case reflect.Slice, reflect.Array:
+		if s, ok := n.(*ast.SequenceNode); ok {
+			for i, e := range s.Values {
+				v.checkFields(e, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+}
+
+// yamlFields returns the types of the fields of the struct type t, keyed by their names in YAML.
+// The fields of the inlined structs are included as if they were the fields of t.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		tag := f.Tag.Get("yaml")
+		if tag == "" {
+			tag = f.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if strings.Contains(opts, "inline") {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			for k, v := range yamlFields(ft) {
+				fields[k] = v
+			}
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// validatedJob is a job of the workflow as seen by the validator.
+type validatedJob struct {
+	id   string
+	name string
+	// path is the ID of the parent of the job, against which the needs are resolved
+	path string
+	// sources is the list of the candidate paths to the definition of the job in the YAML,
+	// in the order of precedence.
+	sources [][]interface{}
+	needs   []string
+	// exprs is the list of the expressions referring to the outputs of other jobs
+	exprs []string
+	// outputs is the set of the outputs the job produces.
+	// It is nil when the outputs cannot be known statically.
+	outputs map[string]struct{}
+}
+
+// checkEnvironment reports the problems of the components and the tests in the environment.
+func (v *validator) checkEnvironment(config Component, env string) {
+	count := v.count
+
+	wf := &Workflow{Options: v.opts, Dir: v.dir}
+
+	components, err := wf.loadEnvironment(config, env)
+	if err != nil {
+		v.add(env, v.lookup("environments", env), "%v", err)
+		return
+	}
+
+	jobs := map[string]*validatedJob{
+		ID("", gitJob): {
+			id:      ID("", gitJob),
+			name:    gitJob,
+			outputs: outputSet("sha", "tag"),
+		},
+	}
+
+	v.checkComponents(env, jobs, "", config.Dir, components, func(name string) [][]interface{} {
+		var sources [][]interface{}
+		if env != "" {
+			sources = append(sources,
+				[]interface{}{"environments", env, "overrides", name},
+				[]interface{}{"environments", env, "uses", name},
+			)
+		}
+		sources = append(sources, []interface{}{"components", name})
+		if env != "" {
+			sources = append(sources, []interface{}{"environments", env, "defaults"})
+		}
+		return sources
+	})
+	v.checkTests(env, jobs, "", config.Tests, [][]interface{}{{}})
+
+	ids := make([]string, 0, len(jobs))
+	for id := range jobs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		v.checkRefs(env, jobs, jobs[id])
+	}
+
+	// The whole workflow is loaded only when the static checks passed,
+	// to find the remaining problems like dependency cycles,
+	// without repeating the ones already reported.
+	if v.count > count {
+		return
+	}
+
+	opts := v.opts
+	opts.Env = env
+	if _, err := NewWorkflow(config, opts); err != nil {
+		v.add(env, nil, "%v", err)
+	}
+}
+
+// checkComponents reports the problems of the components under the path,
+// and adds them to jobs for checking the references among them later.
+// sources returns the candidate paths to the definition of the named component in the YAML.
+func (v *validator) checkComponents(env string, jobs map[string]*validatedJob, path, baseDir string, components map[string]Component, sources func(string) [][]interface{}) {
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		c := components[name]
+		id := ID(path, name)
+		srcs := sources(name)
+
+		if other, ok := jobs[id]; ok {
+			v.add(env, v.find(srcs), "component %q has the same ID %q as %s", name, id, other.describe())
+			continue
+		}
+
+		if err := checkComponentName(name); err != nil {
+			v.add(env, v.find(srcs), "component %q: %v", id, err)
+		}
+
+		dir := c.Dir
+		if dir == "" {
+			dir = baseDir
+		} else {
+			if dir[0] == '/' {
+				dir = filepath.Join(v.dir, dir)
+			} else {
+				dir = filepath.Join(baseDir, dir)
+			}
+
+			if _, err := os.Stat(dir); err != nil {
+				v.add(env, v.find(srcs, "dir"), "component %q: dir %q does not exist", id, c.Dir)
+			}
+		}
+
+		j := &validatedJob{
+			id:      id,
+			name:    name,
+			path:    path,
+			sources: srcs,
+			needs:   c.Needs,
+		}
+		jobs[id] = j
+
+		if c.Externals != nil {
+			v.checkExternals(env, id, srcs, c.Externals)
+		}
+
+		if err := c.Validate(); err != nil {
+			if len(c.Components) == 0 {
+				v.add(env, v.find(srcs), "component %q: %v", id, err)
+			} else {
+				// The component only groups the sub-components
+				j.outputs = outputSet()
+			}
+		} else if d, err := newDriver(id, dir, c, v.opts); err != nil {
+			v.add(env, v.find(srcs), "component %q: %v", id, err)
+		} else if exprs, err := d.exprs(); err != nil {
+			v.add(env, v.find(srcs), "component %q: %v", id, err)
+		} else {
+			j.exprs = exprs
+			j.outputs = knownOutputs(dir, c)
+		}
+
+		v.checkComponents(env, jobs, id, dir, c.Components, func(name string) [][]interface{} {
+			var sub [][]interface{}
+			for _, s := range srcs {
+				sub = append(sub, append(append([]interface{}{}, s...), "components", name))
+			}
+			return sub
+		})
+
+		v.checkTests(env, jobs, id, c.Tests, srcs)
+	}
+}
+
+// describe returns the description of the job for the messages.
+func (j *validatedJob) describe() string {
+	if j.id == ID("", gitJob) && j.sources == nil {
+		return "the builtin git job"
+	}
+	return fmt.Sprintf("%q", j.name)
+}
+
+// checkExternals reports the outputs of the externals whose sources are missing or invalid.
+func (v *validator) checkExternals(env, id string, srcs [][]interface{}, e *Externals) {
+	names := make([]string, 0, len(e.Outputs))
+	for name := range e.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		o := e.Outputs[name]
+		pos := v.find(srcs, "externals", "outputs", name)
+
+		var sources int
+		for _, s := range []struct {
+			field string
+			set   bool
+			check func() error
+		}{
+			{"awsParam", o.AWSParam != nil, func() error { return o.AWSParam.Validate() }},
+			{"awsSecret", o.AWSSecret != nil, func() error { return o.AWSSecret.Validate() }},
+			{"googleSheetCell", o.GoogleSheetCell != nil, func() error { return o.GoogleSheetCell.Validate() }},
+			{"terraformState", o.TerraformState != nil, func() error { return o.TerraformState.Validate() }},
+		} {
+			if !s.set {
+				continue
+			}
+			sources++
+			if err := s.check(); err != nil {
+				v.add(env, pos, "component %q: external output %q: %s: %v", id, name, s.field, err)
+			}
+		}
+
+		if sources != 1 {
+			v.add(env, pos, "component %q: external output %q must have exactly one of awsParam, awsSecret, googleSheetCell, or terraformState", id, name)
+		}
+	}
+}
+
+// checkTests reports the problems of the tests under the path,
+// and adds them to jobs for checking their references later.
+func (v *validator) checkTests(env string, jobs map[string]*validatedJob, path string, tests map[string]Test, srcs [][]interface{}) {
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t := tests[name]
+		id := ID(path, name)
+
+		var tsrcs [][]interface{}
+		for _, s := range srcs {
+			tsrcs = append(tsrcs, append(append([]interface{}{}, s...), "tests", name))
+		}
+
+		if other, ok := jobs[id]; ok {
+			v.add(env, v.find(tsrcs), "test %q has the same ID %q as %s", name, id, other.describe())
+			continue
+		}
+
+		if err := t.Validate(); err != nil {
+			v.add(env, v.find(tsrcs), "test %q: %v", id, err)
+		}
+
+		j := &validatedJob{
+			id:      id,
+			name:    name,
+			path:    path,
+			sources: tsrcs,
+			needs:   t.Needs,
+			outputs: outputSet("prober", "target", "duration", "result", "statusCode", "rtt", "answers"),
+		}
+		if t.TargetFrom != "" {
+			j.exprs = []string{t.TargetFrom}
+		}
+		jobs[id] = j
+	}
+}
+
+// checkRefs reports the needs and the expressions of the job that refer to missing components or outputs.
+func (v *validator) checkRefs(env string, jobs map[string]*validatedJob, j *validatedJob) {
+	for i, n := range j.needs {
+		if n == gitJob {
+			continue
+		}
+		if _, ok := jobs[ID(j.path, n)]; !ok {
+			v.add(env, v.find(j.sources, "needs", i), "component %q needs %q, which does not exist", j.id, n)
+		}
+	}
+
+	for _, e := range j.exprs {
+		pos := v.findString(j.sources, e)
+
+		n, err := expr.Parse(e)
+		if err != nil {
+			v.add(env, pos, "component %q: %v", j.id, err)
+			continue
+		}
+
+		v.checkExpr(env, jobs, j, pos, e, n, false)
+	}
+}
+
+// checkExpr reports the references in the expression e to missing components or outputs.
+// The outputs referenced with fallbacks via `??` are optional, so that only their components are checked.
+func (v *validator) checkExpr(env string, jobs map[string]*validatedJob, j *validatedJob, pos ast.Node, e string, n expr.Node, optional bool) {
+	switch n := n.(type) {
+	case expr.Ref:
+		target := SiblingID(j.id, n.Component)
+		if target == j.id {
+			return
+		}
+
+		t, ok := jobs[target]
+		if !ok {
+			v.add(env, pos, "component %q: %q refers to the outputs of %q, which does not exist", j.id, e, n.Component)
+			return
+		}
+
+		if optional || t.outputs == nil || strings.HasPrefix(n.Output, bookkeepingOutputPrefix) {
+			return
+		}
+
+		if _, ok := t.outputs[n.Output]; !ok {
+			known := make([]string, 0, len(t.outputs))
+			for o := range t.outputs {
+				known = append(known, o)
+			}
+			sort.Strings(known)
+
+			if len(known) == 0 {
+				v.add(env, pos, "component %q: %q refers to the output %q of %q, which does not produce any output", j.id, e, n.Output, n.Component)
+			} else {
+				v.add(env, pos, "component %q: %q refers to the output %q of %q, which produces only %s", j.id, e, n.Output, n.Component, strings.Join(known, ", "))
+			}
+		}
+	case expr.Index:
+		v.checkExpr(env, jobs, j, pos, e, n.X, optional)
+		v.checkExpr(env, jobs, j, pos, e, n.Index, optional)
+	case expr.Field:
+		v.checkExpr(env, jobs, j, pos, e, n.X, optional)
+	case expr.Call:
+		for _, a := range n.Args {
+			v.checkExpr(env, jobs, j, pos, e, a, optional)
+		}
+	case expr.Coalesce:
+		v.checkExpr(env, jobs, j, pos, e, n.X, true)
+		v.checkExpr(env, jobs, j, pos, e, n.Default, optional)
+	}
+}
+
+// knownOutputs returns the set of the outputs the component produces,
+// or nil when they cannot be known statically.
+func knownOutputs(dir string, c Component) map[string]struct{} {
+	switch {
+	case c.AWS != nil:
+		return outputSet()
+	case c.Docker != nil:
+		return outputSet("tag", "ref", "id")
+	case c.Terraform != nil:
+		names, err := terraformOutputNames(dir)
+		if err != nil || names == nil {
+			return nil
+		}
+		return outputSet(append(names, "_raw")...)
+	case c.Kubernetes != nil:
+		return outputSet("pullRequest.id", "pullRequest.nodeID", "pullRequest.number", "pullRequest.head", "pullRequest.htmlURL")
+	case c.Externals != nil:
+		var names []string
+		for name := range c.Externals.Outputs {
+			names = append(names, name)
+		}
+		return outputSet(names...)
+	}
+
+	return outputSet()
+}
+
+func outputSet(names ...string) map[string]struct{} {
+	s := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		s[n] = struct{}{}
+	}
+	return s
+}
+
+// terraformOutputNames returns the names of the outputs declared in the terraform files in dir.
+// It returns nil when there are no terraform files in dir.
+func terraformOutputNames(dir string) ([]string, error) {
+	type Output struct {
+		Name    string   `hcl:"name,label"`
+		Options hcl.Body `hcl:",remain"`
+	}
+
+	type Config struct {
+		Outputs []Output `hcl:"output,block"`
+		Options hcl.Body `hcl:",remain"`
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to glob %s/*.tf: %w", dir, err)
+	}
+
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	names := []string{}
+	for _, f := range files {
+		var config Config
+		if err := decodeHCLFile(f, nil, &config); err != nil {
+			return nil, fmt.Errorf("failed to load configuration %s: %v", f, err)
+		}
+		for _, out := range config.Outputs {
+			names = append(names, out.Name)
+		}
+	}
+
+	return names, nil
+}
+
+// find returns the node at the keys under the first of the sources that has it.
+// When none of the sources has it, the node of the first source that exists is returned,
+// so that the problem is reported at least at the component.
+func (v *validator) find(sources [][]interface{}, keys ...interface{}) ast.Node {
+	for _, s := range sources {
+		if n := v.lookup(append(append([]interface{}{}, s...), keys...)...); n != nil {
+			return n
+		}
+	}
+
+	if len(keys) > 0 {
+		return v.find(sources)
+	}
+
+	return nil
+}
+
+// findString returns the node of the string s within the first of the sources that has it.
+func (v *validator) findString(sources [][]interface{}, s string) ast.Node {
+	for _, src := range sources {
+		_, n := lookupNode(v.root, src)
+		if n == nil {
+			continue
+		}
+		if found := findScalar(n, s); found != nil {
+			return found
+		}
+	}
+
+	return v.find(sources)
+}
+
+// lookup returns the node at the path of map keys and sequence indexes in the YAML,
+// or nil when there is no such node.
+// For a map key, the node of the key is returned so that the position points to the start of the entry.
+func (v *validator) lookup(path ...interface{}) ast.Node {
+	pos, _ := lookupNode(v.root, path)
+	return pos
+}
+
+// lookupNode returns the node to report the position of and the value node at the path.
+func lookupNode(n ast.Node, path []interface{}) (ast.Node, ast.Node) {
+	pos := n
+	for _, p := range path {
+		n = unwrapNode(n)
+		if n == nil {
+			return nil, nil
+		}
+
+		switch k := p.(type) {
+		case string:
+			var found *ast.MappingValueNode
+			for _, mv := range mappingValues(n) {
+				if keyString(mv.Key) == k {
+					found = mv
+					break
+				}
+			}
+			if found == nil {
+				return nil, nil
+			}
+			pos, n = found.Key, found.Value
+		case int:
+			s, ok := n.(*ast.SequenceNode)
+			if !ok || k >= len(s.Values) {
+				return nil, nil
+			}
+			n = s.Values[k]
+			pos = n
+		}
+	}
+
+	return pos, unwrapNode(n)
+}
+
+// findScalar returns the first scalar node under n whose value is s.
+func findScalar(n ast.Node, s string) ast.Node {
+	n = unwrapNode(n)
+	switch t := n.(type) {
+	case nil:
+		return nil
+	case *ast.MappingNode, *ast.MappingValueNode:
+		for _, mv := range mappingValues(t) {
+			if found := findScalar(mv.Value, s); found != nil {
+				return found
+			}
+		}
+	case *ast.SequenceNode:
+		for _, e := range t.Values {
+			if found := findScalar(e, s); found != nil {
+				return found
+			}
+		}
+	case ast.ScalarNode:
+		if fmt.Sprint(t.GetValue()) == s {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// unwrapNode returns the node annotated by the anchor or the tag, if any.
+func unwrapNode(n ast.Node) ast.Node {
+	for {
+		switch t := n.(type) {
+		case *ast.AnchorNode:
+			n = t.Value
+		case *ast.TagNode:
+			n = t.Value
+		default:
+			return n
+		}
+	}
+}
+
+// mappingValues returns the entries of the mapping node.
+// A mapping with a single entry can be parsed as a bare MappingValueNode.
+func mappingValues(n ast.Node) []*ast.MappingValueNode {
+	switch t := n.(type) {
+	case *ast.MappingNode:
+		return t.Values
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{t}
+	}
+	return nil
+}
+
+func keyString(k ast.MapKeyNode) string {
+	if s, ok := k.(ast.ScalarNode); ok {
+		return fmt.Sprint(s.GetValue())
+	}
+	return k.String()
+}
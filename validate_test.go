@@ -0,0 +1,65 @@
+package kanvas_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davinci-std/kanvas"
+	"github.com/stretchr/testify/require"
+)
+
+func validate(t *testing.T, path string) []string {
+	t.Helper()
+
+	file, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	problems, err := kanvas.Validate(path, file, kanvas.Options{TempDir: t.TempDir()})
+	require.NoError(t, err)
+
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	return got
+}
+
+func TestValidate(t *testing.T) {
+	path := filepath.Join("testdata", "validate", "kanvas.yaml")
+
+	require.Equal(t, []string{
+		path + `:6:5: unknown field "workspace" in components.infra`,
+		path + `:10:7: component "app" needs "db", which does not exist (environments: production, staging)`,
+		path + `:17:17: component "app": "infra.domain" refers to the output "domain" of "infra", which produces only _raw, subnet_ids, vpc_id (environments: production, staging)`,
+		path + `:20:3: component "cache" has the same ID "cache" as "Cache" (environments: production, staging)`,
+		path + `:25:9: component "secrets": external output "token": awsSecret: either arn or id must be set (environments: production, staging)`,
+		path + `:28:9: component "secrets": external output "password" must have exactly one of awsParam, awsSecret, googleSheetCell, or terraformState (environments: production, staging)`,
+		path + `:29:3: component "orphan": component does not have any of docker, terraform, kubernetes, aws, externals, githubFiles, or noop fields (environments: production, staging)`,
+		path + `:30:5: component "orphan": dir "missing" does not exist (environments: production, staging)`,
+		path + `:38:24: component "app": "queue.url" refers to the outputs of "queue", which does not exist (environments: production)`,
+	}, validate(t, path))
+}
+
+func TestValidate_Cycle(t *testing.T) {
+	path := filepath.Join("testdata", "validate", "cycle.yaml")
+
+	// The problems found only after loading the whole workflow have no positions
+	require.Equal(t, []string{
+		path + ": the graph contains a cycle",
+	}, validate(t, path))
+}
+
+func TestValidate_EnvComponent(t *testing.T) {
+	path := filepath.Join("testdata", "validate", "envname.yaml")
+
+	require.Equal(t, []string{
+		path + `:2:3: component "env": "env" cannot be used as a component name, because env.NAME refers to the environment variable NAME in expressions`,
+	}, validate(t, path))
+}
+
+func TestValidate_Valid(t *testing.T) {
+	path := filepath.Join("testdata", "workflow", "kanvas.yaml")
+
+	require.Empty(t, validate(t, path))
+}
@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"dario.cat/mergo"
+
+	"github.com/davinci-std/kanvas/expr"
 )
 
 type Workflow struct {
@@ -14,8 +18,20 @@ type Workflow struct {
 	WorkflowJobs map[string]*WorkflowJob
 	Dir          string
 	Options      Options
+	// Warnings is the list of the problems found while loading the config,
+	// which are not fatal unless Options.Strict is set, like the missing needs.
+	Warnings []string
 
 	deps map[string][]string
+	// tests is the list of the tests found while loading the components of an environment.
+	// They are loaded after all the components, so that a test conflicting with any component is detected.
+	tests []testSet
+}
+
+// testSet is the set of tests defined in a component, or at the top-level of the config.
+type testSet struct {
+	path, baseDir string
+	tests         map[string]Test
 }
 
 type WorkflowJob struct {
@@ -23,10 +39,32 @@ type WorkflowJob struct {
 	// This isn't a bool because we want to force the user to specify
 	// the outputs for the skipped job,
 	// otherwise the subsequent job will fail because it can't find the outputs.
-	Skipped map[string]string
+	Skipped Outputs
 	Dir     string
 	Needs   []string
 	Driver  *Driver
+	// Approvals is the list of approval gates that need to be approved before applying the job
+	Approvals []ApprovalGate
+	// Timeout is the maximum duration of diffing or applying the job.
+	// Zero means no timeout.
+	Timeout time.Duration
+	// Retry is the retry policy of the job.
+	// Nil means the job is never retried.
+	Retry *RetryPolicy
+	// OutputOnly is true when the job is not selected via Options.Only,
+	// but needed by a selected job.
+	// The job is neither diffed nor applied, and only its outputs are read via Driver.OutputFunc.
+	OutputOnly bool
+	// AlwaysRun is true when the job is applied every time,
+	// even when its input fingerprint matches the last successful apply.
+	AlwaysRun bool
+	// Watch is the list of extra paths whose changes affect the job, in addition to the dir.
+	// See Component.Watch.
+	Watch []string
+
+	// config is the resolved config of the job, like the Component or the Test.
+	// It is used to compute the fingerprint of the job.
+	config interface{}
 }
 
 func NewWorkflow(config Component, opts Options) (*Workflow, error) {
@@ -45,7 +83,106 @@ func NewWorkflow(config Component, opts Options) (*Workflow, error) {
 }
 
 func (wf *Workflow) Load(path, baseDir string, config Component) error {
-	components, err := wf.loadEnvironment(config)
+	envs, err := wf.environments(config)
+	if err != nil {
+		return err
+	}
+
+	envJobs := map[string][]string{}
+	for _, env := range envs {
+		before := make(map[string]struct{}, len(wf.WorkflowJobs))
+		for id := range wf.WorkflowJobs {
+			before[id] = struct{}{}
+		}
+
+		if len(envs) == 1 {
+			if err := wf.loadEnvironmentJobs(path, baseDir, config, env); err != nil {
+				return err
+			}
+		} else {
+			// Each environment is loaded under its own namespace like /preview/app and /production/app,
+			// so that the same component can be applied once per environment within a single run.
+			if err := wf.loadEnvironmentJobs(ID(path, env), baseDir, config, env); err != nil {
+				return fmt.Errorf("environment %q: %w", env, err)
+			}
+		}
+
+		for id, j := range wf.WorkflowJobs {
+			if _, ok := before[id]; ok {
+				continue
+			}
+
+			// Grouping components are never run, so that
+			// they neither gate nor chain the environments.
+			if j.isGroup() {
+				continue
+			}
+
+			envJobs[env] = append(envJobs[env], id)
+		}
+	}
+
+	wf.gateEnvironments(config, envJobs)
+	wf.chainEnvironments(config, envJobs)
+
+	sort.Strings(wf.Warnings)
+
+	plan, err := topologicalSort(wf.deps)
+	if err != nil {
+		return err
+	}
+
+	if len(plan) == 0 {
+		return fmt.Errorf("BUG: Unable to produce a valid plan even though there was no error")
+	}
+
+	wf.Plan = wf.removeGroups(plan)
+	if err := wf.selectJobs(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// removeGroups removes the grouping components from the plan.
+// They are for logically grouping sub-components so
+// not needed to be executed.
+//
+// For the test/e2e/testdata/kanvas.yaml example, the plan looks like the below:
+//
+// Before: [["/product1/appimage","/product1"],["/product1/base"],["/product1/argocd"],["/product1/argocd_resources"]]
+// After : [["/product1/appimage"],["/product1/base"],["/product1/argocd"],["/product1/argocd_resources"]]
+//
+// A grouping component needed by another job is kept, so that the job never needs a job missing in the plan.
+func (wf *Workflow) removeGroups(plan [][]string) [][]string {
+	needed := map[string]struct{}{}
+	for _, needs := range wf.deps {
+		for _, n := range needs {
+			needed[n] = struct{}{}
+		}
+	}
+
+	var result [][]string
+	for _, phase := range plan {
+		var ids []string
+		for _, id := range phase {
+			if _, ok := needed[id]; !ok && wf.WorkflowJobs[id].isGroup() {
+				continue
+			}
+			ids = append(ids, id)
+		}
+
+		if len(ids) > 0 {
+			result = append(result, ids)
+		}
+	}
+
+	return result
+}
+
+// loadEnvironmentJobs loads the components and the tests for the environment under the path.
+func (wf *Workflow) loadEnvironmentJobs(path, baseDir string, config Component, envName string) error {
+	components, err := wf.loadEnvironment(config, envName)
 	if err != nil {
 		return err
 	}
@@ -54,58 +191,156 @@ func (wf *Workflow) Load(path, baseDir string, config Component) error {
 		return fmt.Errorf("no components found")
 	}
 
+	wf.addGitJob(ID(path, gitJob), baseDir)
+
+	wf.tests = nil
+
 	if err := wf.load(path, baseDir, components); err != nil {
 		return fmt.Errorf("loading %q %q: %w", path, baseDir, err)
 	}
 
-	plan, err := topologicalSort(wf.deps)
+	wf.tests = append(wf.tests, testSet{path: path, baseDir: baseDir, tests: config.Tests})
+
+	for _, s := range wf.tests {
+		if err := wf.loadTests(s.path, s.baseDir, s.tests); err != nil {
+			return fmt.Errorf("loading tests %q %q: %w", s.path, s.baseDir, err)
+		}
+	}
+	wf.tests = nil
+
+	return nil
+}
+
+// environments returns the names of the environments to be loaded, in the order of dependencies.
+//
+// It returns only the environment specified via the options when it does not need any other environment.
+// Otherwise, the returned list contains all the environments needed by the environment transitively,
+// followed by the environment itself.
+func (wf *Workflow) environments(config Component) ([]string, error) {
+	envName := wf.Options.Env
+
+	env, ok := config.Environments[envName]
+	if !ok || len(env.needs()) == 0 {
+		return []string{envName}, nil
+	}
+
+	deps := map[string][]string{}
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		if _, ok := deps[name]; ok {
+			return nil
+		}
+
+		needs := config.Environments[name].needs()
+		deps[name] = needs
+
+		for _, n := range needs {
+			if _, ok := config.Environments[n]; !ok {
+				return fmt.Errorf("environment %q needs %q but it is not defined", name, n)
+			}
+
+			if err := visit(n); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := visit(envName); err != nil {
+		return nil, err
+	}
+
+	levels, err := topologicalSort(deps)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("environment %q: %w", envName, err)
 	}
 
-	if len(components) > 0 && len(plan) == 0 {
-		return fmt.Errorf("BUG: Unable to produce a valid plan even though there was no error")
+	var envs []string
+	for _, l := range levels {
+		envs = append(envs, l...)
 	}
 
-	// Remove top-level components from the plan.
-	// They are for logically grouping sub-components so
-	// not needed to be executed.
-	var midLevels []string
-	for _, level := range plan[0] {
-		if strings.Count(level, "/") < 2 {
+	return envs, nil
+}
+
+// chainEnvironments makes the root jobs of each environment need the leaf jobs of the environments it needs,
+// so that an environment is applied only after all the environments it needs are applied.
+func (wf *Workflow) chainEnvironments(config Component, envJobs map[string][]string) {
+	for env, jobs := range envJobs {
+		var upstreamLeaves []string
+		for _, n := range config.Environments[env].needs() {
+			upstreamLeaves = append(upstreamLeaves, wf.leaves(envJobs[n])...)
+		}
+		sort.Strings(upstreamLeaves)
+
+		if len(upstreamLeaves) == 0 {
 			continue
 		}
-		midLevels = append(midLevels, level)
+
+		for _, id := range jobs {
+			j := wf.WorkflowJobs[id]
+			if _, ok := wf.deps[id]; !ok || len(j.Needs) > 0 || j.Skipped != nil {
+				continue
+			}
+
+			j.Needs = append([]string{}, upstreamLeaves...)
+			wf.deps[id] = j.Needs
+		}
 	}
+}
+
+// gateEnvironments adds the approval gate of each environment to the root jobs of the environment,
+// so that the whole environment is applied only after the gate is approved.
+func (wf *Workflow) gateEnvironments(config Component, envJobs map[string][]string) {
+	for env, jobs := range envJobs {
+		e, ok := config.Environments[env]
+		if !ok || e.Approval == nil {
+			continue
+		}
 
-	// Replace the top-level components with the mid-level ones, if any.
-	// The mid-level components are the ones that actually contain
-	// "run" fields to be executed.
-	// Top-level components are just for grouping hence they don't
-	// contain "run" fields.
-	//
-	// For the test/e2e/testdata/kanvas.yaml example, the plan looks like the below:
-	//
-	// Before: plan = [][]string len: 4, cap: 4, [["/product1/appimage","product1"],["/product1/base"],["/product1/argocd"],["/product1/argocd_resources"]]
-	// After : plan = [][]string len: 4, cap: 4, [["/product1/appimage"],["/product1/base"],["/product1/argocd"],["/product1/argocd_resources"]]
-	//
-	// Notice that the top-level component "product1" is removed.
-	if len(midLevels) > 0 {
-		plan[0] = midLevels
+		for _, id := range jobs {
+			j := wf.WorkflowJobs[id]
+			if _, ok := wf.deps[id]; !ok || len(j.Needs) > 0 || j.Skipped != nil {
+				continue
+			}
+
+			j.Approvals = append([]ApprovalGate{newEnvironmentApprovalGate(env, e.Approval)}, j.Approvals...)
+		}
+	}
+}
+
+// leaves returns the jobs that are not needed by any other job in the list.
+func (wf *Workflow) leaves(jobs []string) []string {
+	needed := map[string]struct{}{}
+	for _, id := range jobs {
+		for _, n := range wf.deps[id] {
+			needed[n] = struct{}{}
+		}
 	}
 
-	wf.Plan = plan
+	var leaves []string
+	for _, id := range jobs {
+		if _, ok := wf.deps[id]; !ok {
+			continue
+		}
 
-	return nil
+		if _, ok := needed[id]; !ok {
+			leaves = append(leaves, id)
+		}
+	}
+
+	return leaves
 }
 
-func (wf *Workflow) loadEnvironment(config Component) (map[string]Component, error) {
+func (wf *Workflow) loadEnvironment(config Component, envName string) (map[string]Component, error) {
 	var env Environment
-	if config.Environments != nil && wf.Options.Env != "" {
+	if config.Environments != nil && envName != "" {
 		var ok bool
-		env, ok = config.Environments[wf.Options.Env]
+		env, ok = config.Environments[envName]
 		if !ok {
-			return nil, fmt.Errorf("environment %q not found", wf.Options.Env)
+			return nil, fmt.Errorf("environment %q not found", envName)
 		}
 	}
 
@@ -118,7 +353,7 @@ func (wf *Workflow) loadEnvironment(config Component) (map[string]Component, err
 		replacement, replaced := env.Uses[name]
 		if replaced {
 			if err := replacement.Validate(); err != nil {
-				return nil, fmt.Errorf("environment %q: override for component %q: %w", wf.Options.Env, name, err)
+				return nil, fmt.Errorf("environment %q: override for component %q: %w", envName, name, err)
 			}
 
 			c = replacement
@@ -131,7 +366,14 @@ func (wf *Workflow) loadEnvironment(config Component) (map[string]Component, err
 			return nil, err
 		}
 
-		if err := mergo.Merge(defaults, c, mergo.WithOverride); err != nil {
+		// c is deep-copied so that merging defaults and overrides into nested structs
+		// like terraform doesn't modify the component shared across environments.
+		cc, err := DeepCopyComponent(c)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := mergo.Merge(defaults, cc, mergo.WithOverride); err != nil {
 			return nil, err
 		}
 
@@ -147,89 +389,46 @@ func (wf *Workflow) loadEnvironment(config Component) (map[string]Component, err
 			return nil, fmt.Errorf("component %q is both used and overridden. You can only use or override a component", name)
 		}
 
-		r[name] = c
+		r[name] = *defaults
 	}
 
 	for name := range env.Uses {
 		if _, ok := usedEnvs[name]; !ok {
-			return nil, fmt.Errorf("environment %q uses %q but it is not defined", wf.Options.Env, name)
+			return nil, fmt.Errorf("environment %q uses %q but it is not defined", envName, name)
 		}
 	}
 
 	for name := range env.Overrides {
 		if _, ok := overrodeEnvs[name]; !ok {
-			return nil, fmt.Errorf("environment %q overrides %q but it is not defined", wf.Options.Env, name)
+			return nil, fmt.Errorf("environment %q overrides %q but it is not defined", envName, name)
 		}
 	}
 
 	return r, nil
 }
 
-func (wf *Workflow) load(path, baseDir string, components map[string]Component) error {
-	const gitJob = "git"
-
-	// "git" job is a special job that is always added to the workflow
-	if _, ok := wf.WorkflowJobs[gitJob]; !ok {
-		dir := baseDir
-		driver := &Driver{
-			Output: kanvasOutputCommandForID(gitJob),
-			OutputFunc: func(r *Runtime, op Op, o map[string]string) error {
-				var tag bytes.Buffer
-				if err := r.Exec(dir, []string{"git", "tag", "--points-at", "HEAD"}, ExecStdout(&tag)); err != nil {
-					return fmt.Errorf("unable to get current git tag: %w", err)
-				}
-				o["tag"] = strings.TrimSpace(tag.String())
-
-				var sha bytes.Buffer
-				if err := r.Exec(dir, []string{"git", "rev-parse", "HEAD"}, ExecStdout(&sha)); err != nil {
-					return fmt.Errorf("unable to get current git sha: %w", err)
-				}
-				o["sha"] = strings.TrimSpace(sha.String())
-
-				return nil
-			},
-		}
-
-		wf.WorkflowJobs[gitJob] = &WorkflowJob{
-			Dir:    dir,
-			Driver: driver,
-		}
-	}
+// gitJob is the name of the special job that outputs the current git tag and sha
+const gitJob = "git"
 
+func (wf *Workflow) load(path, baseDir string, components map[string]Component) error {
 	for name, c := range components {
 		subPath := ID(path, name)
 
+		if err := checkComponentName(name); err != nil {
+			return fmt.Errorf("component %q: %w", subPath, err)
+		}
+
 		j := &WorkflowJob{}
 
 		//
 		// We can override the component's skipped flag via options
 		//
 
-		var outs map[string]map[string]string
-		if wf.Options.SkippedJobsOutputs != nil {
-			outs = wf.Options.SkippedJobsOutputs
-		} else {
-			outs = map[string]map[string]string{}
-		}
-		if len(outs) != len(wf.Options.Skip) {
-			return fmt.Errorf("the number of skipped jobs (%d) doesn't match the number of skipped jobs outputs (%d)", len(wf.Options.Skip), len(outs))
-		}
-
-		var skipped bool
-		for _, s := range wf.Options.Skip {
-			if s == subPath {
-				var m map[string]string
-				if o, ok := outs[subPath]; ok {
-					m = o
-				} else {
-					m = map[string]string{}
-				}
-
-				j.Skipped = m
-				skipped = true
-				break
-			}
+		skippedOutputs, skipped, err := wf.skippedOutputs(subPath)
+		if err != nil {
+			return err
 		}
+		j.Skipped = skippedOutputs
 
 		var needs []string
 		if !skipped {
@@ -242,7 +441,7 @@ func (wf *Workflow) load(path, baseDir string, components map[string]Component)
 					// And we do this only when any of the components needs the git job.
 					// Otherwise, we end up initializing (and possibly failing) the git component
 					// even when no other component needs it.
-					wf.deps[gitJob] = []string{}
+					wf.deps[ID(path, gitJob)] = []string{}
 				}
 			}
 		}
@@ -263,9 +462,46 @@ func (wf *Workflow) load(path, baseDir string, components map[string]Component)
 			return fmt.Errorf("component %q: %w", name, err)
 		}
 
+		if !skipped {
+			needs, err = wf.inferNeeds(subPath, needs, driver)
+			if err != nil {
+				return fmt.Errorf("component %q: %w", name, err)
+			}
+		}
+
+		if c.Timeout != "" {
+			timeout, err := time.ParseDuration(c.Timeout)
+			if err != nil {
+				return fmt.Errorf("component %q: invalid timeout %q: %w", name, c.Timeout, err)
+			}
+			j.Timeout = timeout
+		}
+
+		if c.Retry != nil {
+			retry, err := c.Retry.Policy()
+			if err != nil {
+				return fmt.Errorf("component %q: invalid retry: %w", name, err)
+			}
+			j.Retry = retry
+		}
+
 		j.Dir = dir
 		j.Needs = needs
 		j.Driver = driver
+		j.config = c
+		j.AlwaysRun = c.AlwaysRun
+
+		for _, w := range c.Watch {
+			if w != "" && w[0] == '/' {
+				j.Watch = append(j.Watch, filepath.Join(wf.Dir, w))
+			} else {
+				j.Watch = append(j.Watch, filepath.Join(dir, w))
+			}
+		}
+
+		if c.Approval != nil {
+			j.Approvals = append(j.Approvals, newApprovalGate(subPath, c.Approval))
+		}
 
 		wf.WorkflowJobs[subPath] = j
 
@@ -275,8 +511,152 @@ func (wf *Workflow) load(path, baseDir string, components map[string]Component)
 			}
 		}
 
+		if len(c.Tests) > 0 {
+			wf.tests = append(wf.tests, testSet{path: subPath, baseDir: dir, tests: c.Tests})
+		}
+
 		wf.deps[subPath] = needs
 	}
 
 	return nil
 }
+
+// addGitJob adds the "git" job, which is a special job that is always added to the workflow.
+func (wf *Workflow) addGitJob(id, dir string) {
+	if _, ok := wf.WorkflowJobs[id]; ok {
+		return
+	}
+
+	driver := &Driver{
+		Output: kanvasOutputCommandForID(id, wf.Options),
+		OutputFunc: func(r *Runtime, op Op, o Outputs) error {
+			var tag bytes.Buffer
+			if err := r.Exec(dir, []string{"git", "tag", "--points-at", "HEAD"}, ExecStdout(&tag)); err != nil {
+				return fmt.Errorf("unable to get current git tag: %w", err)
+			}
+			o["tag"] = strings.TrimSpace(tag.String())
+
+			var sha bytes.Buffer
+			if err := r.Exec(dir, []string{"git", "rev-parse", "HEAD"}, ExecStdout(&sha)); err != nil {
+				return fmt.Errorf("unable to get current git sha: %w", err)
+			}
+			o["sha"] = strings.TrimSpace(sha.String())
+
+			return nil
+		},
+	}
+
+	wf.WorkflowJobs[id] = &WorkflowJob{
+		Dir:    dir,
+		Driver: driver,
+		// The git job reads the current commit, which is not a part of its inputs.
+		AlwaysRun: true,
+	}
+}
+
+// skippedOutputs returns the outputs for the job if the job is skipped via options.
+func (wf *Workflow) skippedOutputs(id string) (Outputs, bool, error) {
+	var outs map[string]Outputs
+	if wf.Options.SkippedJobsOutputs != nil {
+		outs = wf.Options.SkippedJobsOutputs
+	} else {
+		outs = map[string]Outputs{}
+	}
+	if len(outs) != len(wf.Options.Skip) {
+		return nil, false, fmt.Errorf("the number of skipped jobs (%d) doesn't match the number of skipped jobs outputs (%d)", len(wf.Options.Skip), len(outs))
+	}
+
+	for _, s := range wf.Options.Skip {
+		if s == id {
+			var m Outputs
+			if o, ok := outs[id]; ok {
+				m = o
+			} else {
+				m = Outputs{}
+			}
+
+			return m, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// loadTests adds the synthetic tests to the workflow.
+// Each test becomes a job that runs after the components it needs.
+//
+// It must be called after all the components are loaded, so that a test conflicting with any component is detected.
+func (wf *Workflow) loadTests(path, baseDir string, tests map[string]Test) error {
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t := tests[name]
+		id := ID(path, name)
+
+		if _, ok := wf.WorkflowJobs[id]; ok {
+			return fmt.Errorf("test %q: conflicts with the component of the same name", name)
+		}
+
+		skippedOutputs, skipped, err := wf.skippedOutputs(id)
+		if err != nil {
+			return err
+		}
+
+		var needs []string
+		if !skipped {
+			for _, n := range t.needs() {
+				needs = append(needs, ID(path, n))
+
+				if n == gitJob {
+					wf.deps[ID(path, gitJob)] = []string{}
+				}
+			}
+		}
+
+		driver, err := newTestDriver(id, t, wf.Options)
+		if err != nil {
+			return fmt.Errorf("test %q: %w", name, err)
+		}
+
+		wf.WorkflowJobs[id] = &WorkflowJob{
+			Skipped: skippedOutputs,
+			Dir:     baseDir,
+			Needs:   needs,
+			Driver:  driver,
+			// A test checks the live state of the deployment,
+			// which can change without any change to its inputs.
+			AlwaysRun: true,
+			config:    t,
+		}
+
+		wf.deps[id] = needs
+	}
+
+	return nil
+}
+
+// isGroup reports whether the job is a component only for grouping its sub-components,
+// which has no driver of its own.
+func (j *WorkflowJob) isGroup() bool {
+	c, ok := j.config.(Component)
+	if !ok || len(c.Components) == 0 {
+		return false
+	}
+
+	return c.AWS == nil && c.Docker == nil && c.Terraform == nil && c.Kubernetes == nil &&
+		c.Externals == nil && c.GitHubFiles == nil && c.Noop == nil
+}
+
+// checkComponentName returns an error when the name cannot be used for a component,
+// because the references to its outputs would be read as something else in expressions.
+func checkComponentName(name string) error {
+	if name == expr.EnvPrefix {
+		return fmt.Errorf("%q cannot be used as a component name, because %s.NAME refers to the environment variable NAME in expressions", name, expr.EnvPrefix)
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package kanvas_test
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/davinci-std/kanvas"
 	"github.com/mumoshu/kargo"
@@ -75,7 +76,7 @@ func TestWorkflowLoad_SkipImage(t *testing.T) {
 
 	opts := func(o *kanvas.Options) {
 		o.Skip = []string{"image"}
-		o.SkippedJobsOutputs = map[string]map[string]string{
+		o.SkippedJobsOutputs = map[string]kanvas.Outputs{
 			"image": {
 				"id": "sha256:12356935acbd6a67d3fed10512d89450330047f3ae6fb3a62e9bf4f229529387",
 			},
@@ -103,7 +104,7 @@ func TestWorkflowLoad_SkipTransitive(t *testing.T) {
 
 	opts := func(o *kanvas.Options) {
 		o.Skip = []string{"image", "prereq"}
-		o.SkippedJobsOutputs = map[string]map[string]string{
+		o.SkippedJobsOutputs = map[string]kanvas.Outputs{
 			"image": {
 				"tag": "foobar",
 			},
@@ -130,7 +131,7 @@ func TestWorkflowLoad_SkipTransitive(t *testing.T) {
 func TestWorkflowLoad_TryingToSkipUnneededGit(t *testing.T) {
 	opts := func(o *kanvas.Options) {
 		o.Skip = []string{"image", "prereq"}
-		o.SkippedJobsOutputs = map[string]map[string]string{
+		o.SkippedJobsOutputs = map[string]kanvas.Outputs{
 			"image": {
 				"tag": "foobar",
 			},
@@ -152,3 +153,360 @@ func TestWorkflowLoad_TryingToSkipUnneededGit(t *testing.T) {
 	require.Error(t, err)
 	require.Equal(t, `loading "" "testdata/workflow": the number of skipped jobs (2) doesn't match the number of skipped jobs outputs (3)`, err.Error())
 }
+
+func TestWorkflowLoad_Tests(t *testing.T) {
+	plan := [][]string{
+		{"git", "prereq"},
+		{"image"},
+		{"deploy"},
+		{"ping"},
+	}
+
+	c := newComponent()
+	c.Tests = map[string]kanvas.Test{
+		"ping": {
+			Needs:      []string{"image"},
+			Prober:     kanvas.ProberHTTP,
+			TargetFrom: "deploy.endpoint",
+		},
+	}
+	o := kanvas.Options{
+		TempDir: t.TempDir(),
+	}
+
+	w, err := kanvas.NewWorkflow(c, o)
+	require.NoError(t, err)
+
+	require.Equal(t, plan, w.Plan)
+	require.Equal(t, []string{"image", "deploy"}, w.WorkflowJobs["ping"].Needs)
+}
+
+func TestWorkflowLoad_InvalidTest(t *testing.T) {
+	c := newComponent()
+	c.Tests = map[string]kanvas.Test{
+		"ping": {
+			Prober: "smtp",
+			Target: "example.com",
+		},
+	}
+	o := kanvas.Options{
+		TempDir: t.TempDir(),
+	}
+
+	_, err := kanvas.NewWorkflow(c, o)
+	require.EqualError(t, err, `loading tests "" "testdata/workflow": test "ping": unsupported prober "smtp": it must be one of http, tcp, icmp, or dns`)
+}
+
+func TestWorkflowLoad_TestConflict(t *testing.T) {
+	c := newComponent()
+	c.Tests = map[string]kanvas.Test{
+		"deploy": {
+			Prober: kanvas.ProberTCP,
+			Target: "example.com:443",
+		},
+	}
+	o := kanvas.Options{
+		TempDir: t.TempDir(),
+	}
+
+	_, err := kanvas.NewWorkflow(c, o)
+	require.EqualError(t, err, `loading tests "" "testdata/workflow": test "deploy": conflicts with the component of the same name`)
+}
+
+func TestWorkflowLoad_EnvComponent(t *testing.T) {
+	c := newComponent()
+	c.Components["env"] = kanvas.Component{Noop: &kanvas.Noop{}}
+
+	_, err := kanvas.NewWorkflow(c, kanvas.Options{TempDir: t.TempDir()})
+	require.EqualError(t, err, `loading "" "testdata/workflow": component "env": "env" cannot be used as a component name, because env.NAME refers to the environment variable NAME in expressions`)
+}
+
+func TestWorkflowLoad_EnvironmentNeeds(t *testing.T) {
+	plan := [][]string{
+		{"/preview/git", "/preview/prereq"},
+		{"/preview/image"},
+		{"/preview/deploy"},
+		{"/production/git", "/production/prereq"},
+		{"/production/image"},
+		{"/production/deploy"},
+	}
+
+	c := newComponent()
+	c.Environments = map[string]kanvas.Environment{
+		"preview": {},
+		"production": {
+			Needs: []string{"preview"},
+		},
+	}
+	o := kanvas.Options{
+		TempDir: t.TempDir(),
+		Env:     "production",
+	}
+
+	w, err := kanvas.NewWorkflow(c, o)
+	require.NoError(t, err)
+
+	require.Equal(t, plan, w.Plan)
+	require.Equal(t, []string{"/preview/deploy"}, w.WorkflowJobs["/production/prereq"].Needs)
+	require.Equal(t, []string{"/production/git", "/production/prereq"}, w.WorkflowJobs["/production/image"].Needs)
+}
+
+func TestWorkflowLoad_EnvironmentNeedsGroups(t *testing.T) {
+	c := kanvas.Component{
+		Dir: "testdata/workflow",
+		Components: map[string]kanvas.Component{
+			"product1": {
+				Components: map[string]kanvas.Component{
+					"appimage": {Noop: &kanvas.Noop{}},
+					"base": {
+						Needs: []string{"appimage"},
+						Noop:  &kanvas.Noop{},
+					},
+				},
+			},
+		},
+		Environments: map[string]kanvas.Environment{
+			"preview": {},
+			"production": {
+				Needs: []string{"preview"},
+			},
+		},
+	}
+
+	single, err := kanvas.NewWorkflow(c, kanvas.Options{TempDir: t.TempDir(), Env: "preview"})
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"/product1/appimage"},
+		{"/product1/base"},
+	}, single.Plan)
+
+	// The grouping components are removed in the same way as the single environment,
+	// so that the next environment waits only for the components actually run.
+	multi, err := kanvas.NewWorkflow(c, kanvas.Options{TempDir: t.TempDir(), Env: "production"})
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"/preview/product1/appimage"},
+		{"/preview/product1/base"},
+		{"/production/product1/appimage"},
+		{"/production/product1/base"},
+	}, multi.Plan)
+	require.Equal(t, []string{"/preview/product1/base"}, multi.WorkflowJobs["/production/product1/appimage"].Needs)
+}
+
+func TestWorkflowLoad_EnvironmentNeedsCycle(t *testing.T) {
+	c := newComponent()
+	c.Environments = map[string]kanvas.Environment{
+		"preview": {
+			After: []string{"production"},
+		},
+		"production": {
+			Needs: []string{"preview"},
+		},
+	}
+	o := kanvas.Options{
+		TempDir: t.TempDir(),
+		Env:     "production",
+	}
+
+	_, err := kanvas.NewWorkflow(c, o)
+	require.EqualError(t, err, `environment "production": the graph contains a cycle`)
+}
+
+func TestWorkflowLoad_Approvals(t *testing.T) {
+	c := newComponent()
+	deploy := c.Components["deploy"]
+	deploy.Approval = &kanvas.Approval{}
+	c.Components["deploy"] = deploy
+	c.Environments = map[string]kanvas.Environment{
+		"production": {
+			Approval: &kanvas.Approval{
+				Environment: "prod",
+			},
+		},
+	}
+	o := kanvas.Options{
+		TempDir: t.TempDir(),
+		Env:     "production",
+	}
+
+	w, err := kanvas.NewWorkflow(c, o)
+	require.NoError(t, err)
+
+	require.Equal(t, []kanvas.ApprovalGate{{ID: "deploy"}}, w.WorkflowJobs["deploy"].Approvals)
+	require.Equal(t, []kanvas.ApprovalGate{{ID: "env:production", Environment: "prod"}}, w.WorkflowJobs["prereq"].Approvals)
+	require.Empty(t, w.WorkflowJobs["image"].Approvals)
+}
+
+func TestWorkflowLoad_TerraformEnvironmentDefaults(t *testing.T) {
+	c := newComponent()
+	c.Components["infra"] = kanvas.Component{
+		Terraform: &kanvas.Terraform{
+			Target: "aws_s3_bucket.b",
+		},
+	}
+	c.Environments = map[string]kanvas.Environment{
+		"production": {
+			Defaults: kanvas.Component{
+				Terraform: &kanvas.Terraform{
+					Workspace: "production",
+					VarFiles:  []string{"production.tfvars"},
+				},
+			},
+			Overrides: map[string]kanvas.Component{
+				"infra": {
+					Terraform: &kanvas.Terraform{
+						BackendConfig: []kanvas.BackendConfig{
+							{Name: "key", Value: "production/infra.tfstate"},
+							{File: "production.s3.tfbackend"},
+						},
+					},
+				},
+			},
+		},
+	}
+	o := kanvas.Options{
+		TempDir: t.TempDir(),
+		Env:     "production",
+	}
+
+	w, err := kanvas.NewWorkflow(c, o)
+	require.NoError(t, err)
+
+	var cmds [][]string
+	for _, task := range w.WorkflowJobs["infra"].Driver.Apply {
+		for _, r := range task.Run {
+			cmds = append(cmds, append([]string{r.Name}, r.Args.MustCollect(nil)...))
+		}
+	}
+
+	require.Equal(t, [][]string{
+		{"terraform", "init", "-backend-config", "key=production/infra.tfstate", "-backend-config", "production.s3.tfbackend"},
+		{"terraform", "workspace", "select", "-or-create", "production"},
+		{"terraform", "apply", "-target", "aws_s3_bucket.b", "-var-file", "production.tfvars", "-auto-approve"},
+	}, cmds)
+
+	cmds = nil
+	for _, task := range w.WorkflowJobs["infra"].Driver.Drift {
+		for _, r := range task.Run {
+			cmds = append(cmds, append([]string{r.Name}, r.Args.MustCollect(nil)...))
+		}
+	}
+
+	// Drift must fail rather than create the missing workspace
+	require.Equal(t, [][]string{
+		{"terraform", "init", "-backend-config", "key=production/infra.tfstate", "-backend-config", "production.s3.tfbackend"},
+		{"terraform", "workspace", "select", "production"},
+		{"terraform", "plan", "-detailed-exitcode", "-target", "aws_s3_bucket.b", "-var-file", "production.tfvars"},
+	}, cmds)
+
+	// The defaults must not leak into the component shared across environments
+	require.Equal(t, &kanvas.Terraform{Target: "aws_s3_bucket.b"}, c.Components["infra"].Terraform)
+}
+
+func TestWorkflowLoad_TerraformPlanDirPreview(t *testing.T) {
+	c := newComponent()
+	c.Components["infra"] = kanvas.Component{
+		Terraform: &kanvas.Terraform{
+			Target: "aws_s3_bucket.b",
+		},
+	}
+	o := kanvas.Options{
+		TempDir: t.TempDir(),
+		PlanDir: "plans",
+	}
+
+	w, err := kanvas.NewWorkflow(c, o)
+	require.NoError(t, err)
+
+	var cmds [][]string
+	for _, task := range w.WorkflowJobs["infra"].Driver.Preview {
+		for _, r := range task.Run {
+			cmds = append(cmds, append([]string{r.Name}, r.Args.MustCollect(nil)...))
+		}
+	}
+
+	// The preview must show the saved plan rather than overwrite it by planning again
+	require.Equal(t, [][]string{
+		{"terraform", "init"},
+		{"kanvas", "tools", "terraform-plan-fingerprint", "--plan", "../../plans/infra.tfplan", "--verify", "--", "-target", "aws_s3_bucket.b"},
+		{"terraform", "show", "../../plans/infra.tfplan"},
+	}, cmds)
+}
+
+func TestWorkflowLoad_Timeout(t *testing.T) {
+	c := newComponent()
+	deploy := c.Components["deploy"]
+	deploy.Timeout = "30m"
+	c.Components["deploy"] = deploy
+
+	o := kanvas.Options{
+		TempDir: t.TempDir(),
+	}
+
+	w, err := kanvas.NewWorkflow(c, o)
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Minute, w.WorkflowJobs["deploy"].Timeout)
+	require.Zero(t, w.WorkflowJobs["image"].Timeout)
+
+	deploy.Timeout = "30"
+	c.Components["deploy"] = deploy
+
+	_, err = kanvas.NewWorkflow(c, o)
+	require.ErrorContains(t, err, `component "deploy": invalid timeout "30"`)
+}
+
+func TestWorkflowLoad_InferNeeds(t *testing.T) {
+	c := newComponent()
+	image := c.Components["image"]
+	image.Needs = []string{"prereq"}
+	c.Components["image"] = image
+	deploy := c.Components["deploy"]
+	deploy.Needs = nil
+	c.Components["deploy"] = deploy
+
+	o := kanvas.Options{
+		TempDir: t.TempDir(),
+	}
+
+	w, err := kanvas.NewWorkflow(c, o)
+	require.NoError(t, err)
+
+	require.Equal(t, [][]string{
+		{"git", "prereq"},
+		{"image"},
+		{"deploy"},
+	}, w.Plan)
+	require.Equal(t, []string{"prereq", "git"}, w.WorkflowJobs["image"].Needs)
+	require.Equal(t, []string{"image"}, w.WorkflowJobs["deploy"].Needs)
+	require.Equal(t, []string{
+		`component "deploy": it refers to the outputs of image but does not list them in needs`,
+		`component "image": it refers to the outputs of git but does not list them in needs`,
+	}, w.Warnings)
+
+	o.Strict = true
+
+	_, err = kanvas.NewWorkflow(c, o)
+	require.Error(t, err)
+	require.Regexp(t, `component "(deploy|image)": it refers to the outputs of (image|git) but does not list them in needs`, err.Error())
+}
+
+func TestWorkflowLoad_RedundantNeeds(t *testing.T) {
+	c := newComponent()
+	o := kanvas.Options{
+		TempDir: t.TempDir(),
+	}
+
+	w, err := kanvas.NewWorkflow(c, o)
+	require.NoError(t, err)
+	require.Equal(t, []string{"git", "prereq"}, w.WorkflowJobs["image"].Needs)
+	require.Equal(t, []string{
+		`component "deploy": needs image, which can be omitted because they are inferred from the references to their outputs`,
+		`component "image": needs git, which can be omitted because they are inferred from the references to their outputs`,
+	}, w.Warnings)
+
+	o.Strict = true
+
+	_, err = kanvas.NewWorkflow(c, o)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "which can be omitted because they are inferred from the references to their outputs")
+}